@@ -0,0 +1,94 @@
+package abi_registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/config"
+	"main/logging"
+	"main/models"
+	"main/store"
+)
+
+// Static configuration variables initalized at runtime.
+var abiDirectory string
+
+// init loads the ABI registry configurations.
+func init() {
+	abiDirectory = config.GetString("ABI_DIRECTORY")
+}
+
+// Initialize registers the ABIs in the ABI directory, if configured. Each
+// file is named after the address of its contract, e.g. 0xdAC1....json, and
+// holds the ABI or a build artifact with the ABI in its abi field. The ABIs
+// in the directory replace the ones registered before.
+func Initialize(ctx context.Context) {
+	if len(abiDirectory) <= 0 {
+		return
+	}
+
+	paths, err := filepath.Glob(filepath.Join(abiDirectory, "*.json"))
+	if err != nil {
+		panic(err)
+	}
+	for _, path := range paths {
+		address := strings.TrimSuffix(filepath.Base(path), ".json")
+		if !common.IsHexAddress(address) {
+			panic(fmt.Sprintf("%s: file name must be a contract address", path))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		normalized, err := NormalizeABI(data)
+		if err != nil {
+			panic(fmt.Sprintf("%s: %v", path, err))
+		}
+		if err := Register(ctx, address, normalized); err != nil {
+			panic(err)
+		}
+	}
+	logging.Info(ctx, "Registered %d ABIs from %s", len(paths), abiDirectory)
+}
+
+// NormalizeABI validates an ABI, or a build artifact with the ABI in its abi
+// field, and returns the ABI as compact JSON.
+func NormalizeABI(data []byte) (string, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		artifact := struct {
+			ABI json.RawMessage `json:"abi"`
+		}{}
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return "", fmt.Errorf("invalid artifact: %w", err)
+		}
+		if len(artifact.ABI) == 0 {
+			return "", fmt.Errorf("artifact has no abi")
+		}
+		data = artifact.ABI
+	}
+	if _, err := abi.JSON(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("invalid abi: %w", err)
+	}
+
+	compact := bytes.Buffer{}
+	if err := json.Compact(&compact, data); err != nil {
+		return "", err
+	}
+	return compact.String(), nil
+}
+
+// Register registers the normalized ABI of a contract, replacing the one
+// registered before.
+func Register(ctx context.Context, address, normalized string) error {
+	return store.ABIs.SetABI(ctx,
+		models.NewContractABI(common.HexToAddress(address).String(), normalized))
+}
@@ -0,0 +1,223 @@
+package abi_registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"main/logging"
+	"main/models"
+	"main/store"
+)
+
+// Sources of decoded calls and events.
+const (
+	SourceABI       = "abi"
+	SourceSignature = "signature"
+)
+
+// Decoded is a function call or an event decoded with the ABI of its
+// contract, or with a bundled signature.
+type Decoded struct {
+	Name      string            `json:"name"`
+	Signature string            `json:"signature"`
+	Source    string            `json:"source"`
+	Arguments []DecodedArgument `json:"arguments"`
+}
+
+// DecodedArgument is an argument of a decoded call or event. Integers are
+// given as decimal strings, bytes as hex and arrays and tuples as lists.
+// Indexed strings, bytes, arrays and tuples are only known by their hash.
+type DecodedArgument struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Indexed bool        `json:"indexed,omitempty"`
+	Value   interface{} `json:"value"`
+}
+
+// errTopicCount is returned if the topics of a log don't match the indexed
+// arguments of an event.
+var errTopicCount = errors.New("topic count mismatch")
+
+// DecodeTransaction decodes the input data of a transaction and its logs with
+// the ABIs registered for the contracts called and emitting them, falling
+// back to the bundled signatures. The input and the logs which can't be
+// decoded are nil.
+func DecodeTransaction(ctx context.Context, tx models.TransactionIntf,
+	logs []models.TransactionLogIntf) (*Decoded, []*Decoded, error) {
+	// get the ABI of each contract once
+	abis := map[string]*abi.ABI{}
+	getABI := func(address string) (*abi.ABI, error) {
+		if len(address) == 0 {
+			return nil, nil
+		}
+		if contractABI, ok := abis[address]; ok {
+			return contractABI, nil
+		}
+		registered, err := store.ABIs.GetByAddress(ctx, address)
+		if errors.Is(err, store.ErrNotFound) {
+			abis[address] = nil
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		contractABI, err := abi.JSON(strings.NewReader(registered.GetABI()))
+		if err != nil {
+			logging.Warn(ctx, "Ignoring invalid ABI of %s: %v", address, err)
+			abis[address] = nil
+			return nil, nil
+		}
+		abis[address] = &contractABI
+		return &contractABI, nil
+	}
+
+	contractABI, err := getABI(tx.GetTxTo())
+	if err != nil {
+		return nil, nil, err
+	}
+	input := decodeCall(contractABI, tx.GetData())
+
+	events := make([]*Decoded, len(logs))
+	for i, log := range logs {
+		contractABI, err := getABI(log.GetAddress())
+		if err != nil {
+			return nil, nil, err
+		}
+		topics := make([]common.Hash, 0, len(log.GetTopics()))
+		for _, topic := range log.GetTopics() {
+			topics = append(topics, common.HexToHash(topic))
+		}
+		events[i] = decodeLog(contractABI, topics, log.GetData())
+	}
+	return input, events, nil
+}
+
+// decodeCall decodes input data with the ABI if given and it has the method,
+// or else with the first bundled function signature decoding it.
+func decodeCall(contractABI *abi.ABI, data []byte) *Decoded {
+	if len(data) < 4 {
+		return nil
+	}
+	if contractABI != nil {
+		if method, err := contractABI.MethodById(data[:4]); err == nil {
+			if args, err := decodeArguments(method.Inputs, nil, data[4:]); err == nil {
+				return &Decoded{Name: method.RawName, Signature: method.Sig,
+					Source: SourceABI, Arguments: args}
+			}
+		}
+	}
+
+	var selector [4]byte
+	copy(selector[:], data)
+	for _, s := range functions[selector] {
+		if args, err := decodeArguments(s.inputs, nil, data[4:]); err == nil {
+			return &Decoded{Name: s.name, Signature: s.signature,
+				Source: SourceSignature, Arguments: args}
+		}
+	}
+	return nil
+}
+
+// decodeLog decodes a log with the ABI if given and it has the event, or
+// else with the first bundled event signature decoding it. Anonymous events
+// aren't decoded.
+func decodeLog(contractABI *abi.ABI, topics []common.Hash, data []byte) *Decoded {
+	if len(topics) == 0 {
+		return nil
+	}
+	if contractABI != nil {
+		if event, err := contractABI.EventByID(topics[0]); err == nil {
+			if args, err := decodeArguments(event.Inputs, topics[1:], data); err == nil {
+				return &Decoded{Name: event.RawName, Signature: event.Sig,
+					Source: SourceABI, Arguments: args}
+			}
+		}
+	}
+
+	for _, s := range events[topics[0]] {
+		if args, err := decodeArguments(s.inputs, topics[1:], data); err == nil {
+			return &Decoded{Name: s.name, Signature: s.signature,
+				Source: SourceSignature, Arguments: args}
+		}
+	}
+	return nil
+}
+
+// decodeArguments decodes the indexed arguments from the topics, and the
+// others from the data.
+func decodeArguments(args abi.Arguments, topics []common.Hash, data []byte) ([]DecodedArgument, error) {
+	indexed := 0
+	for _, arg := range args {
+		if arg.Indexed {
+			indexed++
+		}
+	}
+	if indexed != len(topics) {
+		return nil, errTopicCount
+	}
+	values, err := args.UnpackValues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := make([]DecodedArgument, 0, len(args))
+	for _, arg := range args {
+		var value interface{}
+		if arg.Indexed {
+			out := map[string]interface{}{}
+			if err := abi.ParseTopicsIntoMap(out, abi.Arguments{arg}, topics[:1]); err != nil {
+				return nil, err
+			}
+			value, topics = out[arg.Name], topics[1:]
+		} else {
+			value, values = values[0], values[1:]
+		}
+		decoded = append(decoded, DecodedArgument{
+			Name:    arg.Name,
+			Type:    arg.Type.String(),
+			Indexed: arg.Indexed,
+			Value:   formatValue(arg.Type, value),
+		})
+	}
+	return decoded, nil
+}
+
+// formatValue returns a decoded value in a JSON friendly form.
+func formatValue(t abi.Type, value interface{}) interface{} {
+	// indexed dynamic values are only known by their hash
+	if hash, ok := value.(common.Hash); ok {
+		return hash.String()
+	}
+
+	v := reflect.ValueOf(value)
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		return fmt.Sprint(value)
+	case abi.AddressTy:
+		return value.(common.Address).String()
+	case abi.BytesTy, abi.FixedBytesTy, abi.FunctionTy:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return hexutil.Bytes(b)
+	case abi.SliceTy, abi.ArrayTy:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = formatValue(*t.Elem, v.Index(i).Interface())
+		}
+		return list
+	case abi.TupleTy:
+		list := make([]interface{}, len(t.TupleElems))
+		for i, elem := range t.TupleElems {
+			list[i] = formatValue(*elem, v.Field(i).Interface())
+		}
+		return list
+	default:
+		return value
+	}
+}
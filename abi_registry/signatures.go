@@ -0,0 +1,115 @@
+package abi_registry
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// signature is a bundled function or event signature.
+type signature struct {
+	name      string
+	signature string
+	inputs    abi.Arguments
+}
+
+//go:embed signatures.txt
+var signaturesFile string
+
+// The bundled signatures by function selector and by event topic.
+var functions = map[[4]byte][]signature{}
+var events = map[common.Hash][]signature{}
+
+// init parses the bundled signatures.
+func init() {
+	for i, line := range strings.Split(signaturesFile, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parseDeclaration(line); err != nil {
+			panic(fmt.Sprintf("signatures.txt:%d: %v", i+1, err))
+		}
+	}
+}
+
+// parseDeclaration parses a function or event declaration, such as
+// "event Transfer(address indexed from,address indexed to,uint256 value)",
+// and adds its signature.
+func parseDeclaration(line string) error {
+	kind, rest, _ := strings.Cut(line, " ")
+	lparen, rparen := strings.Index(rest, "("), strings.LastIndex(rest, ")")
+	if lparen <= 0 || rparen != len(rest)-1 {
+		return fmt.Errorf("invalid declaration %q", line)
+	}
+	name := rest[:lparen]
+
+	// split the parameters into their types, names and indexed flags
+	types, names, indexed := []string{}, []string{}, []bool{}
+	for _, param := range splitParams(rest[lparen+1 : rparen]) {
+		fields := strings.Fields(param)
+		if len(fields) == 0 {
+			return fmt.Errorf("empty parameter in %q", line)
+		}
+		types = append(types, fields[0])
+		indexed = append(indexed, len(fields) > 1 && fields[1] == "indexed")
+		names = append(names, "")
+		if len(fields) > 1 && fields[len(fields)-1] != "indexed" {
+			names[len(names)-1] = fields[len(fields)-1]
+		}
+	}
+
+	// parse the types, with the components of tuples
+	selector, err := abi.ParseSelector(name + "(" + strings.Join(types, ",") + ")")
+	if err != nil {
+		return err
+	}
+	inputs := make(abi.Arguments, len(selector.Inputs))
+	for i, input := range selector.Inputs {
+		typ, err := abi.NewType(input.Type, "", input.Components)
+		if err != nil {
+			return err
+		}
+		inputs[i] = abi.Argument{Name: names[i], Type: typ, Indexed: indexed[i]}
+	}
+
+	switch kind {
+	case "function":
+		method := abi.NewMethod(name, name, abi.Function, "", false, false, inputs, nil)
+		var id [4]byte
+		copy(id[:], method.ID)
+		functions[id] = append(functions[id], signature{name, method.Sig, inputs})
+	case "event":
+		event := abi.NewEvent(name, name, false, inputs)
+		events[event.ID] = append(events[event.ID], signature{name, event.Sig, inputs})
+	default:
+		return fmt.Errorf("unknown declaration kind %q", kind)
+	}
+	return nil
+}
+
+// splitParams splits a parameter list on the commas outside tuples.
+func splitParams(params string) []string {
+	if len(strings.TrimSpace(params)) == 0 {
+		return nil
+	}
+	parts := []string{}
+	depth, start := 0, 0
+	for i, c := range params {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, params[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, params[start:])
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"main/abi_registry"
+	"main/logging"
+	"main/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// maxABISize is the maximum size in bytes of an uploaded ABI.
+const maxABISize = 4 << 20
+
+// ContractABI is the ABI registered for a contract.
+type ContractABI struct {
+	Address   string          `json:"address"`
+	ABI       json.RawMessage `json:"abi"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// GetABI returns the ABI registered for a contract.
+func GetABI(ctx *gin.Context) {
+	// Get the address from URL path parameter, in the checksum form the
+	// indexer stores
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+
+	registered, err := store.ABIs.GetByAddress(ctx.Request.Context(), address)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "abi"))
+		return
+	}
+
+	ctx.Set("response", ContractABI{
+		Address:   registered.GetAddress(),
+		ABI:       json.RawMessage(registered.GetABI()),
+		UpdatedAt: registered.GetUpdatedAt(),
+	})
+}
+
+// RegisterABI registers the ABI of a contract, given as the request body or
+// as a build artifact with the ABI in its abi field, replacing the one
+// registered before.
+func RegisterABI(ctx *gin.Context) {
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxABISize))
+	if err != nil {
+		respondWithError(ctx, newValidationError("invalid request body: %v", err))
+		return
+	}
+	normalized, err := abi_registry.NormalizeABI(body)
+	if err != nil {
+		respondWithError(ctx, newValidationError("%v", err))
+		return
+	}
+	if err := abi_registry.Register(ctx.Request.Context(), address, normalized); err != nil {
+		respondWithError(ctx, newDatabaseError(err, "abi"))
+		return
+	}
+	logging.Info(ctx.Request.Context(), "Registered the ABI of %s", address)
+
+	registered, err := store.ABIs.GetByAddress(ctx.Request.Context(), address)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "abi"))
+		return
+	}
+	ctx.Set("response", ContractABI{
+		Address:   registered.GetAddress(),
+		ABI:       json.RawMessage(registered.GetABI()),
+		UpdatedAt: registered.GetUpdatedAt(),
+	})
+}
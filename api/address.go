@@ -0,0 +1,71 @@
+package api
+
+import (
+	"main/api/middleware"
+	"main/models"
+	"main/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Default and maximum number of internal transactions listed.
+const (
+	defaultInternalTxsLimit = 20
+	maxInternalTxsLimit     = 100
+)
+
+func init() {
+	// Setup address router group.
+	root := GetRoot().Group("address",
+		middleware.FormatResponse())
+	root.GET("/:address/internal_transactions", GetInternalTxsByAddress)
+	root.GET("/:address/token_transfers", GetTokenTransfersByAddress)
+	root.GET("/:address/tokens", GetTokenBalancesByAddress)
+}
+
+// GetInternalTxsByAddress lists the latest internal transactions from or to
+// an address, the most recent first.
+func GetInternalTxsByAddress(ctx *gin.Context) {
+	// Get the address from URL path parameter, in the checksum form the
+	// indexer stores
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+	level, err := getConsistency(ctx)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	// Get the number of requested internal transactions
+	limit, err := getLimit(ctx, defaultInternalTxsLimit, maxInternalTxsLimit)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	// Only list internal transactions of blocks served at the consistency
+	// level
+	servedUpTo, err := getServedUpTo(ctx.Request.Context(), level)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "internal transactions"))
+		return
+	}
+	internalTxs := []models.InternalTransactionIntf{}
+	if servedUpTo != nil {
+		internalTxs, err = store.InternalTxs.GetByAddress(
+			ctx.Request.Context(), address, limit, *servedUpTo)
+		if err != nil {
+			respondWithError(ctx, newDatabaseError(err, "internal transactions"))
+			return
+		}
+	}
+
+	// Set results to context.
+	setConsistency(ctx, level)
+	ctx.Set("response", internalTxs)
+}
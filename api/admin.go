@@ -0,0 +1,108 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"main/api/middleware"
+	"main/config"
+	"main/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogLevelsUpdate is the request body to change log levels. Levels are given
+// as number (1-5) or name, e.g. "debug". A null module level clears it.
+type LogLevelsUpdate struct {
+	Default interface{}            `json:"default"`
+	Modules map[string]interface{} `json:"modules"`
+}
+
+func init() {
+	// Setup admin router group.
+	root := GetRoot().Group("admin",
+		requireAdmin,
+		middleware.FormatResponse())
+	root.GET("/log-levels", GetLogLevels)
+	root.PUT("/log-levels", UpdateLogLevels)
+	root.GET("/abis/:address", GetABI)
+	root.PUT("/abis/:address", RegisterABI)
+}
+
+// requireAdmin aborts requests without the configured admin bearer token.
+// The admin API is disabled if no token is configured.
+func requireAdmin(ctx *gin.Context) {
+	token := config.GetString("ADMIN_TOKEN")
+	if len(token) <= 0 {
+		respondWithError(ctx, &APIError{
+			Status:  http.StatusForbidden,
+			Code:    ErrCodeForbidden,
+			Message: "admin API disabled",
+		})
+		return
+	}
+
+	auth := ctx.GetHeader("Authorization")
+	given := strings.TrimPrefix(auth, "Bearer ")
+	if !strings.HasPrefix(auth, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		respondWithError(ctx, &APIError{
+			Status:  http.StatusUnauthorized,
+			Code:    ErrCodeUnauthorized,
+			Message: "invalid admin token",
+		})
+		return
+	}
+}
+
+// GetLogLevels returns the current log levels.
+func GetLogLevels(ctx *gin.Context) {
+	ctx.Set("response", logging.GetLevels())
+}
+
+// UpdateLogLevels changes log levels at runtime.
+func UpdateLogLevels(ctx *gin.Context) {
+	update := LogLevelsUpdate{}
+	if err := ctx.ShouldBindJSON(&update); err != nil {
+		respondWithError(ctx, newValidationError("invalid request body: %v", err))
+		return
+	}
+
+	// Validate all levels before changing any.
+	var defaultLevel uint
+	var err error
+	if update.Default != nil {
+		if defaultLevel, err = logging.ParseLevel(fmt.Sprint(update.Default)); err != nil {
+			respondWithError(ctx, newValidationError("%v", err))
+			return
+		}
+	}
+	moduleLevels := map[string]uint{}
+	for module, value := range update.Modules {
+		if value == nil {
+			continue
+		}
+		if moduleLevels[module], err = logging.ParseLevel(fmt.Sprint(value)); err != nil {
+			respondWithError(ctx, newValidationError("module %s: %v", module, err))
+			return
+		}
+	}
+
+	// Apply the changes.
+	if update.Default != nil {
+		logging.SetLevel(defaultLevel)
+	}
+	for module, value := range update.Modules {
+		if value == nil {
+			logging.ClearModuleLevel(module)
+		} else {
+			logging.SetModuleLevel(module, moduleLevels[module])
+		}
+	}
+	logging.Warn(ctx.Request.Context(), "Log levels changed: %+v",
+		logging.GetLevels())
+
+	ctx.Set("response", logging.GetLevels())
+}
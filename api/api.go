@@ -1,7 +1,7 @@
 package api
 
 import (
-	"fmt"
+	"errors"
 	"main/api/middleware"
 	"main/config"
 	"main/global"
@@ -17,17 +17,29 @@ var router *gin.Engine
 var root *gin.RouterGroup
 var once sync.Once
 
-// respondWithErrorMessage responds to the request with the provided error message.
-func respondWithErrorMessage(ctx *gin.Context, status int,
-	format string, args ...interface{}) {
-
-	// Compose error message for logging and response.
-	message := fmt.Sprintf(format, args...)
+// respondWithError responds to the request with the provided error. Errors
+// other than *APIError are reported as internal errors.
+func respondWithError(ctx *gin.Context, err error) {
+	// Convert the error to an API error.
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = &APIError{
+			Status:  http.StatusInternalServerError,
+			Code:    ErrCodeInternal,
+			Message: "internal error",
+			Cause:   err,
+		}
+	}
 
-	// Log error message and respond to request.
-	logging.Error(ctx.Request.Context(), message)
-	ctx.AbortWithStatusJSON(status, gin.H{
-		"error":      message,
+	// Log the full error, but only respond with the public message.
+	if apiErr.Status >= http.StatusInternalServerError {
+		logging.Error(ctx.Request.Context(), apiErr.Error())
+	} else {
+		logging.Warn(ctx.Request.Context(), apiErr.Error())
+	}
+	ctx.AbortWithStatusJSON(apiErr.Status, gin.H{
+		"error":      apiErr.Message,
+		"code":       apiErr.Code,
 		"request_id": middleware.GetRequestID(ctx),
 	})
 }
@@ -85,6 +97,9 @@ func createRouterAndGroup(prefix string) (*gin.Engine, *gin.RouterGroup) {
 
 // installCommonMiddleware installs common middleware to the router group.
 func installCommonMiddleware(group *gin.RouterGroup) {
+	// Install tracing middleware, a middleware to start a span per request.
+	group.Use(middleware.Tracing())
+
 	// Install logger middleware, a middleware to log requests.
 	group.Use(middleware.Logger())
 
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"main/store/storetest"
+)
+
+// The token, and the holders transferring it in the tests.
+var (
+	testToken = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testAlice = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	testBob   = common.HexToAddress("0x3000000000000000000000000000000000000003")
+)
+
+// forEachBackend runs a test against the in-memory and the SQLite stores.
+func forEachBackend(t *testing.T, test func(t *testing.T)) {
+	for _, backend := range []struct {
+		name string
+		use  func(t testing.TB)
+	}{
+		{"memory", storetest.UseMemory},
+		{"sqlite", storetest.UseSQLite},
+	} {
+		t.Run(backend.name, func(t *testing.T) {
+			backend.use(t)
+			test(t)
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// serve serves a GET request of the path, and checks the response status.
+// The JSON response body is decoded into resp unless nil.
+func serve(t *testing.T, path string, status int, resp interface{}) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	GetRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if recorder.Code != status {
+		t.Fatalf("GET %s = %d %s, want %d", path, recorder.Code,
+			recorder.Body.String(), status)
+	}
+	if resp == nil {
+		return
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), resp); err != nil {
+		t.Fatalf("GET %s: decoding %s: %v", path, recorder.Body.String(), err)
+	}
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// serveError serves a GET request of the path, and checks the status and the
+// error code of the error response.
+func serveError(t *testing.T, path string, status int, code string) {
+	t.Helper()
+	var resp errorResponse
+	serve(t, path, status, &resp)
+	if resp.Code != code {
+		t.Errorf("GET %s error code = %s, want %s", path, resp.Code, code)
+	}
+}
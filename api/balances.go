@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"main/models"
+	"main/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Default and maximum number of token holders listed.
+const (
+	defaultTokenHoldersLimit = 20
+	maxTokenHoldersLimit     = 100
+)
+
+// TokenBalance is the balance of a holder of a token, with the symbol of the
+// token and the balance in whole tokens, both unknown until the metadata is
+// resolved. The balance only counts the transfers indexed from block
+// CountedFrom on, so it leaves out what the holder held before. The result of
+// the last spot check against balanceOf tells whether it's off.
+type TokenBalance struct {
+	Token            string
+	Holder           string
+	Symbol           *string
+	Balance          string
+	BalanceFormatted *string
+	CountedFrom      uint64
+	CheckedBlock     *uint64
+	OnchainBalance   *string
+	Mismatch         bool
+}
+
+// holding is a holder of a token.
+type holding struct{ token, holder string }
+
+// balanceAmount is a balance of a holder of a token as of a block, with the
+// current balance it was taken back from.
+type balanceAmount struct {
+	holding
+	amount  *big.Int
+	current models.BalanceIntf
+}
+
+// GetTokenHolders lists the holders of a token with the highest balances, the
+// highest first.
+func GetTokenHolders(ctx *gin.Context) {
+	// Get the address from URL path parameter, in the checksum form the
+	// indexer stores
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+	level, err := getConsistency(ctx)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	// Get the number of requested token holders
+	limit, err := getLimit(ctx, defaultTokenHoldersLimit, maxTokenHoldersLimit)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	// Only count the balance changes of blocks served at the consistency level
+	servedUpTo, err := getServedUpTo(ctx.Request.Context(), level)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "balances"))
+		return
+	}
+	amounts := []balanceAmount{}
+	if servedUpTo != nil {
+		amounts, err = getTopHolders(ctx.Request.Context(), address, limit, *servedUpTo)
+		if err != nil {
+			respondWithError(ctx, newDatabaseError(err, "balances"))
+			return
+		}
+	}
+	resp, err := newTokenBalances(ctx.Request.Context(), amounts)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "token"))
+		return
+	}
+
+	// Set results to context.
+	setConsistency(ctx, level)
+	ctx.Set("response", resp)
+}
+
+// GetTokenBalancesByAddress lists the tokens held by an address with their
+// balances, as of the block given by the "block" query parameter if any.
+func GetTokenBalancesByAddress(ctx *gin.Context) {
+	// Get the address from URL path parameter, in the checksum form the
+	// indexer stores
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+	level, err := getConsistency(ctx)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	// Get the block the balances are asked as of, if any
+	var block *uint64
+	if value := ctx.Query("block"); len(value) > 0 {
+		num, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondWithError(ctx, newValidationError("invalid block"))
+			return
+		}
+		block = &num
+	}
+
+	// Only count the balance changes of blocks served at the consistency
+	// level, up to the block asked
+	servedUpTo, err := getServedUpTo(ctx.Request.Context(), level)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "balances"))
+		return
+	}
+	if servedUpTo != nil && block != nil && *block < *servedUpTo {
+		servedUpTo = block
+	}
+	amounts := []balanceAmount{}
+	if servedUpTo != nil {
+		amounts, err = getHolderBalances(ctx.Request.Context(), address, *servedUpTo)
+		if err != nil {
+			respondWithError(ctx, newDatabaseError(err, "balances"))
+			return
+		}
+	}
+	resp, err := newTokenBalances(ctx.Request.Context(), amounts)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "token"))
+		return
+	}
+
+	// Set results to context.
+	setConsistency(ctx, level)
+	ctx.Set("response", resp)
+}
+
+// getTopHolders returns the n holders of a token with the highest positive
+// balances as of a block, the highest first. Only the holders whose balances
+// changed above the block can move into the top n, so the top n plus those
+// are read at their current balances, which are then taken back to the
+// block.
+func getTopHolders(ctx context.Context, token string, n, num uint64) ([]balanceAmount, error) {
+	changes, err := store.Balances.GetChangesAfter(ctx, token, "", num)
+	if err != nil {
+		return nil, err
+	}
+	deltas, err := sumChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := store.Balances.GetTopHolders(ctx, token, n+uint64(len(deltas)))
+	if err != nil {
+		return nil, err
+	}
+	listed := map[string]bool{}
+	for _, balance := range top {
+		listed[balance.GetHolder()] = true
+	}
+	changed := []string{}
+	for k := range deltas {
+		if !listed[k.holder] {
+			changed = append(changed, k.holder)
+		}
+	}
+	others, err := store.Balances.GetByToken(ctx, token, changed)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, err := balancesAt(append(top, others...), deltas)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(amounts, func(i, j int) bool {
+		if c := amounts[i].amount.Cmp(amounts[j].amount); c != 0 {
+			return c > 0
+		}
+		return amounts[i].holder < amounts[j].holder
+	})
+	if uint64(len(amounts)) > n {
+		amounts = amounts[:n]
+	}
+	return amounts, nil
+}
+
+// getHolderBalances returns the positive balances of a holder as of a block,
+// by token.
+func getHolderBalances(ctx context.Context, holder string, num uint64) ([]balanceAmount, error) {
+	balances, err := store.Balances.GetByHolder(ctx, holder)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := store.Balances.GetChangesAfter(ctx, "", holder, num)
+	if err != nil {
+		return nil, err
+	}
+	deltas, err := sumChanges(changes)
+	if err != nil {
+		return nil, err
+	}
+	return balancesAt(balances, deltas)
+}
+
+// sumChanges sums balance changes by token and holder.
+func sumChanges(changes []models.BalanceChangeIntf) (map[holding]*big.Int, error) {
+	deltas := map[holding]*big.Int{}
+	for _, change := range changes {
+		delta, ok := new(big.Int).SetString(change.GetDelta(), 10)
+		if !ok {
+			return nil, errors.New("invalid balance change " + change.GetDelta())
+		}
+		k := holding{change.GetToken(), change.GetHolder()}
+		if sum, ok := deltas[k]; ok {
+			delta.Add(delta, sum)
+		}
+		deltas[k] = delta
+	}
+	return deltas, nil
+}
+
+// balancesAt takes current balances back to a block by subtracting the sums
+// of the changes above it, and returns the positive ones.
+func balancesAt(balances []models.BalanceIntf,
+	deltas map[holding]*big.Int) ([]balanceAmount, error) {
+	amounts := []balanceAmount{}
+	for _, balance := range balances {
+		amount, ok := new(big.Int).SetString(balance.GetBalance(), 10)
+		if !ok {
+			return nil, errors.New("invalid balance " + balance.GetBalance())
+		}
+		k := holding{balance.GetToken(), balance.GetHolder()}
+		if delta, ok := deltas[k]; ok {
+			amount.Sub(amount, delta)
+		}
+		if amount.Sign() > 0 {
+			amounts = append(amounts, balanceAmount{k, amount, balance})
+		}
+	}
+	return amounts, nil
+}
+
+// newTokenBalances returns the balances with the symbols of their tokens and
+// in whole tokens, looking each token up once, and with the block the
+// transfers are counted from.
+func newTokenBalances(ctx context.Context, amounts []balanceAmount) ([]TokenBalance, error) {
+	resp := []TokenBalance{}
+	if len(amounts) == 0 {
+		return resp, nil
+	}
+	lowest, err := store.Blocks.GetLowest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := map[string]models.TokenIntf{}
+	for _, a := range amounts {
+		token, ok := tokens[a.token]
+		if !ok {
+			var err error
+			token, err = store.Tokens.GetByAddress(ctx, a.token)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return nil, err
+			}
+			tokens[a.token] = token
+		}
+
+		item := TokenBalance{
+			Token:          a.token,
+			Holder:         a.holder,
+			Balance:        a.amount.String(),
+			CountedFrom:    lowest.GetNumber(),
+			CheckedBlock:   a.current.GetCheckedBlock(),
+			OnchainBalance: a.current.GetOnchainBalance(),
+			Mismatch:       a.current.GetMismatch(),
+		}
+		if token != nil {
+			item.Symbol = token.GetSymbol()
+			if decimals := token.GetDecimals(); decimals != nil {
+				if balance, ok := models.FormatUnits(item.Balance, *decimals); ok {
+					item.BalanceFormatted = &balance
+				}
+			}
+		}
+		resp = append(resp, item)
+	}
+	return resp, nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/store"
+	"main/store/storetest"
+)
+
+func TestGetTokenHolders(t *testing.T) {
+	storetest.UseMemory(t)
+	carol := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	block := storetest.NewBlock(1, common.Hash{}, "")
+	block.AddTransfer(t, storetest.Tx(t, 0), 0, testToken, testAlice, testBob, 10)
+	block.AddTransfer(t, storetest.Tx(t, 1), 1, testToken, testAlice, carol, 30)
+	block.AddTransfer(t, storetest.Tx(t, 2), 2, testToken, carol, testBob, 5)
+	block.Commit(t)
+
+	var holders []TokenBalance
+	path := "/tokens/" + testToken.String() + "/holders"
+	serve(t, path, http.StatusOK, &holders)
+	if len(holders) != 2 ||
+		holders[0].Holder != carol.String() || holders[0].Balance != "25" ||
+		holders[1].Holder != testBob.String() || holders[1].Balance != "15" {
+		t.Errorf("holders = %+v, want carol with 25 and bob with 15", holders)
+	}
+	for _, holder := range holders {
+		if holder.CountedFrom != 1 || holder.CheckedBlock != nil || holder.Mismatch {
+			t.Errorf("holder = %+v, want counted from 1 and unchecked", holder)
+		}
+	}
+
+	// the spot check of a balance is returned with it
+	balances, err := store.Balances.GetByToken(context.Background(),
+		testToken.String(), []string{testBob.String()})
+	if err != nil || len(balances) != 1 {
+		t.Fatalf("GetByToken = %v, %v", balances, err)
+	}
+	onchain := "20"
+	balances[0].SetChecked(1, 1, &onchain, true)
+	if err := store.Balances.UpdateCheck(context.Background(), balances[0]); err != nil {
+		t.Fatalf("UpdateCheck: %v", err)
+	}
+	serve(t, path, http.StatusOK, &holders)
+	if len(holders) != 2 || !holders[1].Mismatch || holders[1].OnchainBalance == nil ||
+		*holders[1].OnchainBalance != onchain {
+		t.Errorf("holders = %+v, want bob with a mismatch against %s", holders, onchain)
+	}
+
+	serve(t, path+"?limit=1", http.StatusOK, &holders)
+	if len(holders) != 1 || holders[0].Holder != carol.String() {
+		t.Errorf("holders = %+v, want carol only", holders)
+	}
+
+	serveError(t, "/tokens/0x1234/holders", http.StatusBadRequest, ErrCodeInvalidParameter)
+	serveError(t, path+"?limit=-1", http.StatusBadRequest, ErrCodeInvalidParameter)
+}
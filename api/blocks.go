@@ -1,13 +1,15 @@
 package api
 
 import (
-	"net/http"
+	"context"
+	"errors"
 	"strconv"
 
 	"main/api/middleware"
 	"main/config"
-	"main/database"
+	"main/eth_index"
 	"main/models"
+	"main/store"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,19 +32,35 @@ func GetBlocks(ctx *gin.Context) {
 	// Get the number of requested blocks
 	num, err := strconv.ParseUint(ctx.Query("limit"), 10, 64)
 	if err != nil {
-		respondWithErrorMessage(ctx, http.StatusBadRequest, "invalid limit")
+		respondWithError(ctx, newValidationError("invalid limit"))
 		return
 	}
 	if num > config.GetUint64("API_MAX_BLOCK_REQ") {
-		respondWithErrorMessage(ctx, http.StatusBadRequest, "require too many blocks")
+		respondWithError(ctx, newValidationError(
+			"limit must not exceed %d", config.GetUint64("API_MAX_BLOCK_REQ")))
+		return
+	}
+	level, err := getConsistency(ctx)
+	if err != nil {
+		respondWithError(ctx, err)
 		return
 	}
 
-	// Get latest N blocks
-	db := database.GetSQL()
-	blocks, err := models.Block.GetBlocks(db, num)
+	// Get latest N completely indexed blocks at the consistency level
+	served, err := getServedUpTo(ctx.Request.Context(), level)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "blocks"))
+		return
+	}
+	setConsistency(ctx, level)
+	if served == nil {
+		ctx.Set("response", []models.BlockIntf{})
+		return
+	}
+	// Blocks below the served height not marked as final yet are left out
+	blocks, err := store.Blocks.GetBlocks(ctx.Request.Context(), num, *served, level)
 	if err != nil {
-		respondWithErrorMessage(ctx, http.StatusInternalServerError, err.Error())
+		respondWithError(ctx, newDatabaseError(err, "blocks"))
 		return
 	}
 
@@ -50,27 +68,64 @@ func GetBlocks(ctx *gin.Context) {
 	ctx.Set("response", blocks)
 }
 
-// GetBlockByNumber ...
+// GetBlockByNumber gets a canonical block by number or hash. Blocks orphaned
+// by a reorg are only found by hash, with include_orphaned=true.
 func GetBlockByNumber(ctx *gin.Context) {
-	// Get Block Number from URL path parameter.
-	num, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	level, err := getConsistency(ctx)
 	if err != nil {
-		respondWithErrorMessage(ctx, http.StatusBadRequest, "invalid block ID")
+		respondWithError(ctx, err)
 		return
 	}
+	includeOrphaned := false
+	if value := ctx.Query("include_orphaned"); len(value) > 0 {
+		if includeOrphaned, err = strconv.ParseBool(value); err != nil {
+			respondWithError(ctx, newValidationError("invalid include_orphaned"))
+			return
+		}
+	}
 
-	// Get the block by given block number
-	db := database.GetSQL()
-	block, err := models.Block.GetByNumber(db, num)
+	// Get the block by given block hash, or block number from URL path
+	// parameter.
+	var block models.BlockIntf
+	if id := ctx.Param("id"); isHexHash(id) {
+		block, err = store.Blocks.GetByHash(ctx.Request.Context(), id)
+	} else if num, parseErr := strconv.ParseUint(id, 10, 64); parseErr == nil {
+		block, err = store.Blocks.GetByNumber(ctx.Request.Context(), num)
+	} else {
+		respondWithError(ctx, newValidationError("invalid block ID"))
+		return
+	}
 	if err != nil {
-		respondWithErrorMessage(ctx, http.StatusInternalServerError, err.Error())
+		respondWithError(ctx, newDatabaseError(err, "block"))
+		return
+	}
+
+	// Serve canonical blocks if completely indexed, and orphans if asked
+	// for, at least as final as the consistency level
+	if block.GetCanonical() {
+		indexed, err := getIndexedUpTo(ctx.Request.Context())
+		if err != nil {
+			respondWithError(ctx, newDatabaseError(err, "block"))
+			return
+		}
+		if indexed == nil || block.GetNumber() > *indexed {
+			respondWithError(ctx, newNotFoundError("block not found"))
+			return
+		}
+	} else if !includeOrphaned {
+		respondWithError(ctx, newNotFoundError("block not found"))
+		return
+	}
+	if !block.GetStatus().AtLeast(level) {
+		respondWithError(ctx, newNotFoundError("block not found"))
 		return
 	}
 
 	// Get transactions in the block
-	transactions, err := models.Transaction.GetByBlockHash(db, block.GetHash())
+	transactions, err := store.Txs.GetByBlockHash(
+		ctx.Request.Context(), block.GetHash())
 	if err != nil {
-		respondWithErrorMessage(ctx, http.StatusInternalServerError, err.Error())
+		respondWithError(ctx, newDatabaseError(err, "transactions"))
 		return
 	}
 
@@ -82,5 +137,19 @@ func GetBlockByNumber(ctx *gin.Context) {
 	resp := BlockWithTransaction{Block: block, Transactions: txHashes}
 
 	// Set results to context.
+	setConsistency(ctx, level)
 	ctx.Set("response", resp)
 }
+
+// getIndexedUpTo returns the indexed watermark, or nil if nothing is indexed
+// yet. Blocks above the watermark may be partially indexed, or follow a gap,
+// and aren't served.
+func getIndexedUpTo(ctx context.Context) (*uint64, error) {
+	indexed, err := eth_index.GetWatermark(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &indexed, nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/store"
+	"main/store/storetest"
+)
+
+// blockResponse is the part of a block in a response the tests check.
+type blockResponse struct {
+	Number    uint64 `json:"block_num"`
+	Hash      string `json:"block_hash"`
+	Canonical bool   `json:"canonical"`
+}
+
+func TestGetBlocks(t *testing.T) {
+	storetest.UseMemory(t)
+	var blocks []blockResponse
+	serve(t, "/blocks?limit=2", http.StatusOK, &blocks)
+	if len(blocks) != 0 {
+		t.Errorf("got %d blocks before indexing, want none", len(blocks))
+	}
+
+	parent := common.Hash{}
+	for num := uint64(1); num <= 3; num++ {
+		block := storetest.NewBlock(num, parent, "")
+		block.Commit(t)
+		parent = block.Hash()
+	}
+	serve(t, "/blocks?limit=2", http.StatusOK, &blocks)
+	if len(blocks) != 2 || blocks[0].Number != 3 || blocks[1].Number != 2 {
+		t.Errorf("blocks = %+v, want 3 and 2", blocks)
+	}
+
+	serveError(t, "/blocks", http.StatusBadRequest, ErrCodeInvalidParameter)
+	serveError(t, "/blocks?limit=ten", http.StatusBadRequest, ErrCodeInvalidParameter)
+	serveError(t, "/blocks?limit=2&consistency=eventual",
+		http.StatusBadRequest, ErrCodeInvalidParameter)
+}
+
+func TestGetBlockByNumber(t *testing.T) {
+	storetest.UseMemory(t)
+	old := storetest.NewBlock(1, common.Hash{}, "old")
+	old.AddTransfer(t, storetest.Tx(t, 0), 0, testToken, testAlice, testBob, 10)
+	old.Commit(t)
+	replacing := storetest.NewBlock(1, common.Hash{}, "new")
+	replacing.Commit(t)
+
+	var resp struct {
+		Block        blockResponse
+		Transactions []string
+	}
+	serve(t, "/blocks/1", http.StatusOK, &resp)
+	if resp.Block.Hash != replacing.Hash().String() || len(resp.Transactions) != 0 {
+		t.Errorf("block = %+v, want %s without transactions", resp, replacing.Hash())
+	}
+
+	// orphans are only served when asked for
+	serveError(t, "/blocks/"+old.Hash().String(), http.StatusNotFound, ErrCodeNotFound)
+	serve(t, "/blocks/"+old.Hash().String()+"?include_orphaned=true",
+		http.StatusOK, &resp)
+	if resp.Block.Canonical || len(resp.Transactions) != 1 {
+		t.Errorf("orphan = %+v, want non-canonical with 1 transaction", resp)
+	}
+
+	// blocks above the indexed watermark aren't served
+	above := storetest.NewBlock(2, replacing.Hash(), "")
+	if err := store.Blocks.CommitBlock(context.Background(), above.IndexedBlock); err != nil {
+		t.Fatalf("CommitBlock: %v", err)
+	}
+	serveError(t, "/blocks/2", http.StatusNotFound, ErrCodeNotFound)
+	serveError(t, "/blocks/one", http.StatusBadRequest, ErrCodeInvalidParameter)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+
+	"main/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// headerConsistency is the response header stating the consistency level of
+// the data returned.
+const headerConsistency = "X-Consistency"
+
+// getConsistency returns the consistency level asked by the "consistency"
+// query parameter: the least final block status served. It defaults to
+// latest.
+func getConsistency(ctx *gin.Context) (models.BlockStatus, error) {
+	name := ctx.Query("consistency")
+	if len(name) <= 0 {
+		return models.StatusLatest, nil
+	}
+	level, ok := models.ParseBlockStatus(name)
+	if !ok {
+		return "", newValidationError(
+			"consistency must be one of latest, safe or finalized")
+	}
+	return level, nil
+}
+
+// setConsistency states the consistency level of the response.
+func setConsistency(ctx *gin.Context, level models.BlockStatus) {
+	ctx.Header(headerConsistency, string(level))
+}
+
+// getServedUpTo returns the highest block number served at the consistency
+// level, or nil if no block is served. Blocks are served up to the indexed
+// watermark and, above latest, up to the highest block of the level.
+func getServedUpTo(ctx context.Context, level models.BlockStatus) (*uint64, error) {
+	indexed, err := getIndexedUpTo(ctx)
+	if err != nil || indexed == nil || level == models.StatusLatest {
+		return indexed, err
+	}
+	highest, err := getHighestNumber(ctx, level)
+	if err != nil || highest == nil {
+		return nil, err
+	}
+	if *highest < *indexed {
+		return highest, nil
+	}
+	return indexed, nil
+}
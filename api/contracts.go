@@ -0,0 +1,78 @@
+package api
+
+import (
+	"main/api/middleware"
+	"main/models"
+	"main/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+type ContractWithCode struct {
+	Contract models.ContractIntf
+	Code     models.ContractCodeIntf
+}
+
+func init() {
+	// Setup contracts router group.
+	root := GetRoot().Group("contracts",
+		middleware.FormatResponse())
+	root.GET("/:address", GetContract)
+}
+
+// GetContract returns the latest deployment of a contract with its bytecode.
+func GetContract(ctx *gin.Context) {
+	// Get the address from URL path parameter, in the checksum form the
+	// indexer stores
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+	level, err := getConsistency(ctx)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	contract, err := store.Contracts.GetByAddress(ctx.Request.Context(), address)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "contract"))
+		return
+	}
+
+	// Serve the contract once the block deploying it is completely indexed
+	// and at least as final as the consistency level
+	block, err := store.Blocks.GetByHash(ctx.Request.Context(), contract.GetBlockHash())
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "contract"))
+		return
+	}
+	indexed, err := getIndexedUpTo(ctx.Request.Context())
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "contract"))
+		return
+	}
+	if indexed == nil || block.GetNumber() > *indexed || !block.GetCanonical() ||
+		!block.GetStatus().AtLeast(level) {
+		respondWithError(ctx, newNotFoundError("contract not found"))
+		return
+	}
+
+	code, err := store.Contracts.GetCode(ctx.Request.Context(), contract.GetCodeHash())
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "contract code"))
+		return
+	}
+
+	resp := ContractWithCode{
+		Contract: contract,
+		Code:     code,
+	}
+
+	// Set results to context.
+	setConsistency(ctx, level)
+	ctx.Set("response", resp)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"main/store"
+
+	"github.com/lib/pq"
+)
+
+// Stable machine-readable error codes returned in the "code" response field.
+const (
+	ErrCodeInvalidParameter    = "INVALID_PARAMETER"
+	ErrCodeNotFound            = "NOT_FOUND"
+	ErrCodeUnauthorized        = "UNAUTHORIZED"
+	ErrCodeForbidden           = "FORBIDDEN"
+	ErrCodeDatabaseUnavailable = "DATABASE_UNAVAILABLE"
+	ErrCodeDatabaseError       = "DATABASE_ERROR"
+	ErrCodeInternal            = "INTERNAL_ERROR"
+)
+
+// APIError is an error with an HTTP status and a stable error code attached.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Cause   error
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Cause)
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *APIError) Unwrap() error {
+	return e.Cause
+}
+
+// newValidationError returns an error for an invalid request parameter.
+func newValidationError(format string, args ...interface{}) *APIError {
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    ErrCodeInvalidParameter,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// newNotFoundError returns an error for a missing resource.
+func newNotFoundError(format string, args ...interface{}) *APIError {
+	return &APIError{
+		Status:  http.StatusNotFound,
+		Code:    ErrCodeNotFound,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// newDatabaseError maps a database error to an API error. Missing records map
+// to 404, connection problems to 503 and everything else to 500. The resource
+// describes what was looked up and is used for the not found message.
+func newDatabaseError(err error, resource string) *APIError {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return newNotFoundError("%s not found", resource)
+	case isUnavailableError(err):
+		return &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    ErrCodeDatabaseUnavailable,
+			Message: "database unavailable",
+			Cause:   err,
+		}
+	default:
+		return &APIError{
+			Status:  http.StatusInternalServerError,
+			Code:    ErrCodeDatabaseError,
+			Message: "database error",
+			Cause:   err,
+		}
+	}
+}
+
+// isUnavailableError reports whether the error indicates that the database
+// cannot be reached rather than that the query itself failed.
+func isUnavailableError(err error) bool {
+	// Connection level errors from database/sql and the network stack.
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// PostgreSQL connection exception, insufficient resources and operator
+	// intervention error classes.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", "53", "57":
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"main/global"
+	"main/leader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health is the health probe response.
+type Health struct {
+	Alive  bool          `json:"alive"`
+	Ready  bool          `json:"ready"`
+	Leader leader.Status `json:"leader"`
+}
+
+func init() {
+	// Setup health probe routes. Probes respond with 503 when failing, so
+	// they don't use the response formatting middleware.
+	root := GetRoot()
+	root.GET("alive", GetAlive)
+	root.GET("ready", GetReady)
+}
+
+// GetAlive is the liveness probe. It fails once the service is shutting down.
+func GetAlive(ctx *gin.Context) {
+	respondWithHealth(ctx, global.Alive)
+}
+
+// GetReady is the readiness probe. It succeeds once the service is serving
+// requests, on leader and standby instances alike.
+func GetReady(ctx *gin.Context) {
+	respondWithHealth(ctx, global.Ready && global.Alive)
+}
+
+// respondWithHealth responds with the health state and a status code
+// depending on whether the probe passed.
+func respondWithHealth(ctx *gin.Context, ok bool) {
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, Health{
+		Alive:  global.Alive,
+		Ready:  global.Ready,
+		Leader: leader.GetStatus(),
+	})
+}
@@ -14,6 +14,11 @@ func FormatResponse() gin.HandlerFunc {
 		// Onto the next handler if we're not final.
 		ctx.Next()
 
+		// Aborted requests have already been responded to.
+		if ctx.IsAborted() {
+			return
+		}
+
 		// Get response format.
 		format := ctx.Query("format")
 		if len(format) <= 0 {
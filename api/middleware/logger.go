@@ -1,18 +1,24 @@
 package middleware
 
 import (
-	"context"
-	"encoding/binary"
 	"encoding/json"
-	"fmt"
-	"hash/fnv"
 	"main/logging"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Request tracing headers.
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceParent = "traceparent"
+)
+
+// maxRequestIDLength is the maximum length of a client supplied request ID.
+const maxRequestIDLength = 128
+
 // blacklist is a list of request URLs that we should ignore from logging.
 var blacklist = map[string]bool{
 	"/alive": false,
@@ -30,10 +36,11 @@ func Logger() gin.HandlerFunc {
 		}
 
 		// Inject request ID into context.Context of *http.Request of *gin.Context
-		requestID := generateRequestID(ctx.Request)
-		ctxWithRequestID := context.WithValue(ctx.Request.Context(),
-			logging.ContextKeyRequestID, requestID)
+		// and echo it back to the client.
+		requestID := resolveRequestID(ctx.Request)
+		ctxWithRequestID := logging.WithRequestID(ctx.Request.Context(), requestID)
 		ctx.Request = ctx.Request.WithContext(ctxWithRequestID)
+		ctx.Header(HeaderRequestID, requestID)
 
 		// Collect relevant information from this request to be logged.
 		address := ctx.ClientIP()
@@ -77,7 +84,7 @@ func Logger() gin.HandlerFunc {
 // GetRequestID returns the request ID associated with the current request.
 func GetRequestID(ctx *gin.Context) string {
 	// Lookup the request logger.
-	requestID, ok := ctx.Request.Context().Value(logging.ContextKeyUserID).(string)
+	requestID, ok := ctx.Request.Context().Value(logging.ContextKeyRequestID).(string)
 	if !ok {
 		logging.Error(ctx.Request.Context(), "Failed to lookup request ID")
 		return ""
@@ -86,20 +93,48 @@ func GetRequestID(ctx *gin.Context) string {
 	return requestID
 }
 
-func generateRequestID(request *http.Request) string {
-	// Generate hash object.
-	hash := fnv.New64a()
+// resolveRequestID returns the request ID supplied by the client through the
+// X-Request-ID header or the trace ID of a W3C traceparent header. A new ID is
+// generated if neither is present or valid.
+func resolveRequestID(request *http.Request) string {
+	// Prefer an explicit request ID.
+	if id := request.Header.Get(HeaderRequestID); isValidRequestID(id) {
+		return id
+	}
+
+	// Fall back to the trace ID of the W3C trace context, which has the form
+	// "version-traceid-parentid-flags".
+	if parts := strings.Split(request.Header.Get(HeaderTraceParent), "-"); len(parts) == 4 {
+		traceID := strings.ToLower(parts[1])
+		if len(traceID) == 32 && isHex(traceID) &&
+			traceID != strings.Repeat("0", 32) {
+			return traceID
+		}
+	}
 
-	// Use time as hash component.
-	currentTimeBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(currentTimeBytes,
-		uint64(time.Now().UnixNano()))
+	return logging.NewRequestID()
+}
 
-	// Compute hash value.
-	hash.Write([]byte(request.Host))
-	hash.Write([]byte(request.RemoteAddr))
-	hash.Write([]byte(request.RequestURI))
-	hash.Write(currentTimeBytes)
+// isValidRequestID reports whether a client supplied request ID is safe to
+// log and echo back.
+func isValidRequestID(id string) bool {
+	if len(id) <= 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
 
-	return fmt.Sprintf("%012x", hash.Sum64())[:12]
+// isHex reports whether s consists of hex digits only.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/logging"
+	"main/tracing"
+)
+
+// Tracing returns a middleware which starts a server span for each request,
+// continuing the trace of an incoming W3C traceparent header if present.
+func Tracing() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Extract the remote span context from the request headers.
+		propagator := otel.GetTextMapPropagator()
+		reqCtx := propagator.Extract(ctx.Request.Context(),
+			propagation.HeaderCarrier(ctx.Request.Header))
+
+		// Name the span after the route template to keep cardinality low.
+		route := ctx.FullPath()
+		if len(route) <= 0 {
+			route = "unmatched"
+		}
+		reqCtx, span := tracing.StartWithKind(reqCtx,
+			fmt.Sprintf("%s %s", ctx.Request.Method, route), trace.SpanKindServer,
+			attribute.String("http.request.method", ctx.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", ctx.Request.URL.Path),
+			attribute.String("client.address", ctx.ClientIP()))
+		defer span.End()
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		// Continue processing request chain.
+		ctx.Next()
+
+		// Record the request ID assigned by the logger and the response code.
+		code := ctx.Writer.Status()
+		span.SetAttributes(
+			attribute.String("request_id",
+				logging.GetRequestID(ctx.Request.Context())),
+			attribute.Int("http.response.status_code", code))
+		if code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(code))
+		}
+	}
+}
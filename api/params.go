@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isHexHash reports whether s is a 0x-prefixed 32-byte hex string.
+func isHexHash(s string) bool {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return false
+	}
+	if len(s) != 66 {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// isHexAddress reports whether s is a 0x-prefixed 20-byte hex string.
+func isHexAddress(s string) bool {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return false
+	}
+	if len(s) != 42 {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// getLimit returns the limit query parameter of a listing, or the default if
+// unset. It must not exceed the maximum.
+func getLimit(ctx *gin.Context, defaultLimit, maxLimit uint64) (uint64, error) {
+	limit := defaultLimit
+	if value := ctx.Query("limit"); len(value) > 0 {
+		var err error
+		if limit, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return 0, newValidationError("invalid limit")
+		}
+	}
+	if limit > maxLimit {
+		return 0, newValidationError("limit must not exceed %d", maxLimit)
+	}
+	return limit, nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"strconv"
+
+	"main/api/middleware"
+	"main/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default and maximum number of reorgs listed.
+const (
+	defaultReorgsLimit = 20
+	maxReorgsLimit     = 100
+)
+
+func init() {
+	// Setup reorgs router group.
+	root := GetRoot().Group("reorgs",
+		middleware.FormatResponse())
+	root.GET("", GetReorgs)
+}
+
+// GetReorgs lists the latest chain reorganizations seen by the indexer, the
+// most recent first.
+func GetReorgs(ctx *gin.Context) {
+	// Get the number of requested reorgs
+	limit := uint64(defaultReorgsLimit)
+	if value := ctx.Query("limit"); len(value) > 0 {
+		var err error
+		if limit, err = strconv.ParseUint(value, 10, 64); err != nil {
+			respondWithError(ctx, newValidationError("invalid limit"))
+			return
+		}
+	}
+	if limit > maxReorgsLimit {
+		respondWithError(ctx, newValidationError(
+			"limit must not exceed %d", maxReorgsLimit))
+		return
+	}
+
+	reorgs, err := store.Reorgs.GetLatest(ctx.Request.Context(), limit)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "reorgs"))
+		return
+	}
+
+	// Set results to context.
+	ctx.Set("response", reorgs)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"main/api/middleware"
+	"main/eth_index"
+	"main/leader"
+	"main/models"
+	"main/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReportedGaps is the maximum number of gaps listed in the status report.
+const maxReportedGaps = 20
+
+// SyncStatus is the indexer sync status report.
+type SyncStatus struct {
+	ChainHead      uint64                     `json:"chain_head"`
+	HighestIndexed *uint64                    `json:"highest_indexed"`
+	HighestSafe    *uint64                    `json:"highest_safe"`
+	HighestFinal   *uint64                    `json:"highest_finalized"`
+	LowestIndexed  *uint64                    `json:"lowest_indexed"`
+	IndexedUpTo    *uint64                    `json:"indexed_up_to"`
+	Lag            *uint64                    `json:"lag"`
+	Gaps           []models.BlockGap          `json:"gaps"`
+	Finality       eth_index.Finality         `json:"finality"`
+	Backfill       eth_index.BackfillProgress `json:"backfill"`
+	LastReorg      *eth_index.ReorgInfo       `json:"last_reorg"`
+	ActiveEndpoint string                     `json:"active_endpoint"`
+	Leader         leader.Status              `json:"leader"`
+}
+
+func init() {
+	// Setup status router group.
+	root := GetRoot().Group("status",
+		middleware.FormatResponse())
+	root.GET("", GetSyncStatus)
+}
+
+// GetSyncStatus reports how far the indexed data lags behind the chain.
+func GetSyncStatus(ctx *gin.Context) {
+	reqCtx := ctx.Request.Context()
+
+	// Get the indexer state recorded by the indexing instance.
+	indexer, err := eth_index.GetStatus(reqCtx)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "indexer_status"))
+		return
+	}
+	finality, err := eth_index.GetFinality(reqCtx)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "indexer_status"))
+		return
+	}
+	status := SyncStatus{
+		ChainHead:      indexer.ChainHead,
+		Finality:       finality,
+		Backfill:       indexer.Backfill,
+		LastReorg:      indexer.LastReorg,
+		ActiveEndpoint: indexer.ActiveEndpoint,
+		Leader:         leader.GetStatus(),
+	}
+
+	// Get the highest indexed block.
+	highest, err := store.Blocks.GetHighest(reqCtx, models.StatusLatest)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondWithError(ctx, newDatabaseError(err, "blocks"))
+		return
+	}
+	if highest != nil {
+		num := highest.GetNumber()
+		status.HighestIndexed = &num
+		if status.ChainHead >= num {
+			lag := status.ChainHead - num
+			status.Lag = &lag
+		}
+	}
+
+	// Get the highest safe and finalized blocks.
+	status.HighestSafe, err = getHighestNumber(reqCtx, models.StatusSafe)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "blocks"))
+		return
+	}
+	status.HighestFinal, err = getHighestNumber(reqCtx, models.StatusFinalized)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "blocks"))
+		return
+	}
+
+	// Get the lowest indexed block.
+	lowest, err := store.Blocks.GetLowest(reqCtx)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondWithError(ctx, newDatabaseError(err, "blocks"))
+		return
+	}
+	if lowest != nil {
+		num := lowest.GetNumber()
+		status.LowestIndexed = &num
+	}
+
+	// Get the height every block up to which is indexed.
+	status.IndexedUpTo, err = getIndexedUpTo(reqCtx)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "watermark"))
+		return
+	}
+
+	// Get known gaps between indexed blocks.
+	status.Gaps, err = store.Blocks.GetGaps(reqCtx, maxReportedGaps)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "blocks"))
+		return
+	}
+
+	// Set results to context.
+	ctx.Set("response", status)
+}
+
+// getHighestNumber returns the number of the highest block at least as final
+// as the status, or nil if there is none.
+func getHighestNumber(ctx context.Context, status models.BlockStatus) (*uint64, error) {
+	block, err := store.Blocks.GetHighest(ctx, status)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	num := block.GetNumber()
+	return &num, nil
+}
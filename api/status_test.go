@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/models"
+	"main/store"
+	"main/store/storetest"
+)
+
+func TestGetSyncStatus(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		ctx := context.Background()
+		var status SyncStatus
+		serve(t, "/status", http.StatusOK, &status)
+		if status.ChainHead != 0 || status.LastReorg != nil || status.Finality.Safe != nil {
+			t.Errorf("status = %+v before indexing, want none", status)
+		}
+
+		// the state recorded by the indexing instance is reported
+		old := storetest.NewBlock(1, common.Hash{}, "old")
+		replacing := storetest.NewBlock(1, common.Hash{}, "new")
+		safe, finalized := uint64(2), uint64(1)
+		for _, err := range []error{
+			store.Indexer.SetChainHead(ctx, 3),
+			store.Indexer.SetActiveEndpoint(ctx, "https://node"),
+			store.Indexer.SetFinality(ctx, "tags", &safe, &finalized),
+			store.Reorgs.SetReorg(ctx, models.NewReorg(0, old.Block, replacing.Block)),
+		} {
+			if err != nil {
+				t.Fatalf("recording the indexer state: %v", err)
+			}
+		}
+		serve(t, "/status", http.StatusOK, &status)
+		if status.ChainHead != 3 || status.ActiveEndpoint != "https://node" {
+			t.Errorf("chain head = %d at %s, want 3 at https://node",
+				status.ChainHead, status.ActiveEndpoint)
+		}
+		if status.Finality.Safe == nil || *status.Finality.Safe != safe {
+			t.Errorf("finality = %+v, want safe %d", status.Finality, safe)
+		}
+		if status.LastReorg == nil || status.LastReorg.NewHash != replacing.Hash().String() {
+			t.Errorf("last reorg = %+v, want to %s", status.LastReorg, replacing.Hash())
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"main/api/middleware"
+	"main/models"
+	"main/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Default and maximum number of token transfers listed.
+const (
+	defaultTokenTransfersLimit = 20
+	maxTokenTransfersLimit     = 100
+)
+
+// TokenTransferWithAmount is a token transfer with the symbol of the token and
+// the amount in whole tokens, both unknown until the metadata is resolved.
+type TokenTransferWithAmount struct {
+	Transfer        models.TokenTransferIntf
+	Symbol          *string
+	AmountFormatted *string
+}
+
+func init() {
+	// Setup tokens router group.
+	root := GetRoot().Group("tokens",
+		middleware.FormatResponse())
+	root.GET("/:address", GetToken)
+	root.GET("/:address/transfers", GetTokenTransfers)
+	root.GET("/:address/holders", GetTokenHolders)
+}
+
+// GetToken returns a token with its metadata.
+func GetToken(ctx *gin.Context) {
+	// Get the address from URL path parameter, in the checksum form the
+	// indexer stores
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+
+	token, err := store.Tokens.GetByAddress(ctx.Request.Context(), address)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "token"))
+		return
+	}
+
+	// Set results to context.
+	ctx.Set("response", token)
+}
+
+// GetTokenTransfers lists the latest transfers of a token, the most recent
+// first.
+func GetTokenTransfers(ctx *gin.Context) {
+	listTokenTransfers(ctx, store.Transfers.GetByToken)
+}
+
+// GetTokenTransfersByAddress lists the latest token transfers from or to an
+// address, the most recent first.
+func GetTokenTransfersByAddress(ctx *gin.Context) {
+	listTokenTransfers(ctx, store.Transfers.GetByAddress)
+}
+
+// listTokenTransfers lists the transfers returned by get for the address in
+// the URL path, with their amounts in whole tokens.
+func listTokenTransfers(ctx *gin.Context, get func(ctx context.Context,
+	address string, n, maxNumber uint64) ([]models.TokenTransferIntf, error)) {
+	// Get the address from URL path parameter, in the checksum form the
+	// indexer stores
+	address := ctx.Param("address")
+	if !isHexAddress(address) {
+		respondWithError(ctx, newValidationError("invalid address"))
+		return
+	}
+	address = common.HexToAddress(address).String()
+	level, err := getConsistency(ctx)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	// Get the number of requested token transfers
+	limit, err := getLimit(ctx, defaultTokenTransfersLimit, maxTokenTransfersLimit)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
+
+	// Only list token transfers of blocks served at the consistency level
+	servedUpTo, err := getServedUpTo(ctx.Request.Context(), level)
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "token transfers"))
+		return
+	}
+	transfers := []models.TokenTransferIntf{}
+	if servedUpTo != nil {
+		transfers, err = get(ctx.Request.Context(), address, limit, *servedUpTo)
+		if err != nil {
+			respondWithError(ctx, newDatabaseError(err, "token transfers"))
+			return
+		}
+	}
+
+	// Format the amounts with the decimals of the tokens, looking each token
+	// up once
+	tokens := map[string]models.TokenIntf{}
+	resp := []TokenTransferWithAmount{}
+	for _, transfer := range transfers {
+		token, ok := tokens[transfer.GetToken()]
+		if !ok {
+			token, err = store.Tokens.GetByAddress(ctx.Request.Context(), transfer.GetToken())
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				respondWithError(ctx, newDatabaseError(err, "token"))
+				return
+			}
+			tokens[transfer.GetToken()] = token
+		}
+
+		item := TokenTransferWithAmount{Transfer: transfer}
+		if token != nil {
+			item.Symbol = token.GetSymbol()
+			if decimals := token.GetDecimals(); decimals != nil {
+				if amount, ok := models.FormatUnits(transfer.GetAmount(), *decimals); ok {
+					item.AmountFormatted = &amount
+				}
+			}
+		}
+		resp = append(resp, item)
+	}
+
+	// Set results to context.
+	setConsistency(ctx, level)
+	ctx.Set("response", resp)
+}
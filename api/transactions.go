@@ -1,18 +1,22 @@
 package api
 
 import (
-	"net/http"
-
+	"main/abi_registry"
 	"main/api/middleware"
-	"main/database"
 	"main/models"
+	"main/store"
 
 	"github.com/gin-gonic/gin"
 )
 
 type TransactionWithLogs struct {
-	Transactoin models.TransactionIntf
-	Logs        []models.TransactionLogIntf
+	Transactoin          models.TransactionIntf
+	Logs                 []models.TransactionLogIntf
+	InternalTransactions []models.InternalTransactionIntf
+	// The decoded input data, and the decoded logs in the order of Logs,
+	// nil where they can't be decoded.
+	DecodedInput *abi_registry.Decoded
+	DecodedLogs  []*abi_registry.Decoded
 }
 
 func init() {
@@ -26,27 +30,74 @@ func init() {
 func GetByTxHash(ctx *gin.Context) {
 	// Get TxHash from URL path parameter.
 	txHash := ctx.Param("txHash")
+	if !isHexHash(txHash) {
+		respondWithError(ctx, newValidationError("invalid transaction hash"))
+		return
+	}
+	level, err := getConsistency(ctx)
+	if err != nil {
+		respondWithError(ctx, err)
+		return
+	}
 
 	// Get the trasaction by give txHash
-	db := database.GetSQL()
-	transaction, err := models.Transaction.GetByHash(db, txHash)
+	transaction, err := store.Txs.GetByHash(ctx.Request.Context(), txHash)
 	if err != nil {
-		respondWithErrorMessage(ctx, http.StatusInternalServerError, err.Error())
+		respondWithError(ctx, newDatabaseError(err, "transaction"))
+		return
+	}
+
+	// Serve the transaction once its block is completely indexed and at
+	// least as final as the consistency level, unless orphaned
+	block, err := store.Blocks.GetByHash(ctx.Request.Context(), transaction.GetBlockHash())
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "transaction"))
+		return
+	}
+	indexed, err := getIndexedUpTo(ctx.Request.Context())
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "transaction"))
+		return
+	}
+	if indexed == nil || block.GetNumber() > *indexed || !block.GetCanonical() ||
+		!block.GetStatus().AtLeast(level) {
+		respondWithError(ctx, newNotFoundError("transaction not found"))
 		return
 	}
 
 	// Get logs in the transaction receipt
-	logs, err := models.TransactionLog.GetByHash(db, transaction.GetTxHash())
+	logs, err := store.Logs.GetByTxHash(ctx.Request.Context(),
+		transaction.GetTxHash(), transaction.GetBlockHash())
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "transaction logs"))
+		return
+	}
+
+	// Get the internal transactions traced in the block of the transaction
+	internalTxs, err := store.InternalTxs.GetByTxHash(ctx.Request.Context(),
+		transaction.GetTxHash(), transaction.GetBlockHash())
+	if err != nil {
+		respondWithError(ctx, newDatabaseError(err, "internal transactions"))
+		return
+	}
+
+	// Decode the input data and the logs with the registered ABIs
+	input, events, err := abi_registry.DecodeTransaction(
+		ctx.Request.Context(), transaction, logs)
 	if err != nil {
-		respondWithErrorMessage(ctx, http.StatusInternalServerError, err.Error())
+		respondWithError(ctx, newDatabaseError(err, "abi"))
 		return
 	}
 
 	resp := TransactionWithLogs{
-		Transactoin: transaction,
-		Logs:        logs,
+		Transactoin:          transaction,
+		Logs:                 logs,
+		InternalTransactions: internalTxs,
+		DecodedInput:         input,
+		DecodedLogs:          events,
 	}
 
 	// Set results to context.
+	setConsistency(ctx, level)
 	ctx.Set("response", resp)
 }
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/store/storetest"
+)
+
+func TestGetByTxHash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		tx := storetest.Tx(t, 0)
+		path := "/transaction/" + tx.Hash().String()
+		serveError(t, path, http.StatusNotFound, ErrCodeNotFound)
+
+		// the transaction is re-mined at another position by a block replacing
+		// the one it was first mined in
+		old := storetest.NewBlock(1, common.Hash{}, "old")
+		old.AddTransfer(t, tx, 0, testToken, testAlice, testBob, 10)
+		old.Commit(t)
+		replacing := storetest.NewBlock(1, common.Hash{}, "new")
+		replacing.AddTransfer(t, storetest.Tx(t, 1), 0, testToken, testAlice, testBob, 5)
+		replacing.AddTransfer(t, tx, 1, testToken, testAlice, testBob, 10)
+		replacing.Commit(t)
+
+		var resp struct {
+			Transactoin struct {
+				TxHash string `json:"tx_hash"`
+			}
+			Logs []struct {
+				TxHash   string `json:"tx_hash"`
+				LogIndex int64  `json:"log_index"`
+			}
+		}
+		serve(t, path, http.StatusOK, &resp)
+		if resp.Transactoin.TxHash != tx.Hash().String() {
+			t.Errorf("transaction = %s, want %s", resp.Transactoin.TxHash, tx.Hash())
+		}
+		if len(resp.Logs) != 1 || resp.Logs[0].LogIndex != 1 {
+			t.Errorf("logs = %+v, want the log at index 1 only", resp.Logs)
+		}
+
+		serveError(t, "/transaction/0x1234", http.StatusBadRequest, ErrCodeInvalidParameter)
+		serveError(t, path+"?consistency=finalized", http.StatusNotFound, ErrCodeNotFound)
+	})
+}
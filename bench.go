@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"main/bench"
+	"main/config"
+	"main/database"
+	"main/lifecycle"
+)
+
+// runBench measures the rows per second written by the row-by-row and the
+// bulk write paths. Every path writes the same synthetic blocks in a
+// transaction that's rolled back, so nothing is left in the database.
+func runBench(args []string) error {
+	fs := newFlagSet("bench", "[flags]")
+	blocks := fs.Int("blocks", 10, "number of synthetic blocks")
+	txs := fs.Int("txs", 100, "number of transactions per block")
+	logs := fs.Int("logs", 3, "number of logs per transaction")
+	migrate := fs.Bool("migrate", config.GetBool("DATABASE_AUTO_MIGRATE"),
+		"apply pending migrations on startup")
+	fs.Parse(args)
+	if *blocks <= 0 || *txs < 0 || *logs < 0 {
+		fs.Usage()
+		return fmt.Errorf("invalid synthetic data size")
+	}
+
+	ctx, cancel := stoppingContext()
+	defer cancel()
+	setup(ctx, *migrate)
+	defer lifecycle.Shutdown()
+
+	data, err := bench.NewData(*blocks, *txs, *logs)
+	if err != nil {
+		return err
+	}
+
+	modes := []bench.Mode{
+		{Name: "row-by-row", Write: bench.WriteRowByRow},
+		{Name: "multi-row upsert", Write: bench.WriteMultiRow},
+	}
+	if database.SupportsCopy() {
+		modes = append(modes, bench.Mode{Name: "copy", Write: bench.WriteCopy})
+	}
+
+	fmt.Printf("%d blocks, %d rows per write path\n", *blocks, data.Rows())
+	for _, mode := range modes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		elapsed, err := bench.Time(database.GetSQLWithContext(ctx), mode, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", mode.Name, err)
+		}
+		fmt.Printf("%-18s %10v %12.0f rows/s\n", mode.Name,
+			elapsed.Round(time.Millisecond), float64(data.Rows())/elapsed.Seconds())
+	}
+
+	return nil
+}
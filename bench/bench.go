@@ -0,0 +1,167 @@
+// Package bench generates synthetic blocks and times the database write paths
+// on them.
+package bench
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jinzhu/gorm"
+
+	"main/models"
+)
+
+// blockBase is the number of the first synthetic block, far above any real
+// block.
+const blockBase = 1 << 62
+
+// Data is a synthetic set of blocks with their transactions, receipts
+// and logs.
+type Data struct {
+	blocks   []models.BlockIntf
+	txs      []models.TransactionIntf
+	receipts []models.ReceiptIntf
+	logs     []models.TransactionLogIntf
+}
+
+// Rows returns the number of rows written by a benchmarked write path.
+func (d *Data) Rows() int {
+	return len(d.txs) + len(d.receipts) + len(d.logs)
+}
+
+// Mode is a benchmarked write path.
+type Mode struct {
+	Name  string
+	Write func(db *gorm.DB, d *Data) error
+}
+
+// Time times a write path in a transaction that's rolled back.
+func Time(db *gorm.DB, mode Mode, data *Data) (time.Duration, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	defer tx.Rollback()
+
+	// the blocks are referenced by the rows written, and aren't timed
+	for _, block := range data.blocks {
+		if err := block.SetBlock(tx); err != nil {
+			return 0, err
+		}
+	}
+
+	start := time.Now()
+	if err := mode.Write(tx, data); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// WriteRowByRow writes each row with its own lookup and insert.
+func WriteRowByRow(db *gorm.DB, d *Data) error {
+	for _, t := range d.txs {
+		if err := t.SetTransaction(db); err != nil {
+			return err
+		}
+	}
+	for _, r := range d.receipts {
+		if err := r.SetReceipt(db); err != nil {
+			return err
+		}
+	}
+	for _, l := range d.logs {
+		if err := l.SetTransactionLog(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMultiRow writes the rows with multi-row upserts.
+func WriteMultiRow(db *gorm.DB, d *Data) error {
+	if err := models.Transaction.SetTransactions(db, d.txs); err != nil {
+		return err
+	}
+	if err := models.Receipt.SetReceipts(db, d.receipts); err != nil {
+		return err
+	}
+	return models.TransactionLog.SetTransactionLogs(db, d.logs)
+}
+
+// WriteCopy writes the rows with COPY.
+func WriteCopy(db *gorm.DB, d *Data) error {
+	if err := models.Transaction.CopyTransactions(db, d.txs); err != nil {
+		return err
+	}
+	if err := models.Receipt.CopyReceipts(db, d.receipts); err != nil {
+		return err
+	}
+	return models.TransactionLog.CopyTransactionLogs(db, d.logs)
+}
+
+// NewData generates blocks of signed transactions, each with a receipt
+// and logs.
+func NewData(blocks, txs, logs int) (*Data, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	data := &Data{}
+	nonce := uint64(0)
+	for i := 0; i < blocks; i++ {
+		block := types.NewBlockWithHeader(&types.Header{
+			Number: new(big.Int).SetUint64(blockBase + uint64(i)),
+			Time:   uint64(i),
+		})
+		blockHash := block.Hash().String()
+		data.blocks = append(data.blocks, models.NewBlock(block))
+
+		for j := 0; j < txs; j++ {
+			tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    nonce,
+				GasPrice: big.NewInt(1),
+				Gas:      21000,
+				To:       &common.Address{},
+				Value:    big.NewInt(1),
+				Data:     make([]byte, 68),
+			})
+			if err != nil {
+				return nil, err
+			}
+			nonce++
+
+			newTx, err := models.NewTransaction(tx, blockHash)
+			if err != nil {
+				return nil, err
+			}
+			data.txs = append(data.txs, newTx)
+
+			newReceipt, err := models.NewReceipt(&types.Receipt{
+				TxHash: tx.Hash(), BlockHash: block.Hash()})
+			if err != nil {
+				return nil, err
+			}
+			data.receipts = append(data.receipts, newReceipt)
+
+			for k := 0; k < logs; k++ {
+				newLog, err := models.NewTransactionLog(&types.Log{
+					TxHash:    tx.Hash(),
+					BlockHash: block.Hash(),
+					Index:     uint(j*logs + k),
+					Data:      make([]byte, 64),
+				}, tx.Hash().String())
+				if err != nil {
+					return nil, err
+				}
+				data.logs = append(data.logs, newLog)
+			}
+		}
+	}
+
+	return data, nil
+}
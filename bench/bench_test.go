@@ -0,0 +1,41 @@
+package bench
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"main/database"
+)
+
+// BenchmarkWritePaths measures the rows per second written by the row-by-row
+// and the bulk write paths on SQLite, the way the bench command does on the
+// configured database.
+func BenchmarkWritePaths(b *testing.B) {
+	ctx := context.Background()
+	if err := database.InitializeSQLite(ctx, filepath.Join(b.TempDir(), "bench.db")); err != nil {
+		b.Fatalf("failed to initialize database: %v", err)
+	}
+	defer database.Finalize()
+
+	data, err := NewData(10, 100, 3)
+	if err != nil {
+		b.Fatalf("failed to generate blocks: %v", err)
+	}
+	for _, mode := range []Mode{
+		{"row-by-row", WriteRowByRow},
+		{"multi-row-upsert", WriteMultiRow},
+	} {
+		b.Run(mode.Name, func(b *testing.B) {
+			var total float64
+			for i := 0; i < b.N; i++ {
+				elapsed, err := Time(database.GetSQLWithContext(ctx), mode, data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				total += elapsed.Seconds()
+			}
+			b.ReportMetric(float64(data.Rows()*b.N)/total, "rows/s")
+		})
+	}
+}
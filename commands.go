@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"main/abi_registry"
+	"main/config"
+	"main/database"
+	"main/eth_index"
+	"main/global"
+	"main/leader"
+	"main/lifecycle"
+	"main/logging"
+	"main/models"
+	"main/server"
+	"main/store"
+	"main/tracing"
+)
+
+// exportBatchSize is the number of blocks loaded from DB at a time on export.
+const exportBatchSize = 1000
+
+// newFlagSet returns the flag set of a subcommand, printing the given
+// arguments in its usage.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s %s\n", os.Args[0], name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// requireFlags exits with the usage if any of the named flags isn't set.
+func requireFlags(fs *flag.FlagSet, names ...string) {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range names {
+		if !set[name] {
+			fmt.Fprintf(fs.Output(), "flag -%s is required\n", name)
+			fs.Usage()
+			os.Exit(2)
+		}
+	}
+}
+
+// signalContext returns a context canceled on SIGINT or SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// stoppingContext returns a context canceled once the shutdown has begun.
+func stoppingContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-lifecycle.Stopping():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// setup initializes the logging, lifecycle, tracing and database modules.
+// Modules register with the lifecycle module to be stopped on shutdown.
+func setup(ctx context.Context, migrate bool) {
+	// Setup logging module, finalized last so shutdown is logged.
+	// NOTE: This should always be first.
+	logging.Initialize(ctx)
+	lifecycle.Initialize(ctx)
+	lifecycle.Register("logging", func(ctx context.Context) error {
+		logging.Finalize()
+		return nil
+	})
+
+	// Setup tracing module.
+	tracing.Initialize(ctx)
+
+	// Setup database module
+	database.SetAutoMigrate(migrate)
+	database.Initialize(ctx)
+}
+
+// watchConfig reloads the configuration on SIGHUP and configuration file
+// changes until the context is done.
+func watchConfig(ctx context.Context) {
+	go config.Watch(ctx, func(changed []string, err error) {
+		if err != nil {
+			logging.Error(ctx, "Configuration reload rejected: %v", err)
+		} else if len(changed) > 0 {
+			logging.Info(ctx, "Configuration reloaded: %v", changed)
+		}
+	})
+}
+
+// serve runs the HTTP server on the address until it's shut down, shutting
+// down everything if the server fails.
+func serve(ctx context.Context, address string) {
+	// Don't start serving if the shutdown has already begun.
+	select {
+	case <-lifecycle.Stopping():
+		return
+	default:
+	}
+
+	// Create HTTP server instance.
+	server := server.CreateServer(ctx, address)
+
+	// Set readiness & liveness flags.
+	global.Ready = true
+	global.Alive = true
+
+	// Start servicing requests.
+	logging.Info(ctx, "Initialization complete, listening on %s...", address)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logging.Error(ctx, "Failed to serve: %v", err)
+		lifecycle.Shutdown()
+	}
+}
+
+// index returns the function indexing blocks while this instance leads. It
+// syncs new heads, resolves token metadata, spot checks balances and catches
+// up on blocks that failed to sync. If syncLatest is set, it also syncs the
+// blocks missed while another instance led or none did.
+func index(syncLatest bool) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		var wg sync.WaitGroup
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			eth_index.RunRealtimeSync(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			eth_index.RunTokenResolver(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			eth_index.RunBalanceReconciler(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			eth_index.RunCatchUp(ctx, syncLatest)
+		}()
+		wg.Wait()
+	}
+}
+
+// defaultAddress returns the configured HTTP listen address.
+func defaultAddress() string {
+	return fmt.Sprintf("%s:%s",
+		config.GetString("SERVER_LISTEN_ADDRESS"),
+		config.GetString("SERVER_LISTEN_PORT"))
+}
+
+// runAll runs the API, the realtime indexer and the startup sync, as the
+// binary did before it had subcommands.
+func runAll(args []string) error {
+	fs := newFlagSet("all", "[flags]")
+	address := fs.String("address", defaultAddress(), "HTTP listen address")
+	migrate := fs.Bool("migrate", config.GetBool("DATABASE_AUTO_MIGRATE"),
+		"apply pending migrations on startup")
+	syncStartup := fs.Bool("sync-startup", true,
+		"sync the blocks missed since the indexed watermark and the latest blocks when elected leader")
+	fs.Parse(args)
+
+	// Create root context.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setup(ctx, *migrate)
+	watchConfig(ctx)
+
+	// Register the ABIs of the ABI directory.
+	abi_registry.Initialize(ctx)
+
+	// Setup etn_index module, indexing only while leader.
+	leader.Initialize(ctx)
+	eth_index.Initialize(ctx)
+	leader.Start(index(*syncStartup))
+
+	// Serve until shutdown, then wait for all modules to stop.
+	serve(ctx, *address)
+	lifecycle.Wait()
+	return nil
+}
+
+// runServe runs the API only.
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "[flags]")
+	address := fs.String("address", defaultAddress(), "HTTP listen address")
+	migrate := fs.Bool("migrate", config.GetBool("DATABASE_AUTO_MIGRATE"),
+		"apply pending migrations on startup")
+	fs.Parse(args)
+
+	// Create root context.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setup(ctx, *migrate)
+	watchConfig(ctx)
+
+	// Register the ABIs of the ABI directory.
+	abi_registry.Initialize(ctx)
+
+	// Serve until shutdown, then wait for all modules to stop.
+	serve(ctx, *address)
+	lifecycle.Wait()
+	return nil
+}
+
+// runIndex runs the realtime indexer only, until SIGINT or SIGTERM.
+func runIndex(args []string) error {
+	fs := newFlagSet("index", "[flags]")
+	migrate := fs.Bool("migrate", config.GetBool("DATABASE_AUTO_MIGRATE"),
+		"apply pending migrations on startup")
+	syncStartup := fs.Bool("sync-startup", true,
+		"sync the blocks missed since the indexed watermark and the latest blocks when elected leader")
+	fs.Parse(args)
+
+	// Create root context.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setup(ctx, *migrate)
+	watchConfig(ctx)
+
+	// Setup etn_index module, indexing only while leader.
+	leader.Initialize(ctx)
+	eth_index.Initialize(ctx)
+	leader.Start(index(*syncStartup))
+
+	// Index until shutdown, then wait for all modules to stop.
+	logging.Info(ctx, "Initialization complete, indexing new blocks...")
+	lifecycle.Wait()
+	return nil
+}
+
+// runBackfill syncs a range of blocks and exits.
+func runBackfill(args []string) error {
+	fs := newFlagSet("backfill", "-from N -to N [flags]")
+	from := fs.Uint64("from", 0, "first block number to sync")
+	to := fs.Uint64("to", 0, "last block number to sync")
+	batch := fs.Int("batch", 50, "number of blocks fetched in parallel")
+	migrate := fs.Bool("migrate", config.GetBool("DATABASE_AUTO_MIGRATE"),
+		"apply pending migrations on startup")
+	fs.Parse(args)
+	requireFlags(fs, "from", "to")
+
+	// Create root context.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setup(ctx, *migrate)
+	defer lifecycle.Shutdown()
+
+	// Setup etn_index module, a shutdown stops after the current batch.
+	eth_index.Initialize(ctx)
+
+	start := time.Now()
+	if err := eth_index.Backfill(ctx, *from, *to, *batch); err != nil {
+		return err
+	}
+	logging.Info(ctx, "Backfilled blocks %d-%d in %v", *from, *to, time.Since(start))
+	return nil
+}
+
+// runVerify checks indexed blocks for gaps, broken parent links and, unless
+// disabled, mismatches with the chain. It fails if any problem is found.
+func runVerify(args []string) error {
+	fs := newFlagSet("verify", "[-from N] [-to N] [flags]")
+	from := fs.Uint64("from", 0, "first block number to check (default lowest indexed)")
+	to := fs.Uint64("to", 0, "last block number to check (default highest indexed)")
+	rpc := fs.Bool("rpc", true, "compare blocks with the chain through the RPC endpoint")
+	fs.Parse(args)
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	ctx, cancel := stoppingContext()
+	defer cancel()
+	setup(ctx, false)
+	defer lifecycle.Shutdown()
+
+	// Default to the whole indexed range.
+	if !set["from"] {
+		lowest, err := store.Blocks.GetLowest(ctx)
+		if errors.Is(err, store.ErrNotFound) {
+			fmt.Println("no blocks indexed")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		*from = lowest.GetNumber()
+	}
+	if !set["to"] {
+		highest, err := store.Blocks.GetHighest(ctx, models.StatusLatest)
+		if errors.Is(err, store.ErrNotFound) {
+			fmt.Println("no blocks indexed")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		*to = highest.GetNumber()
+	}
+
+	problems, err := eth_index.Verify(ctx, *from, *to, *rpc)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in blocks %d-%d", len(problems), *from, *to)
+	}
+	fmt.Printf("blocks %d-%d verified\n", *from, *to)
+	return nil
+}
+
+// runMigrate applies, rolls back or lists schema migrations.
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate", "[-steps N] up|down|status")
+	steps := fs.Int("steps", 1, "number of migrations to roll back with down")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *steps <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	action := fs.Arg(0)
+	switch action {
+	case "up", "down", "status":
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	// Setup logging and database modules without migrating on startup.
+	logging.Initialize(ctx)
+	defer logging.Finalize()
+	database.SetAutoMigrate(false)
+	database.Initialize(ctx)
+	defer database.Finalize()
+
+	switch action {
+	case "up":
+		return database.MigrateUp(ctx)
+	case "down":
+		return database.MigrateDown(ctx, *steps)
+	default:
+		migrations, err := database.GetMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = time.UnixMilli(*m.AppliedAt).UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-30s  %s\n", m.Version, m.Name, applied)
+		}
+		return nil
+	}
+}
+
+// exportedBlock is a block with its transactions as written by export.
+type exportedBlock struct {
+	Block        models.BlockIntf         `json:"block"`
+	Transactions []models.TransactionIntf `json:"transactions"`
+}
+
+// runExport writes the indexed blocks in a range with their transactions to
+// a file, one JSON object per line.
+func runExport(args []string) error {
+	fs := newFlagSet("export", "-from N -to N [-out FILE]")
+	from := fs.Uint64("from", 0, "first block number to export")
+	to := fs.Uint64("to", 0, "last block number to export")
+	out := fs.String("out", "blocks.jsonl", "output file")
+	fs.Parse(args)
+	requireFlags(fs, "from", "to")
+	if *from > *to {
+		return fmt.Errorf("invalid block range %d-%d", *from, *to)
+	}
+
+	ctx, cancel := stoppingContext()
+	defer cancel()
+	setup(ctx, false)
+	defer lifecycle.Shutdown()
+
+	// Create the output file.
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+
+	// Write blocks in batches.
+	exported := 0
+	for start := *from; start <= *to; start += exportBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + exportBatchSize - 1
+		if end > *to || end < start {
+			end = *to
+		}
+		blocks, err := store.Blocks.GetRange(ctx, start, end)
+		if err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			txs, err := store.Txs.GetByBlockHash(ctx, block.GetHash())
+			if err != nil {
+				return err
+			}
+			if err := encoder.Encode(exportedBlock{block, txs}); err != nil {
+				return err
+			}
+			exported++
+		}
+		if end == *to {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	logging.Info(ctx, "Exported %d blocks to %s", exported, *out)
+	return file.Close()
+}
+
+// runConfig runs the config command. "config print" prints the effective
+// configuration with secrets masked.
+func runConfig(args []string) error {
+	fs := newFlagSet("config", "print")
+	fs.Parse(args)
+	if fs.NArg() != 1 || fs.Arg(0) != "print" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	return config.Print(os.Stdout)
+}
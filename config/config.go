@@ -1,14 +1,13 @@
 package config
 
 import (
-	"os"
 	"strconv"
 	"time"
 )
 
 // GetString returns a setting in string.
 func GetString(key string) string {
-	val, exists := os.LookupEnv(key)
+	val, exists := lookup(key)
 	if !exists {
 		panic(key)
 	}
@@ -68,3 +67,21 @@ func GetMilliseconds(key string) time.Duration {
 	val := time.Duration(GetUint(key)) * time.Millisecond
 	return val
 }
+
+// GetFloat64 returns a setting in 64-bit floating point.
+func GetFloat64(key string) float64 {
+	// Parse float64 value from environment variable.
+	var val float64
+	var err error
+	if val, err = strconv.ParseFloat(GetString(key), 64); err != nil {
+		panic(err)
+	}
+
+	return val
+}
+
+// Has returns whether a setting is present.
+func Has(key string) bool {
+	_, exists := lookup(key)
+	return exists
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileKey is the environment variable naming the configuration file. Without
+// it, settings are taken from the environment and defaults only.
+const FileKey = "CONFIG_FILE"
+
+// secretMask replaces secret values when printing the configuration.
+const secretMask = "********"
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  - %s",
+		strings.Join(e.Problems, "\n  - "))
+}
+
+// The effective configuration, its settings keyed by environment variable,
+// the configuration as loaded and the error of loading it. The defaults are
+// in effect while the loaded configuration is invalid, so that packages can
+// read settings on import and the error is reported by the command.
+var mutex sync.RWMutex
+var current *Config
+var values map[string]string
+var loaded *Config
+var loadErr error
+
+// init loads the configuration from the file, environment and defaults.
+func init() {
+	loaded, values, loadErr = Load(os.Getenv(FileKey))
+	current = loaded
+	if loadErr != nil {
+		current, values = defaults()
+	}
+}
+
+// Get returns the effective typed configuration, the defaults if the
+// configuration is invalid.
+func Get() *Config {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return current
+}
+
+// Err returns the error of loading the configuration, a *ValidationError
+// listing every problem found, or nil if it's valid.
+func Err() error {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return loadErr
+}
+
+// RPCErr returns a *ValidationError listing the RPC endpoint settings
+// missing for the commands requiring them, or nil if there are none.
+func RPCErr() error {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if problems := current.validateRPC(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// defaults returns the default configuration and its settings keyed by
+// environment variable.
+func defaults() (*Config, map[string]string) {
+	cfg := &Config{}
+	applyDefaults(cfg)
+	return cfg, settingsOf(cfg)
+}
+
+// applyDefaults sets every setting of the configuration to its default.
+func applyDefaults(cfg *Config) {
+	walkSettings(cfg, func(field reflect.StructField, value reflect.Value) {
+		if err := setFromString(value, field.Tag.Get("default")); err != nil {
+			panic(fmt.Sprintf("invalid default of %s: %v", field.Name, err))
+		}
+	})
+}
+
+// settingsOf returns the settings of the configuration keyed by environment
+// variable.
+func settingsOf(cfg *Config) map[string]string {
+	settings := map[string]string{}
+	walkSettings(cfg, func(field reflect.StructField, value reflect.Value) {
+		settings[field.Tag.Get("env")] = formatValue(value)
+	})
+	return settings
+}
+
+// Load loads a configuration by applying the defaults, the settings of the
+// file at path, if not empty, and the environment variable overrides, in that
+// order. It returns the configuration and its settings keyed by environment
+// variable, along with a *ValidationError listing every problem found.
+func Load(path string) (*Config, map[string]string, error) {
+	cfg := &Config{}
+	problems := []string{}
+
+	// Apply defaults.
+	applyDefaults(cfg)
+
+	// Apply settings of the configuration file.
+	if len(path) > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if err = yaml.UnmarshalStrict(data, cfg); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+
+	// Apply environment variable overrides.
+	walkSettings(cfg, func(field reflect.StructField, value reflect.Value) {
+		key := field.Tag.Get("env")
+		if env, exists := os.LookupEnv(key); exists {
+			if err := setFromString(value, env); err != nil {
+				problems = append(problems,
+					fmt.Sprintf("%s: invalid value %q: %v", key, env, err))
+			}
+		}
+	})
+
+	// Validate the resulting configuration.
+	problems = append(problems, cfg.validate()...)
+
+	// Collect settings keyed by environment variable.
+	settings := settingsOf(cfg)
+
+	if len(problems) > 0 {
+		return cfg, settings, &ValidationError{Problems: problems}
+	}
+	return cfg, settings, nil
+}
+
+// Print writes the configuration as loaded as YAML with secrets masked, and
+// returns the error of loading it, if any.
+func Print(w io.Writer) error {
+	mutex.RLock()
+	masked := *loaded
+	err := loadErr
+	mutex.RUnlock()
+
+	// Mask secrets of the copy.
+	walkSettings(&masked, func(field reflect.StructField, value reflect.Value) {
+		if field.Tag.Get("secret") == "true" && value.Len() > 0 {
+			value.SetString(secretMask)
+		}
+	})
+
+	data, marshalErr := yaml.Marshal(&masked)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	if _, writeErr := w.Write(data); writeErr != nil {
+		return writeErr
+	}
+
+	return err
+}
+
+// lookup returns the effective value of a setting. Settings unknown to the
+// typed configuration are looked up in the environment.
+func lookup(key string) (string, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if val, exists := values[key]; exists {
+		return val, true
+	}
+	return os.LookupEnv(key)
+}
+
+// walkSettings calls fn for every setting of the configuration, i.e. every
+// struct field with an env tag.
+func walkSettings(cfg *Config, fn func(reflect.StructField, reflect.Value)) {
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field, value := t.Field(i), v.Field(i)
+			if field.Type.Kind() == reflect.Struct {
+				walk(value)
+			} else if len(field.Tag.Get("env")) > 0 {
+				fn(field, value)
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+}
+
+// setFromString parses s into the setting value according to its kind.
+func setFromString(value reflect.Value, s string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+	return nil
+}
+
+// formatValue formats a setting value as it would be given in the environment.
+func formatValue(value reflect.Value) string {
+	if value.Kind() == reflect.Float64 {
+		return strconv.FormatFloat(value.Float(), 'g', -1, 64)
+	}
+	return fmt.Sprint(value.Interface())
+}
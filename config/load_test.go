@@ -0,0 +1,99 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "log:\n  format: xml\ntracing:\n  exporter: bad\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INFURA_ENDPOINT", "http://127.0.0.1:8545")
+	t.Setenv("INFURA_WS_ENDPOINT", "ws://127.0.0.1:8546")
+	t.Setenv("API_MAX_BLOCK_REQ", "not a number")
+
+	cfg, settings, err := Load(path)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Load() error = %v, want a *ValidationError", err)
+	}
+	for _, key := range []string{"LOG_FORMAT", "TRACING_EXPORTER", "API_MAX_BLOCK_REQ"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Load() error doesn't report %s: %v", key, err)
+		}
+	}
+	if cfg.Log.Format != "xml" || settings["LOG_FORMAT"] != "xml" {
+		t.Errorf("Load() didn't apply the file: %q", cfg.Log.Format)
+	}
+}
+
+func TestInvalidConfigurationFallsBackToDefaults(t *testing.T) {
+	mutex.Lock()
+	savedCurrent, savedValues, savedLoaded, savedErr := current, values, loaded, loadErr
+	t.Cleanup(func() {
+		mutex.Lock()
+		current, values, loaded, loadErr = savedCurrent, savedValues, savedLoaded, savedErr
+		mutex.Unlock()
+	})
+	t.Setenv("LOG_FORMAT", "xml")
+	loaded, _, loadErr = Load("")
+	current, values = defaults()
+	mutex.Unlock()
+
+	// settings read on import are the defaults, and don't panic
+	if format := GetString("LOG_FORMAT"); format != "text" {
+		t.Errorf("GetString(LOG_FORMAT) = %q, want the default", format)
+	}
+	if Get().Log.Format != "text" {
+		t.Errorf("Get().Log.Format = %q, want the default", Get().Log.Format)
+	}
+
+	// the error is reported by Err and Print, which prints the loaded settings
+	if Err() == nil {
+		t.Error("Err() = nil, want the load error")
+	}
+	var out bytes.Buffer
+	if err := Print(&out); err == nil || !strings.Contains(err.Error(), "LOG_FORMAT") {
+		t.Errorf("Print() error = %v, want the LOG_FORMAT problem", err)
+	}
+	if !strings.Contains(out.String(), "format: xml") {
+		t.Errorf("Print() didn't print the loaded configuration:\n%s", out.String())
+	}
+}
+
+func TestRPCSettingsOnlyRequiredOnDemand(t *testing.T) {
+	t.Setenv("INFURA_ENDPOINT", "")
+	t.Setenv("INFURA_WS_ENDPOINT", "")
+	cfg, _, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v, want none without RPC endpoints", err)
+	}
+
+	mutex.Lock()
+	saved := current
+	current = cfg
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		current = saved
+		mutex.Unlock()
+	})
+	err = RPCErr()
+	for _, key := range []string{"INFURA_ENDPOINT", "INFURA_WS_ENDPOINT"} {
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("RPCErr() = %v, want the %s problem", err, key)
+		}
+	}
+
+	t.Setenv("INFURA_ENDPOINT", "ftp://127.0.0.1")
+	if _, _, err := Load(""); err == nil || !strings.Contains(err.Error(), "INFURA_ENDPOINT") {
+		t.Errorf("Load() error = %v, want the malformed INFURA_ENDPOINT", err)
+	}
+}
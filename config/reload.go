@@ -0,0 +1,129 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Subscribers notified of reloaded configurations.
+var reloadMutex sync.Mutex
+var subscribers []func(cfg *Config)
+
+// Subscribe registers fn to be called with the new configuration after every
+// successful reload.
+func Subscribe(fn func(cfg *Config)) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Reload loads the configuration again and notifies the subscribers if any
+// setting changed. The new configuration is rejected as a whole, keeping the
+// current one in effect, if it's invalid or changes settings that require a
+// restart. It returns the environment variable names of changed settings.
+func Reload() ([]string, error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	// Load and validate the new configuration.
+	cfg, settings, err := Load(os.Getenv(FileKey))
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't drop the RPC settings the running commands may rely on.
+	if problems := cfg.validateRPC(); len(problems) > 0 && RPCErr() == nil {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	// Compare settings with the current ones.
+	mutex.RLock()
+	old := values
+	mutex.RUnlock()
+	changed := []string{}
+	restart := []string{}
+	walkSettings(cfg, func(field reflect.StructField, value reflect.Value) {
+		key := field.Tag.Get("env")
+		if settings[key] == old[key] {
+			return
+		}
+		changed = append(changed, key)
+		if field.Tag.Get("reload") != "true" {
+			restart = append(restart, key)
+		}
+	})
+	if len(restart) > 0 {
+		return nil, fmt.Errorf("settings require a restart: %s",
+			strings.Join(restart, ", "))
+	}
+	if len(changed) <= 0 {
+		return changed, nil
+	}
+
+	// Swap in the new configuration and notify subscribers.
+	mutex.Lock()
+	current, values, loaded, loadErr = cfg, settings, cfg, nil
+	mutex.Unlock()
+	for _, fn := range subscribers {
+		fn(cfg)
+	}
+
+	return changed, nil
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the modification
+// time of the configuration file changes, until the context is done. The
+// outcome of every reload is passed to report.
+func Watch(ctx context.Context, report func(changed []string, err error)) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigChan)
+
+		path := os.Getenv(FileKey)
+		modTime := fileModTime(path)
+		ticker := time.NewTicker(time.Duration(
+			Get().Server.ConfigWatchInterval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigChan:
+			case <-ticker.C:
+				// Only reload if the file was modified.
+				if len(path) <= 0 {
+					continue
+				}
+				latest := fileModTime(path)
+				if latest.Equal(modTime) {
+					continue
+				}
+				modTime = latest
+			}
+			report(Reload())
+		}
+	}()
+}
+
+// fileModTime returns the modification time of the file, or the zero time if
+// it can't be determined.
+func fileModTime(path string) time.Time {
+	if len(path) <= 0 {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,114 @@
+package config
+
+// Config is the typed service configuration. Every setting can be given in
+// the configuration file under its yaml key, and overridden by the environment
+// variable named by its env tag. Settings missing from both take the value of
+// their default tag. Secret settings are masked when printed. Only settings
+// with a reload tag can change when the configuration is reloaded.
+type Config struct {
+	Project  ProjectConfig  `yaml:"project"`
+	Server   ServerConfig   `yaml:"server"`
+	API      APIConfig      `yaml:"api"`
+	Database DatabaseConfig `yaml:"database"`
+	Indexer  IndexerConfig  `yaml:"indexer"`
+	Log      LogConfig      `yaml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+}
+
+// ProjectConfig identifies the deployment.
+type ProjectConfig struct {
+	ID   string `yaml:"id" env:"PROJECT_ID" default:"assignment"`
+	Name string `yaml:"name" env:"PROJECT_NAME" default:"assignment"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	ListenAddress       string `yaml:"listen_address" env:"SERVER_LISTEN_ADDRESS" default:"0.0.0.0"`
+	ListenPort          string `yaml:"listen_port" env:"SERVER_LISTEN_PORT" default:"8000"`
+	ShutdownGracePeriod uint64 `yaml:"shutdown_grace_period_ms" env:"SERVER_SHUTDOWN_GRACE_PERIOD_MS" default:"30000"`
+	ServiceNameAsRoot   bool   `yaml:"service_name_as_root" env:"SERVICE_NAME_AS_ROOT" default:"false"`
+	ConfigWatchInterval uint64 `yaml:"config_watch_interval_ms" env:"CONFIG_WATCH_INTERVAL_MS" default:"5000"`
+}
+
+// APIConfig configures the API handlers.
+type APIConfig struct {
+	MaxBlockRequest uint64 `yaml:"max_block_request" env:"API_MAX_BLOCK_REQ" default:"20" reload:"true"`
+	AdminToken      string `yaml:"admin_token" env:"ADMIN_TOKEN" default:"" reload:"true" secret:"true"`
+	// ABIDirectory holds the ABIs registered on startup, one JSON file per
+	// contract named after its address.
+	ABIDirectory string `yaml:"abi_directory" env:"ABI_DIRECTORY" default:""`
+}
+
+// DatabaseConfig configures the SQL database connection.
+type DatabaseConfig struct {
+	Dialect         string `yaml:"dialect" env:"DATABASE_DIALECT" default:"postgres"`
+	Host            string `yaml:"host" env:"DATABASE_HOST" default:"127.0.0.1"`
+	Port            string `yaml:"port" env:"DATABASE_PORT" default:"5432"`
+	Name            string `yaml:"name" env:"DATABASE_NAME" default:"assignment"`
+	Username        string `yaml:"username" env:"DATABASE_USERNAME" default:"postgres"`
+	Password        string `yaml:"password" env:"DATABASE_PASSWORD" default:"" secret:"true"`
+	Path            string `yaml:"path" env:"DATABASE_PATH" default:"assignment.db"`
+	MaxIdleConns    int    `yaml:"max_idle_connections" env:"DATABASE_MAX_IDLE_CONNECTIONS" default:"5"`
+	MaxOpenConns    int    `yaml:"max_open_connections" env:"DATABASE_MAX_OPEN_CONNECTIONS" default:"30"`
+	MaxConnLifetime uint64 `yaml:"max_conn_lifetime_ms" env:"DATABASE_MAX_CONN_LIFETIME_MS" default:"300000"`
+	AutoMigrate     bool   `yaml:"auto_migrate" env:"DATABASE_AUTO_MIGRATE" default:"true"`
+}
+
+// IndexerConfig configures the block indexer.
+type IndexerConfig struct {
+	// Endpoints are comma separated lists, used in order for failover.
+	Endpoint       string `yaml:"endpoint" env:"INFURA_ENDPOINT" default:"" reload:"true" secret:"true"`
+	WSEndpoint     string `yaml:"ws_endpoint" env:"INFURA_WS_ENDPOINT" default:"" reload:"true" secret:"true"`
+	ConfirmedBlock uint64 `yaml:"confirmed_block" env:"COMFIRMED_BLOCK" default:"20"`
+
+	// Internal transactions are traced with debug_traceBlockByNumber, which
+	// only archive or trace nodes support.
+	TraceInternalTxs bool `yaml:"trace_internal_transactions" env:"TRACE_INTERNAL_TRANSACTIONS" default:"false"`
+
+	// Token metadata is resolved for newly seen tokens every resolve
+	// interval, and refreshed every refresh interval.
+	TokenResolveInterval uint64 `yaml:"token_resolve_interval_ms" env:"TOKEN_RESOLVE_INTERVAL_MS" default:"10000"`
+	TokenRefreshInterval uint64 `yaml:"token_refresh_interval_ms" env:"TOKEN_REFRESH_INTERVAL_MS" default:"3600000"`
+
+	// A batch of balances is spot checked against balanceOf every check
+	// interval, the least recently checked first.
+	BalanceCheckInterval uint64 `yaml:"balance_check_interval_ms" env:"BALANCE_CHECK_INTERVAL_MS" default:"60000"`
+
+	// Only the instance holding the leader lock indexes blocks.
+	LeaderElection     bool   `yaml:"leader_election" env:"LEADER_ELECTION_ENABLED" default:"true"`
+	LeaderPollInterval uint64 `yaml:"leader_poll_interval_ms" env:"LEADER_ELECTION_POLL_INTERVAL_MS" default:"5000"`
+}
+
+// LogConfig configures the logging module and its sinks.
+type LogConfig struct {
+	Level             uint   `yaml:"level" env:"LOG_LEVEL" default:"4" reload:"true"`
+	Format            string `yaml:"format" env:"LOG_FORMAT" default:"text"`
+	ModuleLevels      string `yaml:"module_levels" env:"LOG_MODULE_LEVELS" default:"" reload:"true"`
+	RateLimitBurst    uint   `yaml:"rate_limit_burst" env:"LOG_RATE_LIMIT_BURST" default:"10" reload:"true"`
+	RateLimitInterval uint64 `yaml:"rate_limit_interval_ms" env:"LOG_RATE_LIMIT_INTERVAL_MS" default:"10000" reload:"true"`
+	Sinks             string `yaml:"sinks" env:"LOG_SINKS" default:"stdout"`
+	StdoutLevel       uint   `yaml:"stdout_level" env:"LOG_STDOUT_LEVEL" default:"5" reload:"true"`
+	FileLevel         uint   `yaml:"file_level" env:"LOG_FILE_LEVEL" default:"5" reload:"true"`
+	FilePath          string `yaml:"file_path" env:"LOG_FILE_PATH" default:"logs/assignment.log"`
+	FileFormat        string `yaml:"file_format" env:"LOG_FILE_FORMAT" default:"json"`
+	FileMaxSizeMB     int64  `yaml:"file_max_size_mb" env:"LOG_FILE_MAX_SIZE_MB" default:"100"`
+	FileMaxAge        uint64 `yaml:"file_max_age_ms" env:"LOG_FILE_MAX_AGE_MS" default:"86400000"`
+	FileMaxBackups    int    `yaml:"file_max_backups" env:"LOG_FILE_MAX_BACKUPS" default:"7"`
+	SyslogLevel       uint   `yaml:"syslog_level" env:"LOG_SYSLOG_LEVEL" default:"5" reload:"true"`
+	SyslogNetwork     string `yaml:"syslog_network" env:"LOG_SYSLOG_NETWORK" default:""`
+	SyslogAddress     string `yaml:"syslog_address" env:"LOG_SYSLOG_ADDRESS" default:""`
+	OTLPLevel         uint   `yaml:"otlp_level" env:"LOG_OTLP_LEVEL" default:"5" reload:"true"`
+	OTLPEndpoint      string `yaml:"otlp_endpoint" env:"LOG_OTLP_ENDPOINT" default:"127.0.0.1:4318"`
+	StackDriverLevel  uint   `yaml:"stackdriver_level" env:"LOG_STACKDRIVER_LEVEL" default:"5" reload:"true"`
+	StackDriver       bool   `yaml:"stackdriver_enabled" env:"STACKDRIVER_ENABLED" default:"false"`
+	GRPCTimeout       uint64 `yaml:"grpc_connect_timeout_ms" env:"GRPC_CONNECT_TIMEOUT_MS" default:"15000"`
+}
+
+// TracingConfig configures the tracing module.
+type TracingConfig struct {
+	Exporter        string  `yaml:"exporter" env:"TRACING_EXPORTER" default:"none"`
+	OTLPEndpoint    string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" default:"127.0.0.1:4318"`
+	FilePath        string  `yaml:"file_path" env:"TRACING_FILE_PATH" default:"traces.json"`
+	SampleRatio     float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1"`
+	ShutdownTimeout uint64  `yaml:"shutdown_timeout_ms" env:"TRACING_SHUTDOWN_TIMEOUT_MS" default:"5000"`
+}
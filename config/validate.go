@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Log level names accepted in module levels, besides numbers 1 to 5.
+var logLevelNames = map[string]bool{
+	"critical": true, "crit": true, "error": true, "warning": true,
+	"warn": true, "info": true, "debug": true,
+}
+
+// validate checks the configuration and returns every problem found.
+func (c *Config) validate() []string {
+	problems := []string{}
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	// Server settings.
+	port, err := strconv.ParseUint(c.Server.ListenPort, 10, 16)
+	check(err == nil && port > 0,
+		"SERVER_LISTEN_PORT: invalid port %q", c.Server.ListenPort)
+	check(c.API.MaxBlockRequest > 0, "API_MAX_BLOCK_REQ: must be positive")
+	check(c.Server.ConfigWatchInterval > 0,
+		"CONFIG_WATCH_INTERVAL_MS: must be positive")
+
+	// Database settings.
+	check(oneOf(c.Database.Dialect, "postgres", "cloudsqlpostgres", "sqlite3"),
+		"DATABASE_DIALECT: unsupported dialect %q", c.Database.Dialect)
+	if c.Database.Dialect == "sqlite3" {
+		check(len(c.Database.Path) > 0, "DATABASE_PATH: must not be empty")
+	} else {
+		check(len(c.Database.Host) > 0, "DATABASE_HOST: must not be empty")
+		check(len(c.Database.Name) > 0, "DATABASE_NAME: must not be empty")
+		check(len(c.Database.Username) > 0, "DATABASE_USERNAME: must not be empty")
+	}
+	check(c.Database.MaxOpenConns > 0,
+		"DATABASE_MAX_OPEN_CONNECTIONS: must be positive")
+	check(c.Database.MaxIdleConns >= 0 &&
+		c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"DATABASE_MAX_IDLE_CONNECTIONS: must be between 0 and %d",
+		c.Database.MaxOpenConns)
+
+	// Indexer settings.
+	check(c.Indexer.LeaderPollInterval > 0,
+		"LEADER_ELECTION_POLL_INTERVAL_MS: must be positive")
+	for _, endpoint := range SplitList(c.Indexer.Endpoint) {
+		check(isURL(endpoint, "http", "https"),
+			"INFURA_ENDPOINT: must be a list of http(s) URLs")
+	}
+	for _, endpoint := range SplitList(c.Indexer.WSEndpoint) {
+		check(isURL(endpoint, "ws", "wss"),
+			"INFURA_WS_ENDPOINT: must be a list of ws(s) URLs")
+	}
+	check(c.Indexer.ConfirmedBlock > 0, "COMFIRMED_BLOCK: must be positive")
+	check(c.Indexer.TokenResolveInterval > 0,
+		"TOKEN_RESOLVE_INTERVAL_MS: must be positive")
+	check(c.Indexer.TokenRefreshInterval > 0,
+		"TOKEN_REFRESH_INTERVAL_MS: must be positive")
+	check(c.Indexer.BalanceCheckInterval > 0,
+		"BALANCE_CHECK_INTERVAL_MS: must be positive")
+
+	// Logging settings.
+	for _, level := range []struct {
+		key   string
+		value uint
+	}{
+		{"LOG_LEVEL", c.Log.Level},
+		{"LOG_STDOUT_LEVEL", c.Log.StdoutLevel},
+		{"LOG_FILE_LEVEL", c.Log.FileLevel},
+		{"LOG_SYSLOG_LEVEL", c.Log.SyslogLevel},
+		{"LOG_OTLP_LEVEL", c.Log.OTLPLevel},
+		{"LOG_STACKDRIVER_LEVEL", c.Log.StackDriverLevel},
+	} {
+		check(level.value >= 1 && level.value <= 5,
+			"%s: must be between 1 and 5", level.key)
+	}
+	check(oneOf(c.Log.Format, "text", "json"),
+		"LOG_FORMAT: must be text or json")
+	check(oneOf(c.Log.FileFormat, "text", "json"),
+		"LOG_FILE_FORMAT: must be text or json")
+	for _, sink := range SplitList(c.Log.Sinks) {
+		sink = strings.ToLower(sink)
+		check(oneOf(sink, "stdout", "file", "syslog", "otlp", "stackdriver"),
+			"LOG_SINKS: unknown sink %q", sink)
+	}
+	for _, pair := range SplitList(c.Log.ModuleLevels) {
+		parts := strings.SplitN(pair, "=", 2)
+		check(len(parts) == 2 && len(strings.TrimSpace(parts[0])) > 0 &&
+			isLogLevel(parts[1]), "LOG_MODULE_LEVELS: invalid entry %q", pair)
+	}
+
+	// Tracing settings.
+	check(oneOf(c.Tracing.Exporter, "none", "otlp", "stdout", "file"),
+		"TRACING_EXPORTER: must be none, otlp, stdout or file")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1,
+		"TRACING_SAMPLE_RATIO: must be between 0 and 1")
+	check(c.Tracing.ShutdownTimeout > 0,
+		"TRACING_SHUTDOWN_TIMEOUT_MS: must be positive")
+
+	return problems
+}
+
+// validateRPC checks the RPC endpoint settings required by the indexer
+// commands, and returns every problem found.
+func (c *Config) validateRPC() []string {
+	problems := []string{}
+	if len(SplitList(c.Indexer.Endpoint)) <= 0 {
+		problems = append(problems, "INFURA_ENDPOINT: must not be empty")
+	}
+	if len(SplitList(c.Indexer.WSEndpoint)) <= 0 {
+		problems = append(problems, "INFURA_WS_ENDPOINT: must not be empty")
+	}
+	return problems
+}
+
+// oneOf returns whether value is one of the options.
+func oneOf(value string, options ...string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isURL returns whether value is an absolute URL with one of the schemes.
+func isURL(value string, schemes ...string) bool {
+	u, err := url.Parse(value)
+	return err == nil && len(u.Host) > 0 && oneOf(u.Scheme, schemes...)
+}
+
+// isLogLevel returns whether value is a log level number or name.
+func isLogLevel(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if level, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return level >= 1 && level <= 5
+	}
+	return logLevelNames[value]
+}
+
+// SplitList splits a comma separated list setting, dropping empty items.
+func SplitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
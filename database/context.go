@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+
+	"main/logging"
+)
+
+// contextLogger is a GORM logger which logs through the logging module with
+// the request context attached, so that SQL logs and errors can be correlated
+// with the request that caused them.
+type contextLogger struct {
+	ctx context.Context
+}
+
+// Print implements the GORM logger interface.
+func (l contextLogger) Print(values ...interface{}) {
+	if len(values) < 2 {
+		return
+	}
+
+	switch values[0] {
+	case "sql":
+		// Values are: "sql", source, duration, statement, vars, rows.
+		if len(values) >= 6 {
+			logging.Debug(l.ctx, "SQL [%v] %s %v (%v rows) (%v)",
+				values[2], values[3], values[4], values[5], values[1])
+		}
+	case "error":
+		logging.Error(l.ctx, "SQL error: %v (%v)", values[2:], values[1])
+	default:
+		logging.Debug(l.ctx, "%s", fmt.Sprint(values[2:]...))
+	}
+}
+
+// GetSQLWithContext returns a SQL database handle bound to the given context.
+// Statements and errors logged by the handle carry the request ID found in
+// the context, and queries are traced as children of the span in the context.
+func GetSQLWithContext(ctx context.Context) *gorm.DB {
+	db := GetSQL().New()
+	db.SetLogger(contextLogger{ctx: ctx})
+	return db.Set(logging.ContextKeyRequestID, logging.GetRequestID(ctx)).
+		Set(scopeKeyContext, ctx)
+}
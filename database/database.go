@@ -7,6 +7,7 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"main/config"
+	"main/lifecycle"
 )
 
 // DB is the interface handle to a SQL database.
@@ -14,6 +15,8 @@ type DB interface {
 	initialize(ctx context.Context, cfg dbConfig)
 	finalize()
 	db() interface{}
+	advisoryLocks() bool
+	copyFrom() bool
 }
 
 // dbConfig is the config to connect to a SQL database.
@@ -35,6 +38,9 @@ type dbConfig struct {
 
 	// The name of the database to connect to.
 	DBName string
+
+	// The path of the database file, for file based databases.
+	Path string
 }
 
 // Global database interfaces.
@@ -43,6 +49,12 @@ var DBIntf DB
 // Database root context.
 var dbRootCtx context.Context
 
+// The configured database dialect.
+var dialect string
+
+// Whether to apply pending migrations on initialization.
+var autoMigrate bool
+
 // Connection pool configuration
 var maxIdleConns int
 var maxOpenConns int
@@ -52,6 +64,13 @@ func init() {
 	maxIdleConns = config.GetInt("DATABASE_MAX_IDLE_CONNECTIONS")
 	maxOpenConns = config.GetInt("DATABASE_MAX_OPEN_CONNECTIONS")
 	maxConnLifetime = config.GetMilliseconds("DATABASE_MAX_CONN_LIFETIME_MS")
+	autoMigrate = config.GetBool("DATABASE_AUTO_MIGRATE")
+}
+
+// SetAutoMigrate overrides whether Initialize applies pending migrations. It
+// must be called before Initialize.
+func SetAutoMigrate(enabled bool) {
+	autoMigrate = enabled
 }
 
 // Initialize initializes the database module and instance.
@@ -60,26 +79,63 @@ func Initialize(ctx context.Context) {
 	dbRootCtx = ctx
 
 	// Create database according to dialect.
-	Dialect := config.GetString("DATABASE_DIALECT")
-	switch Dialect {
+	dialect = config.GetString("DATABASE_DIALECT")
+	switch dialect {
 	case "postgres", "cloudsqlpostgres":
 		DBIntf = &postgresDB{}
+	case "sqlite3":
+		DBIntf = &sqliteDB{}
 	default:
 		panic("invalid dialect")
 	}
 
 	// Get database configuration from environment variables.
 	DBConfig := dbConfig{
-		Dialect:  config.GetString("DATABASE_DIALECT"),
+		Dialect:  dialect,
 		Username: config.GetString("DATABASE_USERNAME"),
 		Password: config.GetString("DATABASE_PASSWORD"),
 		Address:  config.GetString("DATABASE_HOST"),
 		Port:     config.GetString("DATABASE_PORT"),
 		DBName:   config.GetString("DATABASE_NAME"),
+		Path:     config.GetString("DATABASE_PATH"),
 	}
 
 	// Initialize the database context.
 	DBIntf.initialize(ctx, DBConfig)
+
+	// Trace queries issued through context bound handles.
+	registerTracingCallbacks(GetSQLWithContext(ctx))
+
+	// Close the database handles on shutdown.
+	lifecycle.Register("database", func(ctx context.Context) error {
+		Finalize()
+		return nil
+	})
+
+	// Bring the schema up to date.
+	if autoMigrate {
+		if err := MigrateUp(ctx); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// InitializeSQLite initializes the database module with the SQLite database
+// file at path, and brings its schema up to date, e.g. for tests. Unlike
+// Initialize, it leaves it to the caller to finalize the module.
+func InitializeSQLite(ctx context.Context, path string) error {
+	// Save database root context.
+	dbRootCtx = ctx
+
+	// Initialize the database context.
+	dialect = "sqlite3"
+	DBIntf = &sqliteDB{}
+	DBIntf.initialize(ctx, dbConfig{Dialect: dialect, Path: path})
+
+	// Trace queries issued through context bound handles.
+	registerTracingCallbacks(GetSQLWithContext(ctx))
+
+	return MigrateUp(ctx)
 }
 
 // Finalize finalizes the database module and closes the database handles.
@@ -98,6 +154,18 @@ func GetDB() interface{} {
 	return DBIntf.db()
 }
 
+// SupportsAdvisoryLocks returns whether the database supports session
+// advisory locks. Without them, a single instance is assumed.
+func SupportsAdvisoryLocks() bool {
+	return DBIntf.advisoryLocks()
+}
+
+// SupportsCopy returns whether the database supports bulk loading rows with
+// COPY FROM STDIN.
+func SupportsCopy() bool {
+	return DBIntf.copyFrom()
+}
+
 // GetSQL returns the SQL database instance.
 func GetSQL() *gorm.DB {
 	return GetDB().(*gorm.DB)
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/logging"
+)
+
+// migrationFiles holds the SQL migrations of every supported dialect. Files
+// are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockKey is the advisory lock key held while migrating, so only one
+// instance migrates the schema at a time.
+const migrationLockKey = 7245683921
+
+// Migration is a versioned schema change.
+type Migration struct {
+	Version   uint64 `json:"version"`
+	Name      string `json:"name"`
+	AppliedAt *int64 `json:"applied_at"`
+	up        string
+	down      string
+}
+
+// MigrateUp applies all pending migrations in version order.
+func MigrateUp(ctx context.Context) error {
+	return withMigrationLock(ctx, func(conn *sql.Conn) error {
+		migrations, err := loadMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Apply the migrations that have not been applied yet.
+		for _, m := range migrations {
+			if m.AppliedAt != nil {
+				continue
+			}
+			if err := applyMigration(ctx, conn, m, true); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown rolls back the given number of most recently applied migrations.
+func MigrateDown(ctx context.Context, steps int) error {
+	return withMigrationLock(ctx, func(conn *sql.Conn) error {
+		migrations, err := loadMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Roll back applied migrations in reverse version order.
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if m.AppliedAt == nil {
+				continue
+			}
+			if err := applyMigration(ctx, conn, m, false); err != nil {
+				return err
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// GetMigrations returns all known migrations with the time they were applied.
+func GetMigrations(ctx context.Context) ([]Migration, error) {
+	var migrations []Migration
+	err := withMigrationLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		migrations, err = loadMigrations(ctx, conn)
+		return err
+	})
+	return migrations, err
+}
+
+// withMigrationLock runs fn on a dedicated connection while holding the
+// migration lock, making sure the schema_migrations table exists.
+func withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := GetSQL().DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Advisory locks are held by the session, so lock and unlock on the
+	// same connection the migrations run on.
+	if SupportsAdvisoryLocks() {
+		if _, err := conn.ExecContext(ctx,
+			"SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(),
+				"SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+				logging.Error(ctx, "Failed to release migration lock: %v", err)
+			}
+		}()
+	}
+
+	// Create the migration bookkeeping table.
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version    BIGINT PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			applied_at BIGINT NOT NULL
+		)`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// loadMigrations reads the embedded migrations of the configured dialect and
+// marks the ones recorded in schema_migrations as applied.
+func loadMigrations(ctx context.Context, conn *sql.Conn) ([]Migration, error) {
+	dir := path.Join("migrations", migrationDialect())
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pair up and down files by version.
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		// Parse the version and name from the file name.
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", name)
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("conflicting migration names for version %d", version)
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.up) <= 0 {
+			return nil, fmt.Errorf("migration %d has no up file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	// Mark applied migrations.
+	rows, err := conn.QueryContext(ctx,
+		"SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version uint64
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		i := sort.Search(len(migrations), func(i int) bool {
+			return migrations[i].Version >= version
+		})
+		if i >= len(migrations) || migrations[i].Version != version {
+			return nil, fmt.Errorf(
+				"database has migration %d applied which this binary does not know", version)
+		}
+		migrations[i].AppliedAt = &appliedAt
+	}
+
+	return migrations, rows.Err()
+}
+
+// applyMigration runs the up or down script of a migration and records it in
+// schema_migrations within a single transaction.
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+	script, verb := m.up, "Applying"
+	if !up {
+		script, verb = m.down, "Rolling back"
+		if len(script) <= 0 {
+			return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+	}
+	logging.Info(ctx, "%s migration %04d_%s", verb, m.Version, m.Name)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Run the script and record the result.
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+			m.Version, m.Name, time.Now().UnixNano()/int64(time.Millisecond))
+	} else {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// migrationDialect returns the migrations directory for the configured
+// database dialect.
+func migrationDialect() string {
+	switch dialect {
+	case "postgres", "cloudsqlpostgres":
+		return "postgres"
+	case "sqlite3":
+		return "sqlite"
+	default:
+		return dialect
+	}
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// useSQLite initializes the module with an empty SQLite database for the
+// duration of the test, with every migration applied.
+func useSQLite(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := InitializeSQLite(context.Background(), path); err != nil {
+		t.Fatalf("InitializeSQLite: %v", err)
+	}
+	t.Cleanup(Finalize)
+}
+
+// countApplied returns the number of migrations applied, and the number
+// known.
+func countApplied(t *testing.T) (int, int) {
+	t.Helper()
+	migrations, err := GetMigrations(context.Background())
+	if err != nil {
+		t.Fatalf("GetMigrations: %v", err)
+	}
+	applied := 0
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			applied++
+		}
+	}
+	return applied, len(migrations)
+}
+
+func TestMigrateRoundTrip(t *testing.T) {
+	useSQLite(t)
+	ctx := context.Background()
+	applied, known := countApplied(t)
+	if known == 0 || applied != known {
+		t.Fatalf("%d of %d migrations applied, want all", applied, known)
+	}
+
+	// roll back one migration at a time, so each down migration runs on the
+	// schema it's written for
+	for want := known - 1; want >= 0; want-- {
+		if err := MigrateDown(ctx, 1); err != nil {
+			t.Fatalf("MigrateDown with %d applied: %v", want+1, err)
+		}
+		if applied, _ := countApplied(t); applied != want {
+			t.Fatalf("%d migrations applied, want %d", applied, want)
+		}
+	}
+
+	if err := MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if applied, _ := countApplied(t); applied != known {
+		t.Errorf("%d migrations applied, want %d", applied, known)
+	}
+}
+
+func TestMigrateDownAll(t *testing.T) {
+	useSQLite(t)
+	ctx := context.Background()
+	_, known := countApplied(t)
+	if err := MigrateDown(ctx, known); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	// only the bookkeeping table is left
+	var tables []string
+	rows, err := GetSQL().DB().Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("listing tables: %v", err)
+		}
+		tables = append(tables, name)
+	}
+	if len(tables) != 1 || tables[0] != "schema_migrations" {
+		t.Errorf("tables = %v, want only schema_migrations", tables)
+	}
+}
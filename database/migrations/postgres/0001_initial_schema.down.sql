@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS transaction_logs;
+DROP TABLE IF EXISTS receipts;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS blocks;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS transaction_logs_tx_hash_idx ON transaction_logs (tx_hash);
+DROP INDEX IF EXISTS transaction_logs_tx_hash_log_index_key;
+ALTER TABLE transaction_logs ALTER COLUMN log_index DROP NOT NULL;
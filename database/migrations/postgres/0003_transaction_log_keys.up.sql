@@ -0,0 +1,15 @@
+-- Logs are keyed by their transaction and their index in the block, so that
+-- every log of a transaction is kept and writes can upsert on the key.
+DELETE FROM transaction_logs a
+    USING transaction_logs b
+    WHERE a.ctid < b.ctid
+      AND a.tx_hash = b.tx_hash
+      AND a.log_index IS NOT DISTINCT FROM b.log_index;
+UPDATE transaction_logs SET log_index = 0 WHERE log_index IS NULL;
+ALTER TABLE transaction_logs ALTER COLUMN log_index SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_tx_hash_log_index_key
+    ON transaction_logs (tx_hash, log_index);
+
+-- The unique index covers lookups by transaction.
+DROP INDEX IF EXISTS transaction_logs_tx_hash_idx;
@@ -0,0 +1,10 @@
+-- Blocks carry a finality status (latest, safe or finalized) instead of a
+-- stable flag. Blocks confirmed stable by depth are finalized.
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'latest';
+UPDATE blocks SET status = 'finalized' WHERE stable;
+
+DROP INDEX IF EXISTS blocks_stable_number_idx;
+ALTER TABLE blocks DROP COLUMN IF EXISTS stable;
+
+-- The highest block of a status is looked up for the sync status.
+CREATE INDEX IF NOT EXISTS blocks_status_number_idx ON blocks (status, number);
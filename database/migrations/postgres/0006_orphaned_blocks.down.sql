@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS reorgs;
+
+-- Orphaned blocks are deleted with their transactions by the cascades.
+DELETE FROM blocks WHERE NOT canonical;
+DELETE FROM transactions WHERE NOT canonical;
+ALTER TABLE transactions DROP COLUMN IF EXISTS canonical;
+
+DROP INDEX IF EXISTS blocks_canonical_number_key;
+ALTER TABLE blocks DROP COLUMN IF EXISTS canonical;
+ALTER TABLE blocks ADD PRIMARY KEY (number);
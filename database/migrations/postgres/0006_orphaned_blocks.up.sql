@@ -0,0 +1,27 @@
+-- Blocks replaced by a reorg are kept as orphans, flagged non-canonical
+-- along with their transactions. Only the canonical block of a height is
+-- unique, so blocks are no longer keyed by number.
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS canonical BOOL NOT NULL DEFAULT TRUE;
+ALTER TABLE blocks DROP CONSTRAINT IF EXISTS blocks_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS blocks_canonical_number_key
+    ON blocks (number) WHERE canonical;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS canonical BOOL NOT NULL DEFAULT TRUE;
+
+-- Table: reorgs
+-- Chain reorganizations seen by the indexer, from the common ancestor of the
+-- old and new chains to their tips.
+CREATE TABLE IF NOT EXISTS reorgs
+(
+    id             BIGSERIAL PRIMARY KEY,
+    fork_number    BIGINT NOT NULL,
+    depth          BIGINT NOT NULL,
+    old_tip_number BIGINT NOT NULL,
+    old_tip_hash   VARCHAR(255) NOT NULL,
+    new_tip_number BIGINT NOT NULL,
+    new_tip_hash   VARCHAR(255) NOT NULL,
+    detected_at    BIGINT NOT NULL,
+
+    created_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision),
+    updated_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision)
+);
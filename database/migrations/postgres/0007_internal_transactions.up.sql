@@ -0,0 +1,35 @@
+-- Table: internal_transactions
+-- Calls made inside the execution of a transaction, flattened from its call
+-- trace in depth-first order. The trace address is the path of the call in
+-- the tree, as comma separated positions among its siblings. Traces are kept
+-- per block, so the traces of an orphaned block stay with it.
+CREATE TABLE IF NOT EXISTS internal_transactions
+(
+    block_hash    VARCHAR(255) NOT NULL,
+    block_number  BIGINT NOT NULL,
+    tx_hash       VARCHAR(255) NOT NULL REFERENCES transactions (tx_hash) ON DELETE CASCADE,
+    position      BIGINT NOT NULL,
+    trace_address VARCHAR(255) NOT NULL,
+    call_type     VARCHAR(32) NOT NULL,
+    tx_from       VARCHAR(255),
+    tx_to         VARCHAR(255),
+    value         VARCHAR(255),
+    gas           BIGINT,
+    gas_used      BIGINT,
+    error         TEXT,
+    depth         BIGINT NOT NULL,
+
+    created_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision),
+    updated_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS internal_transactions_block_hash_tx_hash_position_key
+    ON internal_transactions (block_hash, tx_hash, position);
+
+-- Internal transactions are listed by transaction and by address.
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_hash_idx
+    ON internal_transactions (tx_hash);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_from_idx
+    ON internal_transactions (tx_from, block_number);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_to_idx
+    ON internal_transactions (tx_to, block_number);
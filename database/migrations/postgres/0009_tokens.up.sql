@@ -0,0 +1,54 @@
+-- Table: tokens
+-- Token contracts seen by the indexer, with their metadata resolved through
+-- eth_call. Metadata a token doesn't implement is left NULL. A token is due
+-- for a refresh from next_refresh_at, right away once first seen.
+CREATE TABLE IF NOT EXISTS tokens
+(
+    address         VARCHAR(255) PRIMARY KEY,
+    name            VARCHAR(255),
+    symbol          VARCHAR(255),
+    decimals        BIGINT,
+    total_supply    VARCHAR(255),
+    resolved        BOOL NOT NULL DEFAULT FALSE,
+    attempts        BIGINT NOT NULL DEFAULT 0,
+    refreshed_at    BIGINT,
+    next_refresh_at BIGINT NOT NULL DEFAULT 0,
+
+    created_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision),
+    updated_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision)
+);
+
+CREATE INDEX IF NOT EXISTS tokens_next_refresh_at_idx
+    ON tokens (next_refresh_at);
+
+-- Table: token_transfers
+-- ERC-20 Transfer events, decoded from the logs of transactions. Transfers
+-- are kept per block, so the transfers of an orphaned block stay with it.
+CREATE TABLE IF NOT EXISTS token_transfers
+(
+    block_hash   VARCHAR(255) NOT NULL,
+    block_number BIGINT NOT NULL,
+    tx_hash      VARCHAR(255) NOT NULL REFERENCES transactions (tx_hash) ON DELETE CASCADE,
+    log_index    BIGINT NOT NULL,
+    token        VARCHAR(255) NOT NULL,
+    tx_from      VARCHAR(255) NOT NULL,
+    tx_to        VARCHAR(255) NOT NULL,
+    amount       VARCHAR(255) NOT NULL,
+
+    created_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision),
+    updated_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS token_transfers_block_hash_log_index_key
+    ON token_transfers (block_hash, log_index);
+
+-- Transfers are cascaded with their transaction, and listed by token and by
+-- address.
+CREATE INDEX IF NOT EXISTS token_transfers_tx_hash_idx
+    ON token_transfers (tx_hash);
+CREATE INDEX IF NOT EXISTS token_transfers_token_idx
+    ON token_transfers (token, block_number);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_from_idx
+    ON token_transfers (tx_from, block_number);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_to_idx
+    ON token_transfers (tx_to, block_number);
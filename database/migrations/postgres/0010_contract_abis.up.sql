@@ -0,0 +1,17 @@
+-- Logs carry the address of the contract emitting them and their topics,
+-- comma separated, so events can be decoded. Logs indexed before are left
+-- NULL.
+ALTER TABLE transaction_logs ADD COLUMN IF NOT EXISTS address VARCHAR(255);
+ALTER TABLE transaction_logs ADD COLUMN IF NOT EXISTS topics TEXT;
+
+-- Table: contract_abis
+-- The ABIs registered for contracts through the admin API or the ABI
+-- directory, as JSON, used to decode the input and the logs of transactions.
+CREATE TABLE IF NOT EXISTS contract_abis
+(
+    address VARCHAR(255) PRIMARY KEY,
+    abi     TEXT NOT NULL,
+
+    created_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision),
+    updated_at BIGINT DEFAULT (date_part('epoch'::text, now()) * (1000)::double precision)
+);
@@ -0,0 +1,13 @@
+-- Logs are keyed by transaction again, so only the logs of the block each
+-- transaction is in are kept.
+DELETE FROM transaction_logs l
+    USING transactions t
+    WHERE t.tx_hash = l.tx_hash AND l.block_hash IS DISTINCT FROM t.block_hash;
+
+DROP INDEX IF EXISTS transaction_logs_tx_hash_idx;
+DROP INDEX IF EXISTS transaction_logs_block_hash_log_index_key;
+ALTER TABLE transaction_logs DROP COLUMN IF EXISTS block_hash;
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_tx_hash_log_index_key
+    ON transaction_logs (tx_hash, log_index);
+
+ALTER TABLE receipts DROP COLUMN IF EXISTS block_hash;
@@ -0,0 +1,22 @@
+-- Receipts and logs are kept per block, so that the logs of a transaction in
+-- an orphaned block stay with it instead of mixing with the logs of the block
+-- the transaction was mined again in. Receipts and logs are read joined on
+-- the block of their transaction.
+ALTER TABLE receipts ADD COLUMN IF NOT EXISTS block_hash VARCHAR(255);
+UPDATE receipts r SET block_hash = t.block_hash
+    FROM transactions t
+    WHERE t.tx_hash = r.tx_hash AND r.block_hash IS NULL;
+
+ALTER TABLE transaction_logs ADD COLUMN IF NOT EXISTS block_hash VARCHAR(255)
+    REFERENCES blocks (hash) ON DELETE CASCADE;
+UPDATE transaction_logs l SET block_hash = t.block_hash
+    FROM transactions t
+    WHERE t.tx_hash = l.tx_hash AND l.block_hash IS NULL;
+
+DROP INDEX IF EXISTS transaction_logs_tx_hash_log_index_key;
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_block_hash_log_index_key
+    ON transaction_logs (block_hash, log_index);
+
+-- Logs are still read by transaction.
+CREATE INDEX IF NOT EXISTS transaction_logs_tx_hash_idx
+    ON transaction_logs (tx_hash);
@@ -0,0 +1,34 @@
+-- Transactions are keyed by hash again, so only one block keeps each of
+-- them: the canonical block it's in, or the block it was last written in.
+-- The rows of orphaned blocks referring to it are kept.
+ALTER TABLE token_transfers DROP CONSTRAINT IF EXISTS token_transfers_block_hash_tx_hash_fkey;
+ALTER TABLE contracts DROP CONSTRAINT IF EXISTS contracts_block_hash_creation_tx_hash_fkey;
+ALTER TABLE internal_transactions DROP CONSTRAINT IF EXISTS internal_transactions_block_hash_tx_hash_fkey;
+ALTER TABLE transaction_logs DROP CONSTRAINT IF EXISTS transaction_logs_block_hash_tx_hash_fkey;
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS receipts_block_hash_tx_hash_fkey;
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS receipts_block_hash_tx_hash_key;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_block_hash_tx_hash_key;
+DROP INDEX IF EXISTS transactions_tx_hash_idx;
+
+DELETE FROM transactions a
+    USING transactions b
+    WHERE a.tx_hash = b.tx_hash
+      AND (a.canonical, COALESCE(a.updated_at, 0), a.ctid)
+        < (b.canonical, COALESCE(b.updated_at, 0), b.ctid);
+DELETE FROM receipts r WHERE NOT EXISTS (
+    SELECT 1 FROM transactions t WHERE t.block_hash = r.block_hash AND t.tx_hash = r.tx_hash);
+DELETE FROM transaction_logs l WHERE NOT EXISTS (
+    SELECT 1 FROM receipts r WHERE r.tx_hash = l.tx_hash);
+
+ALTER TABLE transactions ADD CONSTRAINT transactions_tx_hash_key UNIQUE (tx_hash);
+ALTER TABLE receipts ADD CONSTRAINT receipts_tx_hash_key UNIQUE (tx_hash);
+ALTER TABLE receipts ADD CONSTRAINT receipts_tx_hash_fkey
+    FOREIGN KEY (tx_hash) REFERENCES transactions (tx_hash) ON DELETE CASCADE;
+ALTER TABLE transaction_logs ADD CONSTRAINT transaction_logs_tx_hash_fkey
+    FOREIGN KEY (tx_hash) REFERENCES receipts (tx_hash) ON DELETE CASCADE;
+ALTER TABLE internal_transactions ADD CONSTRAINT internal_transactions_tx_hash_fkey
+    FOREIGN KEY (tx_hash) REFERENCES transactions (tx_hash) ON DELETE CASCADE;
+ALTER TABLE contracts ADD CONSTRAINT contracts_creation_tx_hash_fkey
+    FOREIGN KEY (creation_tx_hash) REFERENCES transactions (tx_hash) ON DELETE CASCADE;
+ALTER TABLE token_transfers ADD CONSTRAINT token_transfers_tx_hash_fkey
+    FOREIGN KEY (tx_hash) REFERENCES transactions (tx_hash) ON DELETE CASCADE;
@@ -0,0 +1,66 @@
+-- Transactions are kept per block, so that an orphaned block keeps its
+-- transactions when they're mined again in the block replacing it. Receipts
+-- follow their transactions, and the rows of a transaction refer to it in
+-- their block. The transactions orphaned blocks lost are restored from the
+-- rows still referring to them.
+ALTER TABLE token_transfers DROP CONSTRAINT IF EXISTS token_transfers_tx_hash_fkey;
+ALTER TABLE contracts DROP CONSTRAINT IF EXISTS contracts_creation_tx_hash_fkey;
+ALTER TABLE internal_transactions DROP CONSTRAINT IF EXISTS internal_transactions_tx_hash_fkey;
+ALTER TABLE transaction_logs DROP CONSTRAINT IF EXISTS transaction_logs_tx_hash_fkey;
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS receipts_tx_hash_fkey;
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS receipts_tx_hash_key;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_tx_hash_key;
+
+INSERT INTO transactions (block_hash, tx_hash, tx_from, tx_to, nounce, data, value, canonical, created_at, updated_at)
+SELECT x.block_hash, t.tx_hash, t.tx_from, t.tx_to, t.nounce, t.data, t.value, b.canonical, t.created_at, t.updated_at
+    FROM (
+        SELECT block_hash, tx_hash FROM receipts
+        UNION SELECT block_hash, tx_hash FROM transaction_logs
+        UNION SELECT block_hash, tx_hash FROM internal_transactions
+        UNION SELECT block_hash, creation_tx_hash FROM contracts
+        UNION SELECT block_hash, tx_hash FROM token_transfers
+    ) x
+    JOIN transactions t ON t.tx_hash = x.tx_hash AND t.block_hash <> x.block_hash
+    JOIN blocks b ON b.hash = x.block_hash;
+
+INSERT INTO receipts (block_hash, tx_hash)
+SELECT DISTINCT l.block_hash, l.tx_hash FROM transaction_logs l
+    JOIN transactions t ON t.block_hash = l.block_hash AND t.tx_hash = l.tx_hash
+    WHERE NOT EXISTS (
+        SELECT 1 FROM receipts r
+        WHERE r.block_hash = l.block_hash AND r.tx_hash = l.tx_hash);
+
+-- Rows of blocks no longer indexed have no transaction to refer to.
+DELETE FROM receipts r WHERE NOT EXISTS (
+    SELECT 1 FROM transactions t WHERE t.block_hash = r.block_hash AND t.tx_hash = r.tx_hash);
+DELETE FROM transaction_logs l WHERE NOT EXISTS (
+    SELECT 1 FROM receipts r WHERE r.block_hash = l.block_hash AND r.tx_hash = l.tx_hash);
+DELETE FROM internal_transactions i WHERE NOT EXISTS (
+    SELECT 1 FROM transactions t WHERE t.block_hash = i.block_hash AND t.tx_hash = i.tx_hash);
+DELETE FROM contracts c WHERE NOT EXISTS (
+    SELECT 1 FROM transactions t WHERE t.block_hash = c.block_hash AND t.tx_hash = c.creation_tx_hash);
+DELETE FROM token_transfers tr WHERE NOT EXISTS (
+    SELECT 1 FROM transactions t WHERE t.block_hash = tr.block_hash AND t.tx_hash = tr.tx_hash);
+
+ALTER TABLE transactions ADD CONSTRAINT transactions_block_hash_tx_hash_key
+    UNIQUE (block_hash, tx_hash);
+ALTER TABLE receipts ADD CONSTRAINT receipts_block_hash_tx_hash_key
+    UNIQUE (block_hash, tx_hash);
+ALTER TABLE receipts ADD CONSTRAINT receipts_block_hash_tx_hash_fkey
+    FOREIGN KEY (block_hash, tx_hash)
+    REFERENCES transactions (block_hash, tx_hash) ON DELETE CASCADE;
+ALTER TABLE transaction_logs ADD CONSTRAINT transaction_logs_block_hash_tx_hash_fkey
+    FOREIGN KEY (block_hash, tx_hash)
+    REFERENCES receipts (block_hash, tx_hash) ON DELETE CASCADE;
+ALTER TABLE internal_transactions ADD CONSTRAINT internal_transactions_block_hash_tx_hash_fkey
+    FOREIGN KEY (block_hash, tx_hash)
+    REFERENCES transactions (block_hash, tx_hash) ON DELETE CASCADE;
+ALTER TABLE contracts ADD CONSTRAINT contracts_block_hash_creation_tx_hash_fkey
+    FOREIGN KEY (block_hash, creation_tx_hash)
+    REFERENCES transactions (block_hash, tx_hash) ON DELETE CASCADE;
+ALTER TABLE token_transfers ADD CONSTRAINT token_transfers_block_hash_tx_hash_fkey
+    FOREIGN KEY (block_hash, tx_hash)
+    REFERENCES transactions (block_hash, tx_hash) ON DELETE CASCADE;
+
+-- Transactions are still looked up by hash.
+CREATE INDEX IF NOT EXISTS transactions_tx_hash_idx ON transactions (tx_hash);
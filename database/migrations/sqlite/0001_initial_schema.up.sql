@@ -0,0 +1,47 @@
+-- Table: blocks
+CREATE TABLE IF NOT EXISTS blocks
+(
+    number BIGINT PRIMARY KEY,
+    hash   VARCHAR(255) UNIQUE NOT NULL,
+    time   BIGINT,
+    parent VARCHAR(255),
+
+    stable BOOLEAN,
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+-- Table: transactions
+CREATE TABLE IF NOT EXISTS transactions
+(
+    block_hash VARCHAR(255) REFERENCES blocks (hash) ON DELETE CASCADE,
+    tx_hash    VARCHAR(255) UNIQUE NOT NULL,
+    tx_from    VARCHAR(255),
+    tx_to      VARCHAR(255),
+    nounce     BIGINT,
+    data       BLOB,
+    value      VARCHAR(255),
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+-- Table: receipts
+CREATE TABLE IF NOT EXISTS receipts
+(
+    tx_hash   VARCHAR(255)  UNIQUE NOT NULL REFERENCES transactions (tx_hash) ON DELETE CASCADE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+-- Table: transaction_logs
+CREATE TABLE IF NOT EXISTS transaction_logs
+(
+    tx_hash   VARCHAR(255) NOT NULL REFERENCES receipts (tx_hash) ON DELETE CASCADE,
+    log_index BIGINT,
+    data      BLOB,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS blocks_stable_number_idx;
+DROP INDEX IF EXISTS transaction_logs_tx_hash_idx;
+DROP INDEX IF EXISTS transactions_block_hash_idx;
@@ -0,0 +1,6 @@
+-- Transactions are listed by block and logs by transaction.
+CREATE INDEX IF NOT EXISTS transactions_block_hash_idx ON transactions (block_hash);
+CREATE INDEX IF NOT EXISTS transaction_logs_tx_hash_idx ON transaction_logs (tx_hash);
+
+-- The highest stable block is looked up for the sync status.
+CREATE INDEX IF NOT EXISTS blocks_stable_number_idx ON blocks (number) WHERE stable;
@@ -0,0 +1,13 @@
+-- Logs are keyed by their transaction and their index in the block, so that
+-- every log of a transaction is kept and writes can upsert on the key.
+DELETE FROM transaction_logs
+    WHERE rowid NOT IN (
+        SELECT MIN(rowid) FROM transaction_logs GROUP BY tx_hash, log_index
+    );
+UPDATE transaction_logs SET log_index = 0 WHERE log_index IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_tx_hash_log_index_key
+    ON transaction_logs (tx_hash, log_index);
+
+-- The unique index covers lookups by transaction.
+DROP INDEX IF EXISTS transaction_logs_tx_hash_idx;
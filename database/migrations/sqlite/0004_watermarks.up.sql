@@ -0,0 +1,19 @@
+-- Table: watermarks
+-- Named block heights, such as the height every block up to which is indexed.
+CREATE TABLE IF NOT EXISTS watermarks
+(
+    name   VARCHAR(64) PRIMARY KEY,
+    number BIGINT NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+-- Start the indexed watermark at the end of the first contiguous run of
+-- blocks already indexed.
+INSERT INTO watermarks (name, number)
+SELECT 'indexed', MIN(b.number)
+FROM blocks b
+WHERE NOT EXISTS (SELECT 1 FROM blocks n WHERE n.number = b.number + 1)
+HAVING MIN(b.number) IS NOT NULL
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,7 @@
+ALTER TABLE blocks ADD COLUMN stable BOOLEAN;
+UPDATE blocks SET stable = (status = 'finalized');
+
+DROP INDEX IF EXISTS blocks_status_number_idx;
+ALTER TABLE blocks DROP COLUMN status;
+
+CREATE INDEX IF NOT EXISTS blocks_stable_number_idx ON blocks (number) WHERE stable;
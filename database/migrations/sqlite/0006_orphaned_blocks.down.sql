@@ -0,0 +1,69 @@
+DROP TABLE IF EXISTS reorgs;
+
+-- Rebuild the tables as in the up migration, keeping the canonical blocks
+-- and transactions only.
+CREATE TABLE blocks_new
+(
+    number BIGINT PRIMARY KEY,
+    hash   VARCHAR(255) UNIQUE NOT NULL,
+    time   BIGINT,
+    parent VARCHAR(255),
+    status VARCHAR(16) NOT NULL DEFAULT 'latest',
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+CREATE TABLE transactions_new
+(
+    block_hash VARCHAR(255) REFERENCES blocks_new (hash) ON DELETE CASCADE,
+    tx_hash    VARCHAR(255) UNIQUE NOT NULL,
+    tx_from    VARCHAR(255),
+    tx_to      VARCHAR(255),
+    nounce     BIGINT,
+    data       BLOB,
+    value      VARCHAR(255),
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+CREATE TABLE receipts_new
+(
+    tx_hash VARCHAR(255) UNIQUE NOT NULL REFERENCES transactions_new (tx_hash) ON DELETE CASCADE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+CREATE TABLE transaction_logs_new
+(
+    tx_hash   VARCHAR(255) NOT NULL REFERENCES receipts_new (tx_hash) ON DELETE CASCADE,
+    log_index BIGINT,
+    data      BLOB,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+INSERT INTO blocks_new (number, hash, time, parent, status, created_at, updated_at)
+SELECT number, hash, time, parent, status, created_at, updated_at FROM blocks WHERE canonical;
+INSERT INTO transactions_new (block_hash, tx_hash, tx_from, tx_to, nounce, data, value, created_at, updated_at)
+SELECT block_hash, tx_hash, tx_from, tx_to, nounce, data, value, created_at, updated_at FROM transactions WHERE canonical;
+INSERT INTO receipts_new (tx_hash, created_at, updated_at)
+SELECT r.tx_hash, r.created_at, r.updated_at FROM receipts r
+    JOIN transactions_new t ON t.tx_hash = r.tx_hash;
+INSERT INTO transaction_logs_new (tx_hash, log_index, data, created_at, updated_at)
+SELECT l.tx_hash, l.log_index, l.data, l.created_at, l.updated_at FROM transaction_logs l
+    JOIN receipts_new r ON r.tx_hash = l.tx_hash;
+
+DROP TABLE transaction_logs;
+DROP TABLE receipts;
+DROP TABLE transactions;
+DROP TABLE blocks;
+ALTER TABLE blocks_new RENAME TO blocks;
+ALTER TABLE transactions_new RENAME TO transactions;
+ALTER TABLE receipts_new RENAME TO receipts;
+ALTER TABLE transaction_logs_new RENAME TO transaction_logs;
+
+CREATE INDEX IF NOT EXISTS blocks_status_number_idx ON blocks (status, number);
+CREATE INDEX IF NOT EXISTS transactions_block_hash_idx ON transactions (block_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_tx_hash_log_index_key
+    ON transaction_logs (tx_hash, log_index);
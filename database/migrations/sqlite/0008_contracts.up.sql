@@ -0,0 +1,39 @@
+-- Table: contract_code
+-- Contract bytecode, stored once per code hash.
+CREATE TABLE IF NOT EXISTS contract_code
+(
+    code_hash VARCHAR(255) PRIMARY KEY,
+    bytecode  BLOB NOT NULL,
+    size      BIGINT NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+-- Table: contracts
+-- Contracts deployed by a transaction, at the top level or by an internal
+-- CREATE or CREATE2. Deployments are kept per block, so the deployments of an
+-- orphaned block stay with it.
+CREATE TABLE IF NOT EXISTS contracts
+(
+    address          VARCHAR(255) NOT NULL,
+    block_hash       VARCHAR(255) NOT NULL,
+    block_number     BIGINT NOT NULL,
+    creation_tx_hash VARCHAR(255) NOT NULL REFERENCES transactions (tx_hash) ON DELETE CASCADE,
+    creator          VARCHAR(255) NOT NULL,
+    code_hash        VARCHAR(255) NOT NULL REFERENCES contract_code (code_hash),
+    standard         VARCHAR(32) NOT NULL DEFAULT '',
+    supports_erc165  BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS contracts_address_block_hash_key
+    ON contracts (address, block_hash);
+
+-- Contracts are cascaded with their creation transaction, and found by code.
+CREATE INDEX IF NOT EXISTS contracts_creation_tx_hash_idx
+    ON contracts (creation_tx_hash);
+CREATE INDEX IF NOT EXISTS contracts_code_hash_idx
+    ON contracts (code_hash);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS token_transfers;
+DROP TABLE IF EXISTS tokens;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS contract_abis;
+
+ALTER TABLE transaction_logs DROP COLUMN topics;
+ALTER TABLE transaction_logs DROP COLUMN address;
@@ -0,0 +1,54 @@
+-- Table: balance_changes
+-- The net change of the balance of each holder of a token in a block,
+-- derived from its ERC-20 transfers, as a signed decimal. Changes are kept
+-- per block, so the changes of an orphaned block can be reverted.
+CREATE TABLE IF NOT EXISTS balance_changes
+(
+    token        VARCHAR(255) NOT NULL,
+    holder       VARCHAR(255) NOT NULL,
+    block_hash   VARCHAR(255) NOT NULL REFERENCES blocks (hash) ON DELETE CASCADE,
+    block_number BIGINT NOT NULL,
+    delta        VARCHAR(255) NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS balance_changes_token_holder_block_hash_key
+    ON balance_changes (token, holder, block_hash);
+
+-- Changes are reverted by block, and summed by holder and by token above a
+-- block number.
+CREATE INDEX IF NOT EXISTS balance_changes_block_hash_idx
+    ON balance_changes (block_hash);
+CREATE INDEX IF NOT EXISTS balance_changes_holder_idx
+    ON balance_changes (holder, block_number);
+CREATE INDEX IF NOT EXISTS balance_changes_token_idx
+    ON balance_changes (token, block_number);
+
+-- Table: balances
+-- The balance of each holder of a token, as of the canonical blocks
+-- indexed, with the result of the last spot check against balanceOf. A
+-- balance is due for a check from checked_at, right away once first seen.
+CREATE TABLE IF NOT EXISTS balances
+(
+    token           VARCHAR(255) NOT NULL,
+    holder          VARCHAR(255) NOT NULL,
+    balance         VARCHAR(255) NOT NULL,
+    checked_at      BIGINT NOT NULL DEFAULT 0,
+    checked_block   BIGINT,
+    onchain_balance VARCHAR(255),
+    mismatch        BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+
+    PRIMARY KEY (token, holder)
+);
+
+-- Balances are listed by holder, and checked the least recently checked
+-- first.
+CREATE INDEX IF NOT EXISTS balances_holder_idx
+    ON balances (holder);
+CREATE INDEX IF NOT EXISTS balances_checked_at_idx
+    ON balances (checked_at);
@@ -0,0 +1,27 @@
+-- Logs are keyed by transaction again, so only the logs of the block each
+-- transaction is in are kept.
+--
+-- SQLite can't drop a column with a foreign key, so the logs are rebuilt.
+-- Nothing refers to them, so the old table can be dropped.
+CREATE TABLE transaction_logs_old
+(
+    tx_hash   VARCHAR(255) NOT NULL REFERENCES receipts (tx_hash) ON DELETE CASCADE,
+    log_index BIGINT,
+    data      BLOB,
+    address   VARCHAR(255),
+    topics    TEXT,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+INSERT INTO transaction_logs_old (tx_hash, log_index, data, address, topics, created_at, updated_at)
+SELECT l.tx_hash, l.log_index, l.data, l.address, l.topics, l.created_at, l.updated_at
+    FROM transaction_logs l
+    JOIN transactions t ON t.tx_hash = l.tx_hash AND t.block_hash = l.block_hash;
+
+DROP TABLE transaction_logs;
+ALTER TABLE transaction_logs_old RENAME TO transaction_logs;
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_tx_hash_log_index_key
+    ON transaction_logs (tx_hash, log_index);
+
+ALTER TABLE receipts DROP COLUMN block_hash;
@@ -0,0 +1,20 @@
+-- Receipts and logs are kept per block, so that the logs of a transaction in
+-- an orphaned block stay with it instead of mixing with the logs of the block
+-- the transaction was mined again in. Receipts and logs are read joined on
+-- the block of their transaction.
+ALTER TABLE receipts ADD COLUMN block_hash VARCHAR(255);
+UPDATE receipts SET block_hash = (
+    SELECT t.block_hash FROM transactions t WHERE t.tx_hash = receipts.tx_hash);
+
+ALTER TABLE transaction_logs ADD COLUMN block_hash VARCHAR(255)
+    REFERENCES blocks (hash) ON DELETE CASCADE;
+UPDATE transaction_logs SET block_hash = (
+    SELECT t.block_hash FROM transactions t WHERE t.tx_hash = transaction_logs.tx_hash);
+
+DROP INDEX IF EXISTS transaction_logs_tx_hash_log_index_key;
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_block_hash_log_index_key
+    ON transaction_logs (block_hash, log_index);
+
+-- Logs are still read by transaction.
+CREATE INDEX IF NOT EXISTS transaction_logs_tx_hash_idx
+    ON transaction_logs (tx_hash);
@@ -0,0 +1,160 @@
+-- Transactions are keyed by hash again, so only one block keeps each of
+-- them: the canonical block it's in, or the block it was last written in.
+-- The rows of orphaned blocks referring to it are kept.
+--
+-- SQLite can't alter constraints, so the transactions and the tables
+-- referring to them are rebuilt, referring to the old tables until they're
+-- renamed.
+CREATE TABLE transactions_old
+(
+    block_hash VARCHAR(255) REFERENCES blocks (hash) ON DELETE CASCADE,
+    tx_hash    VARCHAR(255) UNIQUE NOT NULL,
+    tx_from    VARCHAR(255),
+    tx_to      VARCHAR(255),
+    nounce     BIGINT,
+    data       BLOB,
+    value      VARCHAR(255),
+    canonical  BOOLEAN NOT NULL DEFAULT TRUE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+CREATE TABLE receipts_old
+(
+    tx_hash VARCHAR(255) UNIQUE NOT NULL REFERENCES transactions_old (tx_hash) ON DELETE CASCADE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    block_hash VARCHAR(255)
+);
+CREATE TABLE transaction_logs_old
+(
+    tx_hash   VARCHAR(255) NOT NULL REFERENCES receipts_old (tx_hash) ON DELETE CASCADE,
+    log_index BIGINT NOT NULL,
+    data      BLOB,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    address    VARCHAR(255),
+    topics     TEXT,
+    block_hash VARCHAR(255) REFERENCES blocks (hash) ON DELETE CASCADE
+);
+CREATE TABLE internal_transactions_old
+(
+    block_hash    VARCHAR(255) NOT NULL,
+    block_number  BIGINT NOT NULL,
+    tx_hash       VARCHAR(255) NOT NULL REFERENCES transactions_old (tx_hash) ON DELETE CASCADE,
+    position      BIGINT NOT NULL,
+    trace_address VARCHAR(255) NOT NULL,
+    call_type     VARCHAR(32) NOT NULL,
+    tx_from       VARCHAR(255),
+    tx_to         VARCHAR(255),
+    value         VARCHAR(255),
+    gas           BIGINT,
+    gas_used      BIGINT,
+    error         TEXT,
+    depth         BIGINT NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+CREATE TABLE contracts_old
+(
+    address          VARCHAR(255) NOT NULL,
+    block_hash       VARCHAR(255) NOT NULL,
+    block_number     BIGINT NOT NULL,
+    creation_tx_hash VARCHAR(255) NOT NULL REFERENCES transactions_old (tx_hash) ON DELETE CASCADE,
+    creator          VARCHAR(255) NOT NULL,
+    code_hash        VARCHAR(255) NOT NULL REFERENCES contract_code (code_hash),
+    standard         VARCHAR(32) NOT NULL DEFAULT '',
+    supports_erc165  BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+CREATE TABLE token_transfers_old
+(
+    block_hash   VARCHAR(255) NOT NULL,
+    block_number BIGINT NOT NULL,
+    tx_hash      VARCHAR(255) NOT NULL REFERENCES transactions_old (tx_hash) ON DELETE CASCADE,
+    log_index    BIGINT NOT NULL,
+    token        VARCHAR(255) NOT NULL,
+    tx_from      VARCHAR(255) NOT NULL,
+    tx_to        VARCHAR(255) NOT NULL,
+    amount       VARCHAR(255) NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+INSERT INTO transactions_old (block_hash, tx_hash, tx_from, tx_to, nounce, data, value, canonical, created_at, updated_at)
+SELECT t.block_hash, t.tx_hash, t.tx_from, t.tx_to, t.nounce, t.data, t.value, t.canonical, t.created_at, t.updated_at
+    FROM transactions t
+    WHERE t.rowid = (
+        SELECT o.rowid FROM transactions o
+        WHERE o.tx_hash = t.tx_hash
+        ORDER BY o.canonical DESC, COALESCE(o.updated_at, 0) DESC, o.rowid DESC
+        LIMIT 1);
+INSERT INTO receipts_old (tx_hash, created_at, updated_at, block_hash)
+SELECT r.tx_hash, r.created_at, r.updated_at, r.block_hash FROM receipts r
+    JOIN transactions_old t ON t.tx_hash = r.tx_hash AND t.block_hash = r.block_hash;
+INSERT INTO transaction_logs_old (tx_hash, log_index, data, created_at, updated_at, address, topics, block_hash)
+SELECT l.tx_hash, l.log_index, l.data, l.created_at, l.updated_at, l.address, l.topics, l.block_hash
+    FROM transaction_logs l
+    WHERE l.tx_hash IN (SELECT tx_hash FROM receipts_old);
+INSERT INTO internal_transactions_old (block_hash, block_number, tx_hash, position, trace_address, call_type, tx_from, tx_to, value, gas, gas_used, error, depth, created_at, updated_at)
+SELECT i.block_hash, i.block_number, i.tx_hash, i.position, i.trace_address, i.call_type, i.tx_from, i.tx_to, i.value, i.gas, i.gas_used, i.error, i.depth, i.created_at, i.updated_at
+    FROM internal_transactions i;
+INSERT INTO contracts_old (address, block_hash, block_number, creation_tx_hash, creator, code_hash, standard, supports_erc165, created_at, updated_at)
+SELECT c.address, c.block_hash, c.block_number, c.creation_tx_hash, c.creator, c.code_hash, c.standard, c.supports_erc165, c.created_at, c.updated_at
+    FROM contracts c;
+INSERT INTO token_transfers_old (block_hash, block_number, tx_hash, log_index, token, tx_from, tx_to, amount, created_at, updated_at)
+SELECT tr.block_hash, tr.block_number, tr.tx_hash, tr.log_index, tr.token, tr.tx_from, tr.tx_to, tr.amount, tr.created_at, tr.updated_at
+    FROM token_transfers tr;
+
+DROP TABLE token_transfers;
+DROP TABLE contracts;
+DROP TABLE internal_transactions;
+DROP TABLE transaction_logs;
+DROP TABLE receipts;
+DROP TABLE transactions;
+ALTER TABLE transactions_old RENAME TO transactions;
+ALTER TABLE receipts_old RENAME TO receipts;
+ALTER TABLE transaction_logs_old RENAME TO transaction_logs;
+ALTER TABLE internal_transactions_old RENAME TO internal_transactions;
+ALTER TABLE contracts_old RENAME TO contracts;
+ALTER TABLE token_transfers_old RENAME TO token_transfers;
+
+CREATE INDEX IF NOT EXISTS transactions_block_hash_idx ON transactions (block_hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_block_hash_log_index_key
+    ON transaction_logs (block_hash, log_index);
+CREATE INDEX IF NOT EXISTS transaction_logs_tx_hash_idx
+    ON transaction_logs (tx_hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS internal_transactions_block_hash_tx_hash_position_key
+    ON internal_transactions (block_hash, tx_hash, position);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_hash_idx
+    ON internal_transactions (tx_hash);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_from_idx
+    ON internal_transactions (tx_from, block_number);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_to_idx
+    ON internal_transactions (tx_to, block_number);
+
+CREATE UNIQUE INDEX IF NOT EXISTS contracts_address_block_hash_key
+    ON contracts (address, block_hash);
+CREATE INDEX IF NOT EXISTS contracts_creation_tx_hash_idx
+    ON contracts (creation_tx_hash);
+CREATE INDEX IF NOT EXISTS contracts_code_hash_idx
+    ON contracts (code_hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS token_transfers_block_hash_log_index_key
+    ON token_transfers (block_hash, log_index);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_hash_idx
+    ON token_transfers (tx_hash);
+CREATE INDEX IF NOT EXISTS token_transfers_token_idx
+    ON token_transfers (token, block_number);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_from_idx
+    ON token_transfers (tx_from, block_number);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_to_idx
+    ON token_transfers (tx_to, block_number);
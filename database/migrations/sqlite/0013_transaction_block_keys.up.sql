@@ -0,0 +1,199 @@
+-- Transactions are kept per block, so that an orphaned block keeps its
+-- transactions when they're mined again in the block replacing it. Receipts
+-- follow their transactions, and the rows of a transaction refer to it in
+-- their block. The transactions orphaned blocks lost are restored from the
+-- rows still referring to them.
+--
+-- SQLite can't alter constraints, so the transactions and the tables
+-- referring to them are rebuilt, referring to the new tables until they're
+-- renamed.
+CREATE TABLE transactions_new
+(
+    block_hash VARCHAR(255) REFERENCES blocks (hash) ON DELETE CASCADE,
+    tx_hash    VARCHAR(255) NOT NULL,
+    tx_from    VARCHAR(255),
+    tx_to      VARCHAR(255),
+    nounce     BIGINT,
+    data       BLOB,
+    value      VARCHAR(255),
+    canonical  BOOLEAN NOT NULL DEFAULT TRUE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+
+    UNIQUE (block_hash, tx_hash)
+);
+CREATE TABLE receipts_new
+(
+    block_hash VARCHAR(255),
+    tx_hash    VARCHAR(255) NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+
+    UNIQUE (block_hash, tx_hash),
+    FOREIGN KEY (block_hash, tx_hash)
+        REFERENCES transactions_new (block_hash, tx_hash) ON DELETE CASCADE
+);
+CREATE TABLE transaction_logs_new
+(
+    tx_hash    VARCHAR(255) NOT NULL,
+    block_hash VARCHAR(255) REFERENCES blocks (hash) ON DELETE CASCADE,
+    log_index  BIGINT NOT NULL,
+    data       BLOB,
+    address    VARCHAR(255),
+    topics     TEXT,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+
+    FOREIGN KEY (block_hash, tx_hash)
+        REFERENCES receipts_new (block_hash, tx_hash) ON DELETE CASCADE
+);
+CREATE TABLE internal_transactions_new
+(
+    block_hash    VARCHAR(255) NOT NULL,
+    block_number  BIGINT NOT NULL,
+    tx_hash       VARCHAR(255) NOT NULL,
+    position      BIGINT NOT NULL,
+    trace_address VARCHAR(255) NOT NULL,
+    call_type     VARCHAR(32) NOT NULL,
+    tx_from       VARCHAR(255),
+    tx_to         VARCHAR(255),
+    value         VARCHAR(255),
+    gas           BIGINT,
+    gas_used      BIGINT,
+    error         TEXT,
+    depth         BIGINT NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+
+    FOREIGN KEY (block_hash, tx_hash)
+        REFERENCES transactions_new (block_hash, tx_hash) ON DELETE CASCADE
+);
+CREATE TABLE contracts_new
+(
+    address          VARCHAR(255) NOT NULL,
+    block_hash       VARCHAR(255) NOT NULL,
+    block_number     BIGINT NOT NULL,
+    creation_tx_hash VARCHAR(255) NOT NULL,
+    creator          VARCHAR(255) NOT NULL,
+    code_hash        VARCHAR(255) NOT NULL REFERENCES contract_code (code_hash),
+    standard         VARCHAR(32) NOT NULL DEFAULT '',
+    supports_erc165  BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+
+    FOREIGN KEY (block_hash, creation_tx_hash)
+        REFERENCES transactions_new (block_hash, tx_hash) ON DELETE CASCADE
+);
+CREATE TABLE token_transfers_new
+(
+    block_hash   VARCHAR(255) NOT NULL,
+    block_number BIGINT NOT NULL,
+    tx_hash      VARCHAR(255) NOT NULL,
+    log_index    BIGINT NOT NULL,
+    token        VARCHAR(255) NOT NULL,
+    tx_from      VARCHAR(255) NOT NULL,
+    tx_to        VARCHAR(255) NOT NULL,
+    amount       VARCHAR(255) NOT NULL,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+
+    FOREIGN KEY (block_hash, tx_hash)
+        REFERENCES transactions_new (block_hash, tx_hash) ON DELETE CASCADE
+);
+
+INSERT INTO transactions_new (block_hash, tx_hash, tx_from, tx_to, nounce, data, value, canonical, created_at, updated_at)
+SELECT block_hash, tx_hash, tx_from, tx_to, nounce, data, value, canonical, created_at, updated_at
+    FROM transactions;
+INSERT INTO transactions_new (block_hash, tx_hash, tx_from, tx_to, nounce, data, value, canonical, created_at, updated_at)
+SELECT x.block_hash, t.tx_hash, t.tx_from, t.tx_to, t.nounce, t.data, t.value, b.canonical, t.created_at, t.updated_at
+    FROM (
+        SELECT block_hash, tx_hash FROM receipts
+        UNION SELECT block_hash, tx_hash FROM transaction_logs
+        UNION SELECT block_hash, tx_hash FROM internal_transactions
+        UNION SELECT block_hash, creation_tx_hash FROM contracts
+        UNION SELECT block_hash, tx_hash FROM token_transfers
+    ) x
+    JOIN transactions t ON t.tx_hash = x.tx_hash AND t.block_hash <> x.block_hash
+    JOIN blocks b ON b.hash = x.block_hash;
+
+INSERT INTO receipts_new (block_hash, tx_hash, created_at, updated_at)
+SELECT r.block_hash, r.tx_hash, r.created_at, r.updated_at FROM receipts r
+    JOIN transactions_new t ON t.block_hash = r.block_hash AND t.tx_hash = r.tx_hash;
+INSERT INTO receipts_new (block_hash, tx_hash)
+SELECT DISTINCT l.block_hash, l.tx_hash FROM transaction_logs l
+    JOIN transactions_new t ON t.block_hash = l.block_hash AND t.tx_hash = l.tx_hash
+    WHERE NOT EXISTS (
+        SELECT 1 FROM receipts_new r
+        WHERE r.block_hash = l.block_hash AND r.tx_hash = l.tx_hash);
+
+INSERT INTO transaction_logs_new (tx_hash, block_hash, log_index, data, address, topics, created_at, updated_at)
+SELECT l.tx_hash, l.block_hash, l.log_index, l.data, l.address, l.topics, l.created_at, l.updated_at
+    FROM transaction_logs l
+    JOIN receipts_new r ON r.block_hash = l.block_hash AND r.tx_hash = l.tx_hash;
+INSERT INTO internal_transactions_new (block_hash, block_number, tx_hash, position, trace_address, call_type, tx_from, tx_to, value, gas, gas_used, error, depth, created_at, updated_at)
+SELECT i.block_hash, i.block_number, i.tx_hash, i.position, i.trace_address, i.call_type, i.tx_from, i.tx_to, i.value, i.gas, i.gas_used, i.error, i.depth, i.created_at, i.updated_at
+    FROM internal_transactions i
+    JOIN transactions_new t ON t.block_hash = i.block_hash AND t.tx_hash = i.tx_hash;
+INSERT INTO contracts_new (address, block_hash, block_number, creation_tx_hash, creator, code_hash, standard, supports_erc165, created_at, updated_at)
+SELECT c.address, c.block_hash, c.block_number, c.creation_tx_hash, c.creator, c.code_hash, c.standard, c.supports_erc165, c.created_at, c.updated_at
+    FROM contracts c
+    JOIN transactions_new t ON t.block_hash = c.block_hash AND t.tx_hash = c.creation_tx_hash;
+INSERT INTO token_transfers_new (block_hash, block_number, tx_hash, log_index, token, tx_from, tx_to, amount, created_at, updated_at)
+SELECT tr.block_hash, tr.block_number, tr.tx_hash, tr.log_index, tr.token, tr.tx_from, tr.tx_to, tr.amount, tr.created_at, tr.updated_at
+    FROM token_transfers tr
+    JOIN transactions_new t ON t.block_hash = tr.block_hash AND t.tx_hash = tr.tx_hash;
+
+DROP TABLE token_transfers;
+DROP TABLE contracts;
+DROP TABLE internal_transactions;
+DROP TABLE transaction_logs;
+DROP TABLE receipts;
+DROP TABLE transactions;
+ALTER TABLE transactions_new RENAME TO transactions;
+ALTER TABLE receipts_new RENAME TO receipts;
+ALTER TABLE transaction_logs_new RENAME TO transaction_logs;
+ALTER TABLE internal_transactions_new RENAME TO internal_transactions;
+ALTER TABLE contracts_new RENAME TO contracts;
+ALTER TABLE token_transfers_new RENAME TO token_transfers;
+
+-- Transactions are still looked up by hash.
+CREATE INDEX IF NOT EXISTS transactions_tx_hash_idx ON transactions (tx_hash);
+CREATE INDEX IF NOT EXISTS transactions_block_hash_idx ON transactions (block_hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_logs_block_hash_log_index_key
+    ON transaction_logs (block_hash, log_index);
+CREATE INDEX IF NOT EXISTS transaction_logs_tx_hash_idx
+    ON transaction_logs (tx_hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS internal_transactions_block_hash_tx_hash_position_key
+    ON internal_transactions (block_hash, tx_hash, position);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_hash_idx
+    ON internal_transactions (tx_hash);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_from_idx
+    ON internal_transactions (tx_from, block_number);
+CREATE INDEX IF NOT EXISTS internal_transactions_tx_to_idx
+    ON internal_transactions (tx_to, block_number);
+
+CREATE UNIQUE INDEX IF NOT EXISTS contracts_address_block_hash_key
+    ON contracts (address, block_hash);
+CREATE INDEX IF NOT EXISTS contracts_creation_tx_hash_idx
+    ON contracts (creation_tx_hash);
+CREATE INDEX IF NOT EXISTS contracts_code_hash_idx
+    ON contracts (code_hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS token_transfers_block_hash_log_index_key
+    ON token_transfers (block_hash, log_index);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_hash_idx
+    ON token_transfers (tx_hash);
+CREATE INDEX IF NOT EXISTS token_transfers_token_idx
+    ON token_transfers (token, block_number);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_from_idx
+    ON token_transfers (tx_from, block_number);
+CREATE INDEX IF NOT EXISTS token_transfers_tx_to_idx
+    ON token_transfers (tx_to, block_number);
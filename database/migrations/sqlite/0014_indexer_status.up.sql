@@ -0,0 +1,16 @@
+-- Table: indexer_status
+-- The state of the indexer reported by /status: the chain head seen from the
+-- active RPC endpoint and the finality heights tracked. It has a single row.
+CREATE TABLE IF NOT EXISTS indexer_status
+(
+    id                 INTEGER PRIMARY KEY CHECK (id = 1),
+    chain_head         BIGINT      NOT NULL DEFAULT 0,
+    chain_head_seen_at BIGINT,
+    active_endpoint    TEXT        NOT NULL DEFAULT '',
+    finality_mode      VARCHAR(16) NOT NULL DEFAULT '',
+    safe               BIGINT,
+    finalized          BIGINT,
+
+    created_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000),
+    updated_at BIGINT DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
@@ -52,3 +52,13 @@ func (db *postgresDB) finalize() {
 func (db *postgresDB) db() interface{} {
 	return db.DB
 }
+
+// advisoryLocks returns true, PostgreSQL supports session advisory locks.
+func (db *postgresDB) advisoryLocks() bool {
+	return true
+}
+
+// copyFrom returns true, PostgreSQL supports COPY FROM STDIN.
+func (db *postgresDB) copyFrom() bool {
+	return true
+}
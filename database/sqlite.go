@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	// SQLite driver.
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+
+	"main/logging"
+)
+
+// sqliteDB is the concrete SQLite handle to a SQL database, meant for local
+// development without external services.
+type sqliteDB struct{ *gorm.DB }
+
+// initialize initializes the SQLite database handle.
+func (db *sqliteDB) initialize(ctx context.Context, cfg dbConfig) {
+	// Assemble SQLite database source with foreign keys enforced, and wait
+	// for locks held by other processes instead of failing right away.
+	dbSource := fmt.Sprintf("file:%s?_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL",
+		cfg.Path)
+
+	// Open the SQLite database.
+	var err error
+	db.DB, err = gorm.Open(cfg.Dialect, dbSource)
+	if err != nil {
+		panic(err)
+	}
+
+	// SQLite allows a single writer, so serialize access through one
+	// connection. This also keeps in-memory databases shared.
+	db.DB.DB().SetMaxOpenConns(1)
+	db.DB.DB().SetMaxIdleConns(1)
+	db.DB.DB().SetConnMaxLifetime(0)
+}
+
+// finalize finalizes the SQLite database handle.
+func (db *sqliteDB) finalize() {
+	// Close the SQLite database handle.
+	if err := db.Close(); err != nil {
+		logging.Error(dbRootCtx, "Failed to close database handle: %v", err)
+	}
+}
+
+// db returns the SQLite GORM database handle.
+func (db *sqliteDB) db() interface{} {
+	return db.DB
+}
+
+// advisoryLocks returns false, SQLite has no advisory locks.
+func (db *sqliteDB) advisoryLocks() bool {
+	return false
+}
+
+// copyFrom returns false, SQLite has no COPY.
+func (db *sqliteDB) copyFrom() bool {
+	return false
+}
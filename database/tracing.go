@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/tracing"
+)
+
+// Scope keys used to pass tracing state through GORM callbacks.
+const (
+	scopeKeyContext = "tracing:context"
+	scopeKeySpan    = "tracing:span"
+)
+
+// registerTracingCallbacks installs GORM callbacks which wrap every query in
+// a client span. The parent span is taken from the context bound to the handle
+// with GetSQLWithContext.
+func registerTracingCallbacks(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:create").
+		Register("tracing:before_create", beforeQuery("gorm.create"))
+	callbacks.Create().After("gorm:create").
+		Register("tracing:after_create", afterQuery)
+	callbacks.Query().Before("gorm:query").
+		Register("tracing:before_query", beforeQuery("gorm.query"))
+	callbacks.Query().After("gorm:query").
+		Register("tracing:after_query", afterQuery)
+	callbacks.Update().Before("gorm:update").
+		Register("tracing:before_update", beforeQuery("gorm.update"))
+	callbacks.Update().After("gorm:update").
+		Register("tracing:after_update", afterQuery)
+	callbacks.Delete().Before("gorm:delete").
+		Register("tracing:before_delete", beforeQuery("gorm.delete"))
+	callbacks.Delete().After("gorm:delete").
+		Register("tracing:after_delete", afterQuery)
+	callbacks.RowQuery().Before("gorm:row_query").
+		Register("tracing:before_row_query", beforeQuery("gorm.row_query"))
+	callbacks.RowQuery().After("gorm:row_query").
+		Register("tracing:after_row_query", afterQuery)
+}
+
+// beforeQuery returns a callback which starts a span with the given name.
+func beforeQuery(name string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		// Only trace queries issued with a context bound handle.
+		value, ok := scope.Get(scopeKeyContext)
+		if !ok {
+			return
+		}
+		ctx, ok := value.(context.Context)
+		if !ok {
+			return
+		}
+
+		_, span := tracing.StartWithKind(ctx, name, trace.SpanKindClient,
+			attribute.String("db.system", scope.Dialect().GetName()),
+			attribute.String("db.collection.name", scope.TableName()))
+		scope.Set(scopeKeySpan, span)
+	}
+}
+
+// afterQuery ends the span started by beforeQuery.
+func afterQuery(scope *gorm.Scope) {
+	value, ok := scope.Get(scopeKeySpan)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("db.query.text", scope.SQL),
+		attribute.Int64("db.rows_affected", scope.DB().RowsAffected))
+	err := scope.DB().Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = nil
+	}
+	tracing.EndWithError(span, err)
+}
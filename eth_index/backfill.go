@@ -0,0 +1,105 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"main/logging"
+	"main/store"
+)
+
+// defaultBackfillBatchSize is the number of blocks fetched in parallel when
+// syncing the latest blocks on startup.
+const defaultBackfillBatchSize = 50
+
+// errStopping is returned when syncing blocks after the module was finalized.
+var errStopping = errors.New("eth index is stopping")
+
+// Backfill syncs the blocks in the range [from, to] to DB, fetching batchSize
+// blocks in parallel, and returns once all of them are synced. Blocks are saved
+// as safe or finalized as tracked at the chain head. When the context is done or
+// the module is finalized, a backfill stops taking new blocks and drains the
+// ones in flight.
+func Backfill(ctx context.Context, from, to uint64, batchSize int) error {
+	if from > to {
+		return fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	if batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %d", batchSize)
+	}
+	if !inflight.add() {
+		return errStopping
+	}
+	defer inflight.done()
+	ctx = logging.WithRequestID(ctx, logging.NewRequestID())
+
+	// init eth client
+	endpointURL, _ := endpoints.current()
+	client, err := dialClient(ctx, endpointURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	state.setActiveEndpoint(ctx, endpointURL)
+
+	// get the chain head to check the range
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	state.setChainHead(ctx, head)
+	if to > head {
+		return fmt.Errorf("block %d is beyond the chain head %d", to, head)
+	}
+
+	logging.Info(ctx, "Backfill blocks from %d to %d", from, to)
+	return backfillRange(ctx, client, head, from, to, batchSize)
+}
+
+// backfillRange syncs the blocks in the range [from, to] through a pipeline
+// fetching the given number of blocks in parallel, and returns once the
+// blocks submitted are committed. The caller must count as an in-flight sync.
+func backfillRange(ctx context.Context, client *chainClient,
+	head, from, to uint64, workers int) error {
+	state.startBackfill(to - from + 1)
+	if err := finality.update(ctx, client, head); err != nil {
+		logging.Error(ctx, "Failed to update finality: %v", err)
+	} else if err := finality.save(ctx); err != nil {
+		logging.Error(ctx, "Failed to record finality: %v", err)
+	}
+
+	// blocks are synced under the root context, so the blocks in flight are
+	// drained rather than canceled on shutdown, and written with COPY where
+	// the database supports it
+	syncCtx := logging.WithRequestID(ethRootCtx, logging.GetRequestID(ctx))
+	syncCtx = store.WithCopy(syncCtx)
+	blocks := startPipeline(client, workers, func(job *syncJob) {
+		state.backfillBlockDone()
+	})
+
+	// submit blocks in order until the range is done or the sync stops
+	var err error
+	for num := from; ; num++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if stopCtx.Err() != nil {
+			err = errStopping
+			break
+		}
+		blocks.submit(syncCtx, num, finality.statusOf(num))
+		if num == to {
+			break
+		}
+	}
+
+	failed := blocks.close()
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %d blocks in range %d-%d", failed, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/logging"
+	"main/models"
+	"main/store"
+	"main/tracing"
+)
+
+// selectorBalanceOf is the selector of the balanceOf(address) function.
+var selectorBalanceOf = []byte{0x70, 0xa0, 0x82, 0x31}
+
+// balanceCheckBatch is the number of balances spot checked per round.
+const balanceCheckBatch = 20
+
+// decodeBalanceChanges returns the net balance changes of the holders of each
+// token in the block from its transfers, in the order first seen. Holders
+// whose transfers in and out cancel out are left out, and so is the zero
+// address tokens are minted from and burned to.
+func decodeBalanceChanges(job *syncJob, transfers []models.TokenTransferIntf) []models.BalanceChangeIntf {
+	type key struct{ token, holder string }
+	deltas := map[key]*big.Int{}
+	order := []key{}
+	add := func(token, holder string, amount *big.Int) {
+		if holder == (common.Address{}).String() {
+			return
+		}
+		k := key{token, holder}
+		delta, ok := deltas[k]
+		if !ok {
+			delta = new(big.Int)
+			deltas[k] = delta
+			order = append(order, k)
+		}
+		delta.Add(delta, amount)
+	}
+
+	for _, transfer := range transfers {
+		amount, ok := new(big.Int).SetString(transfer.GetAmount(), 10)
+		if !ok {
+			continue
+		}
+		add(transfer.GetToken(), transfer.GetTxFrom(), new(big.Int).Neg(amount))
+		add(transfer.GetToken(), transfer.GetTxTo(), amount)
+	}
+
+	blockHash := job.block.Hash().String()
+	changes := []models.BalanceChangeIntf{}
+	for _, k := range order {
+		if deltas[k].Sign() != 0 {
+			changes = append(changes, models.NewBalanceChange(
+				k.token, k.holder, blockHash, job.num, deltas[k].String()))
+		}
+	}
+	return changes
+}
+
+// RunBalanceReconciler spot checks a batch of balances against balanceOf
+// every BALANCE_CHECK_INTERVAL_MS, the least recently checked first, until
+// the context is done or the module is finalized. Balances which don't match
+// are flagged and logged.
+func RunBalanceReconciler(ctx context.Context) {
+	if !inflight.add() {
+		return
+	}
+	defer inflight.done()
+
+	// stop with the module as well
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(balanceCheckInterval)
+	defer ticker.Stop()
+	for {
+		roundCtx := logging.WithRequestID(ctx, logging.NewRequestID())
+		if err := checkDueBalances(roundCtx); err != nil && ctx.Err() == nil {
+			logging.Error(roundCtx, "Failed to check balances: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkDueBalances spot checks a batch of balances at the indexed watermark.
+func checkDueBalances(ctx context.Context) error {
+	watermark, err := GetWatermark(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	balances, err := store.Balances.GetDue(ctx, balanceCheckBatch)
+	if err != nil || len(balances) == 0 {
+		return err
+	}
+
+	endpointURL, _ := endpoints.current()
+	client, err := dialClient(ctx, endpointURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return checkBalances(ctx, client, balances, watermark)
+}
+
+// checkBalances spot checks balances at the given block, and records the
+// results. A balance whose balanceOf call reverts is recorded as checked with
+// an unknown on-chain balance. One which can't be checked, e.g. on a rate
+// limit, is left as it was, and comes up as due again.
+func checkBalances(ctx context.Context, client *chainClient,
+	balances []models.BalanceIntf, num uint64) error {
+	for _, balance := range balances {
+		if ctx.Err() != nil {
+			return nil
+		}
+		balanceCtx := logging.WithFields(ctx, logging.Fields{
+			"token":  balance.GetToken(),
+			"holder": balance.GetHolder(),
+		})
+		if err := checkBalance(balanceCtx, client, balance, num); err != nil {
+			logging.Warn(balanceCtx, "Failed to check balance: %v", err)
+			continue
+		}
+		if err := store.Balances.UpdateCheck(balanceCtx, balance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBalance calls balanceOf on the token for the holder at the given
+// block, and compares it with the indexed balance as of the block.
+func checkBalance(ctx context.Context, client *chainClient,
+	balance models.BalanceIntf, num uint64) error {
+	ctx, span := tracing.Start(ctx, "eth_index.check_balance",
+		attribute.String("token.address", balance.GetToken()),
+		attribute.String("holder.address", balance.GetHolder()))
+	indexed, err := balanceAt(ctx, balance, num)
+	if err != nil {
+		tracing.EndWithError(span, err)
+		return err
+	}
+
+	// a reverted or malformed call leaves the on-chain balance unknown
+	address := common.HexToAddress(balance.GetToken())
+	data := append(append([]byte{}, selectorBalanceOf...),
+		common.LeftPadBytes(common.HexToAddress(balance.GetHolder()).Bytes(), 32)...)
+	callCtx, callSpan := tracing.StartWithKind(ctx, "eth_call", trace.SpanKindClient,
+		attribute.String("contract.address", address.String()))
+	result, err := client.CallContract(callCtx,
+		ethereum.CallMsg{To: &address, Data: data}, new(big.Int).SetUint64(num))
+	tracing.EndWithError(callSpan, err)
+	if err != nil && !isReverted(err) {
+		tracing.EndWithError(span, err)
+		return err
+	}
+	tracing.EndWithError(span, nil)
+
+	var onchain *string
+	mismatch := false
+	value := decodeUint(result)
+	switch {
+	case err != nil:
+		logging.Warn(ctx, "Failed to call balanceOf at block %d: %v", num, err)
+	case value == nil:
+		logging.Warn(ctx, "Malformed balanceOf result at block %d", num)
+	default:
+		s := value.String()
+		onchain = &s
+		if mismatch = value.Cmp(indexed) != 0; mismatch {
+			logging.Warn(ctx, "Indexed balance %s doesn't match balanceOf %s at block %d",
+				indexed, s, num)
+		}
+	}
+	balance.SetChecked(time.Now().UnixMilli(), num, onchain, mismatch)
+	return nil
+}
+
+// balanceAt returns the indexed balance as of the given block: the current
+// balance without the changes of the canonical blocks above it.
+func balanceAt(ctx context.Context, balance models.BalanceIntf, num uint64) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(balance.GetBalance(), 10)
+	if !ok {
+		return nil, errors.New("invalid balance " + balance.GetBalance())
+	}
+	changes, err := store.Balances.GetChangesAfter(ctx,
+		balance.GetToken(), balance.GetHolder(), num)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		delta, ok := new(big.Int).SetString(change.GetDelta(), 10)
+		if !ok {
+			return nil, errors.New("invalid balance change " + change.GetDelta())
+		}
+		amount.Sub(amount, delta)
+	}
+	return amount, nil
+}
@@ -0,0 +1,97 @@
+package eth_index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/models"
+	"main/store"
+	"main/store/storetest"
+)
+
+// errorServer answers every JSON-RPC request with the given error.
+func errorServer(t *testing.T, rpcErr *string) *chainClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":%s}`, req.ID, *rpcErr)
+	}))
+	t.Cleanup(server.Close)
+	client, err := dialClient(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestCheckBalances(t *testing.T) {
+	for _, backend := range []struct {
+		name string
+		use  func(t testing.TB)
+	}{
+		{"memory", storetest.UseMemory},
+		{"sqlite", storetest.UseSQLite},
+	} {
+		t.Run(backend.name, func(t *testing.T) {
+			backend.use(t)
+			ctx := context.Background()
+			token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+			holder := common.HexToAddress("0x3000000000000000000000000000000000000003")
+			block := storetest.NewBlock(1, common.Hash{}, "")
+			block.AddTransfer(t, storetest.Tx(t, 0), 0, token, common.Address{}, holder, 10)
+			block.Commit(t)
+
+			// a balance flagged by an earlier check
+			balance := getBalance(t, token.String(), holder.String())
+			onchain := "20"
+			balance.SetChecked(1, 1, &onchain, true)
+			if err := store.Balances.UpdateCheck(ctx, balance); err != nil {
+				t.Fatalf("UpdateCheck: %v", err)
+			}
+
+			// a call which fails to execute leaves the balance as it was
+			rpcErr := `{"code":-32005,"message":"request rate limited"}`
+			client := errorServer(t, &rpcErr)
+			balance = getBalance(t, token.String(), holder.String())
+			if err := checkBalances(ctx, client, []models.BalanceIntf{balance}, 1); err != nil {
+				t.Fatalf("checkBalances: %v", err)
+			}
+			balance = getBalance(t, token.String(), holder.String())
+			if !balance.GetMismatch() || balance.GetOnchainBalance() == nil ||
+				*balance.GetOnchainBalance() != onchain || balance.GetCheckedAt() != 1 {
+				t.Errorf("balance = %+v, want the earlier mismatch kept", balance)
+			}
+
+			// a reverted call leaves the on-chain balance unknown
+			rpcErr = `{"code":3,"message":"execution reverted"}`
+			if err := checkBalances(ctx, client, []models.BalanceIntf{balance}, 1); err != nil {
+				t.Fatalf("checkBalances: %v", err)
+			}
+			balance = getBalance(t, token.String(), holder.String())
+			if balance.GetMismatch() || balance.GetOnchainBalance() != nil ||
+				balance.GetCheckedAt() == 1 {
+				t.Errorf("balance = %+v, want checked with an unknown balance", balance)
+			}
+		})
+	}
+}
+
+// getBalance returns the stored balance of a holder of a token.
+func getBalance(t *testing.T, token, holder string) models.BalanceIntf {
+	t.Helper()
+	balances, err := store.Balances.GetByToken(context.Background(), token, []string{holder})
+	if err != nil || len(balances) != 1 {
+		t.Fatalf("GetByToken = %v, %v, want one balance", balances, err)
+	}
+	return balances[0]
+}
@@ -0,0 +1,127 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"main/logging"
+	"main/store"
+)
+
+// Delays between catch-up attempts while blocks keep failing to sync.
+const (
+	minCatchUpDelay = time.Second
+	maxCatchUpDelay = time.Minute
+)
+
+// catchUpRequests is signaled when a block failed to sync, so that the blocks
+// from the indexed watermark up to the chain head are synced again.
+var catchUpRequests = make(chan struct{}, 1)
+
+// requestCatchUp asks RunCatchUp to sync the blocks following the indexed
+// watermark. Requests made while one is pending are merged.
+func requestCatchUp() {
+	select {
+	case catchUpRequests <- struct{}{}:
+	default:
+	}
+}
+
+// RunCatchUp syncs the blocks from the indexed watermark up to the chain head
+// whenever a block failed to sync, until the context is done or the module is
+// finalized. If syncLatest is set, it also does so on start, along with the
+// latest COMFIRMED_BLOCK blocks. A catch-up is retried with a backoff delay
+// until the watermark reaches the head it started at.
+func RunCatchUp(ctx context.Context, syncLatest bool) {
+	if syncLatest {
+		if err := catchUp(ctx, true); err != nil {
+			logging.Error(ctx, "Failed to sync the latest blocks: %v", err)
+			requestCatchUp()
+		}
+	}
+
+	delay := minCatchUpDelay
+	for {
+		select {
+		case <-catchUpRequests:
+		case <-ctx.Done():
+			return
+		case <-stopCtx.Done():
+			return
+		}
+
+		// let the failure clear before fetching the blocks again
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		case <-stopCtx.Done():
+			return
+		}
+		if err := catchUp(ctx, false); err != nil {
+			logging.Error(ctx, "Catch-up failed, retrying in %v: %v", delay*2, err)
+			if delay *= 2; delay > maxCatchUpDelay {
+				delay = maxCatchUpDelay
+			}
+			requestCatchUp()
+			continue
+		}
+		delay = minCatchUpDelay
+	}
+}
+
+// catchUp syncs the blocks from the indexed watermark up to the chain head
+// through the active endpoint, and the latest COMFIRMED_BLOCK blocks if latest
+// is set.
+func catchUp(ctx context.Context, latest bool) error {
+	if !inflight.add() {
+		return errStopping
+	}
+	defer inflight.done()
+	ctx = logging.WithRequestID(ctx, logging.NewRequestID())
+
+	// init eth client
+	endpointURL, _ := endpoints.current()
+	client, err := dialClient(ctx, endpointURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	state.setActiveEndpoint(ctx, endpointURL)
+
+	return catchUpTo(ctx, client, latest)
+}
+
+// catchUpTo syncs the blocks following the indexed watermark up to the chain
+// head of the client, and the latest COMFIRMED_BLOCK blocks if latest is set.
+// Without a watermark, nothing is indexed yet and only the latest blocks are.
+// The caller must count as an in-flight sync.
+func catchUpTo(ctx context.Context, client *chainClient, latest bool) error {
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	state.setChainHead(ctx, head)
+
+	from := head + 1
+	if latest && comfirmedBlock > 0 {
+		from = 0
+		if head >= comfirmedBlock {
+			from = head - comfirmedBlock + 1
+		}
+	}
+	watermark, err := GetWatermark(ctx)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if err == nil && watermark < from {
+		from = watermark + 1
+	}
+	if from > head {
+		return nil
+	}
+
+	logging.Info(ctx, "Catch up blocks from %d to %d", from, head)
+	return backfillRange(ctx, client, head, from, head, defaultBackfillBatchSize)
+}
@@ -0,0 +1,53 @@
+package eth_index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"main/store/storetest"
+)
+
+func TestCatchUp(t *testing.T) {
+	storetest.UseMemory(t)
+	ctx := context.Background()
+	ethRootCtx, stopCtx = ctx, ctx
+	t.Cleanup(func() { ethRootCtx, stopCtx = nil, nil })
+
+	// block 1 is indexed, and the node is at block 4
+	blocks := map[string]*storetest.Block{}
+	parent := common.Hash{}
+	for num := uint64(1); num <= 4; num++ {
+		block := storetest.NewBlock(num, parent, "")
+		blocks[hexutil.EncodeUint64(num)] = block
+		parent = block.Hash()
+	}
+	blocks["0x1"].Commit(t)
+
+	// block 3 fails to sync, holding the watermark back and requesting a
+	// catch-up
+	failing := map[string]*storetest.Block{
+		"0x1": blocks["0x1"], "0x2": blocks["0x2"], "0x4": blocks["0x4"],
+	}
+	if err := catchUpTo(ctx, chainServer(t, failing), false); err == nil {
+		t.Fatal("catch-up with a failing block succeeded")
+	}
+	select {
+	case <-catchUpRequests:
+	default:
+		t.Error("failed block didn't request a catch-up")
+	}
+	if watermark, err := GetWatermark(ctx); err != nil || watermark != 2 {
+		t.Fatalf("watermark is %d, %v, want 2", watermark, err)
+	}
+
+	// the next catch-up syncs the blocks from the watermark up to the head
+	if err := catchUpTo(ctx, chainServer(t, blocks), false); err != nil {
+		t.Fatalf("catch-up: %v", err)
+	}
+	if watermark, err := GetWatermark(ctx); err != nil || watermark != 4 {
+		t.Fatalf("watermark is %d, %v, want 4", watermark, err)
+	}
+}
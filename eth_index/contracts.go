@@ -0,0 +1,251 @@
+package eth_index
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/models"
+	"main/tracing"
+)
+
+// ERC-165 interface IDs. The ID of ERC-165 itself is the selector of
+// supportsInterface(bytes4).
+var (
+	interfaceERC165  = [4]byte{0x01, 0xff, 0xc9, 0xa7}
+	interfaceInvalid = [4]byte{0xff, 0xff, 0xff, 0xff}
+	interfaceERC721  = [4]byte{0x80, 0xac, 0x58, 0xcd}
+	interfaceERC1155 = [4]byte{0xd9, 0xb6, 0x7a, 0x26}
+)
+
+// Function selectors looked for in the bytecode of contracts which don't
+// implement ERC-165. A contract is classified by the first standard all the
+// selectors of which it dispatches on.
+var standardSelectors = []struct {
+	standard  models.ContractStandard
+	selectors [][4]byte
+}{
+	{models.StandardERC1155, [][4]byte{
+		{0x00, 0xfd, 0xd5, 0x8e}, // balanceOf(address,uint256)
+		{0x4e, 0x12, 0x73, 0xf4}, // balanceOfBatch(address[],uint256[])
+		{0xf2, 0x42, 0x43, 0x2a}, // safeTransferFrom(address,address,uint256,uint256,bytes)
+		{0x2e, 0xb2, 0xc2, 0xd6}, // safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)
+	}},
+	{models.StandardERC721, [][4]byte{
+		{0x63, 0x52, 0x21, 0x1e}, // ownerOf(uint256)
+		{0x42, 0x84, 0x2e, 0x0e}, // safeTransferFrom(address,address,uint256)
+		{0x08, 0x18, 0x12, 0xfc}, // getApproved(uint256)
+		{0xa2, 0x2c, 0xb4, 0x65}, // setApprovalForAll(address,bool)
+	}},
+	{models.StandardERC20, [][4]byte{
+		{0x18, 0x16, 0x0d, 0xdd}, // totalSupply()
+		{0x70, 0xa0, 0x82, 0x31}, // balanceOf(address)
+		{0xa9, 0x05, 0x9c, 0xbb}, // transfer(address,uint256)
+		{0x23, 0xb8, 0x72, 0xdd}, // transferFrom(address,address,uint256)
+		{0x09, 0x5e, 0xa7, 0xb3}, // approve(address,uint256)
+		{0xdd, 0x62, 0xed, 0x3e}, // allowance(address,address)
+	}},
+}
+
+// opPush1 is the opcode pushing the byte following it. PUSHn is opPush1+n-1.
+const opPush1 = 0x60
+
+// deployment is a contract deployed by a transaction.
+type deployment struct {
+	address common.Address
+	creator common.Address
+	txHash  common.Hash
+}
+
+// findContracts is the contracts stage, getting the bytecode of the contracts
+// deployed in the block and classifying them. Contracts deployed by internal
+// CREATE or CREATE2 calls are only found if the block was traced. The code
+// and interfaces are read at the latest block, as nodes which aren't archive
+// nodes don't keep the state of older blocks, so contracts destroyed since
+// have no bytecode.
+func (p *pipeline) findContracts(job *syncJob) {
+	if job.err != nil || job.indexed {
+		return
+	}
+	deployments, err := findDeployments(job)
+	if err != nil {
+		job.err = err
+		return
+	}
+	if len(deployments) == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(job.ctx, "eth_index.find_contracts",
+		attribute.Int("block.contracts", len(deployments)))
+	defer func() { tracing.EndWithError(span, job.err) }()
+	blockHash := job.block.Hash().String()
+	seen := map[string]bool{}
+	for _, d := range deployments {
+		bytecode, err := latestCode(ctx, p.client, d.address)
+		if err != nil {
+			job.err = fmt.Errorf("failed to get the code of contract %s: %w", d.address, err)
+			return
+		}
+		standard, supportsERC165, err := classifyContract(ctx, p.client, d.address, bytecode)
+		if err != nil {
+			job.err = fmt.Errorf("failed to classify contract %s: %w", d.address, err)
+			return
+		}
+
+		// store the bytecode once per code hash
+		code := models.NewContractCode(bytecode)
+		if !seen[code.GetCodeHash()] {
+			seen[code.GetCodeHash()] = true
+			job.code = append(job.code, code)
+		}
+		job.contracts = append(job.contracts, models.NewContract(
+			d.address.String(), blockHash, job.num, d.txHash.String(),
+			d.creator.String(), code.GetCodeHash(), standard, supportsERC165))
+	}
+}
+
+// findDeployments returns the contracts deployed in the block: by successful
+// contract creation transactions, and by CREATE or CREATE2 calls which
+// weren't reverted, if the block was traced.
+func findDeployments(job *syncJob) ([]deployment, error) {
+	receipts := make(map[common.Hash]*types.Receipt, len(job.receipts))
+	for _, receipt := range job.receipts {
+		receipts[receipt.TxHash] = receipt
+	}
+
+	deployments := []deployment{}
+	for i, tx := range job.block.Transactions() {
+		receipt := receipts[tx.Hash()]
+		if tx.To() == nil && receipt != nil &&
+			receipt.Status == types.ReceiptStatusSuccessful {
+			from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+			if err != nil {
+				return nil, err
+			}
+			deployments = append(deployments, deployment{
+				address: receipt.ContractAddress, creator: from, txHash: tx.Hash()})
+		}
+		if job.traces == nil || job.traces[i].Result.Error != "" {
+			continue
+		}
+
+		// walk the call tree, skipping the calls of reverted calls
+		var walk func(calls []callFrame)
+		walk = func(calls []callFrame) {
+			for _, call := range calls {
+				if call.Error != "" {
+					continue
+				}
+				callType := strings.ToUpper(call.Type)
+				if (callType == "CREATE" || callType == "CREATE2") && call.To != nil {
+					deployments = append(deployments, deployment{
+						address: *call.To, creator: call.From, txHash: tx.Hash()})
+				}
+				walk(call.Calls)
+			}
+		}
+		walk(job.traces[i].Result.Calls)
+	}
+	return deployments, nil
+}
+
+// latestCode returns the code of a contract at the latest block.
+func latestCode(ctx context.Context, client *chainClient,
+	address common.Address) ([]byte, error) {
+	ctx, span := tracing.StartWithKind(ctx, "eth_getCode", trace.SpanKindClient,
+		attribute.String("contract.address", address.String()))
+	bytecode, err := client.CodeAt(ctx, address, nil)
+	tracing.EndWithError(span, err)
+	return bytecode, err
+}
+
+// classifyContract returns the standard a contract implements and whether it
+// implements ERC-165. Contracts implementing ERC-165 are asked which
+// interfaces they support, others are classified by the selectors in their
+// bytecode.
+func classifyContract(ctx context.Context, client *chainClient,
+	address common.Address, bytecode []byte) (models.ContractStandard, bool, error) {
+	if len(bytecode) == 0 {
+		return models.StandardNone, false, nil
+	}
+
+	// ERC-165 contracts support its interface, and not the invalid one
+	supports := func(id [4]byte) (bool, error) {
+		return supportsInterface(ctx, client, address, id)
+	}
+	supportsERC165, err := supports(interfaceERC165)
+	if err == nil && supportsERC165 {
+		var invalid bool
+		invalid, err = supports(interfaceInvalid)
+		supportsERC165 = !invalid
+	}
+	if err != nil {
+		return models.StandardNone, false, err
+	}
+	if supportsERC165 {
+		for _, i := range []struct {
+			id       [4]byte
+			standard models.ContractStandard
+		}{{interfaceERC1155, models.StandardERC1155}, {interfaceERC721, models.StandardERC721}} {
+			ok, err := supports(i.id)
+			if err != nil {
+				return models.StandardNone, false, err
+			}
+			if ok {
+				return i.standard, true, nil
+			}
+		}
+	}
+
+	// fall back to the selectors dispatched on, e.g. for ERC-20 tokens
+	for _, s := range standardSelectors {
+		if hasSelectors(bytecode, s.selectors) {
+			return s.standard, supportsERC165, nil
+		}
+	}
+	return models.StandardNone, supportsERC165, nil
+}
+
+// supportsInterface calls supportsInterface(bytes4) on a contract at the
+// latest block. A reverted call means the interface isn't supported, other
+// errors are returned.
+func supportsInterface(ctx context.Context, client *chainClient,
+	address common.Address, id [4]byte) (bool, error) {
+	data := make([]byte, 0, 36)
+	data = append(data, interfaceERC165[:]...)
+	data = append(data, id[:]...)
+	data = append(data, make([]byte, 28)...)
+
+	ctx, span := tracing.StartWithKind(ctx, "eth_call", trace.SpanKindClient,
+		attribute.String("contract.address", address.String()))
+	result, err := client.CallContract(ctx, ethereum.CallMsg{To: &address, Data: data}, nil)
+	tracing.EndWithError(span, err)
+	if isReverted(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(result) == 32 && new(big.Int).SetBytes(result).Cmp(big.NewInt(1)) == 0, nil
+}
+
+// hasSelectors returns whether the bytecode pushes all the selectors. The
+// leading zero bytes of a selector are pushed with a shorter PUSH.
+func hasSelectors(bytecode []byte, selectors [][4]byte) bool {
+	for _, selector := range selectors {
+		value := bytes.TrimLeft(selector[:], "\x00")
+		push := append([]byte{byte(opPush1 + len(value) - 1)}, value...)
+		if !bytes.Contains(bytecode, push) {
+			return false
+		}
+	}
+	return true
+}
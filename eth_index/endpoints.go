@@ -0,0 +1,84 @@
+package eth_index
+
+import (
+	"sync"
+
+	"main/config"
+)
+
+// endpointSet is the list of RPC endpoints used for failover. The HTTP and
+// websocket endpoints at the active index are used together, wrapping around
+// the shorter list.
+type endpointSet struct {
+	sync.Mutex
+	http    []string
+	ws      []string
+	active  int
+	changed chan struct{}
+}
+
+// endpoints is the singleton RPC endpoint set.
+var endpoints = &endpointSet{changed: make(chan struct{}, 1)}
+
+// current returns the active HTTP and websocket endpoints.
+func (s *endpointSet) current() (string, string) {
+	s.Lock()
+	defer s.Unlock()
+	return s.http[s.active%len(s.http)], s.ws[s.active%len(s.ws)]
+}
+
+// failover switches to the next endpoints in the lists.
+func (s *endpointSet) failover() {
+	s.Lock()
+	defer s.Unlock()
+	s.active = (s.active + 1) % maxInt(len(s.http), len(s.ws))
+}
+
+// update replaces the endpoint lists. If they differ from the current lists,
+// the first endpoints become active and a change is signaled, unless no lists
+// were set yet.
+func (s *endpointSet) update(http, ws []string) {
+	s.Lock()
+	defer s.Unlock()
+	if equalStrings(s.http, http) && equalStrings(s.ws, ws) {
+		return
+	}
+	initial := len(s.http) == 0 && len(s.ws) == 0
+	s.http, s.ws, s.active = http, ws, 0
+	if initial {
+		return
+	}
+
+	// Signal the change without blocking if one is already pending.
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// reloadEndpoints applies reloaded endpoint lists.
+func reloadEndpoints(cfg *config.Config) {
+	endpoints.update(config.SplitList(cfg.Indexer.Endpoint),
+		config.SplitList(cfg.Indexer.WSEndpoint))
+}
+
+// equalStrings returns whether both slices hold the same strings.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// maxInt returns the larger of a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
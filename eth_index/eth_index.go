@@ -1,17 +1,19 @@
 package eth_index
 
 import (
-	"fmt"
 	"main/config"
-	"main/database"
+	"main/lifecycle"
 	"main/logging"
 	"main/models"
+	"main/tracing"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -19,123 +21,188 @@ import (
 
 // Static configuration variables initalized at runtime.
 var comfirmedBlock uint64
-var endpointURL string
-var wsEndpointURL string
+var traceInternalTxs bool
+var tokenResolveInterval time.Duration
+var tokenRefreshInterval time.Duration
+var balanceCheckInterval time.Duration
+
+// realtimeWorkers is the number of blocks fetched in parallel on new heads.
+const realtimeWorkers = 4
+
+// Delays between attempts to resubscribe after a failure.
+const (
+	minResubscribeDelay = time.Second
+	maxResubscribeDelay = 30 * time.Second
+)
 
-// eth index root context.
+// eth index root context, canceled when in-flight block syncs are abandoned,
+// and the context canceled once the indexer stops taking new blocks.
 var ethRootCtx context.Context
 var ethCancel context.CancelFunc
+var stopCtx context.Context
+var stopCancel context.CancelFunc
 
 // init loads the logging configurations.
 func init() {
 	comfirmedBlock = config.GetUint64("COMFIRMED_BLOCK")
-	endpointURL = config.GetString("INFURA_ENDPOINT")
-	wsEndpointURL = config.GetString("INFURA_WS_ENDPOINT")
+	traceInternalTxs = config.GetBool("TRACE_INTERNAL_TRANSACTIONS")
+	tokenResolveInterval = config.GetMilliseconds("TOKEN_RESOLVE_INTERVAL_MS")
+	tokenRefreshInterval = config.GetMilliseconds("TOKEN_REFRESH_INTERVAL_MS")
+	balanceCheckInterval = config.GetMilliseconds("BALANCE_CHECK_INTERVAL_MS")
+	endpoints.update(
+		config.SplitList(config.GetString("INFURA_ENDPOINT")),
+		config.SplitList(config.GetString("INFURA_WS_ENDPOINT")))
+	config.Subscribe(reloadEndpoints)
 }
 
-// Initialize initializes the logger module.
+// Initialize initializes the eth index module. It must be called before
+// syncing any blocks.
 func Initialize(ctx context.Context) {
 	// init root context and cancel function
 	ethRootCtx, ethCancel = context.WithCancel(ctx)
+	stopCtx, stopCancel = context.WithCancel(ethRootCtx)
+
+	// drain in-flight block syncs on shutdown
+	lifecycle.Register("eth_index", Finalize)
+}
+
+// RunRealtimeSync subscribes to new heads and syncs them to DB until the
+// context is done or the module is finalized.
+func RunRealtimeSync(ctx context.Context) {
+	if !inflight.add() {
+		return
+	}
+	defer inflight.done()
+
+	// stop with the module as well
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
 	// connect to rpc endpoints and sync
-	go subscribeAndSync(ethRootCtx, endpointURL, wsEndpointURL)
+	subscribeAndSync(ctx)
 }
 
-// Finalize finalizes the logging module.
-func Finalize() {
+// Finalize stops taking new blocks and waits for in-flight block syncs until
+// the context is done, then cancels the ones left.
+func Finalize(ctx context.Context) error {
+	// stop subscriptions and backfills
+	stopCancel()
+	inflight.close()
+
+	// wait for in-flight block syncs
+	err := inflight.wait(ctx)
+	if err != nil {
+		logging.Warn(ctx, "Abandoning in-flight block syncs: %v", err)
+	}
 	ethCancel()
+
+	return err
 }
 
-// subscribeAndSync subscribe to ws endpoints and sync latest block to DB
-func subscribeAndSync(ctx context.Context, endpointURL, wsEndpointURL string) {
-	headers := make(chan *types.Header)
-	defer close(headers)
+// subscribeAndSync keeps a new head subscription alive, failing over to the
+// next endpoints on errors and reconnecting when the endpoints are reloaded
+func subscribeAndSync(ctx context.Context) {
+	delay := minResubscribeDelay
+	for {
+		err := subscribe(ctx)
+		if ctx.Err() != nil {
+			logging.Info(ctx, "stop subscription")
+			return
+		}
+		if err == nil {
+			// endpoints changed, reconnect immediately
+			delay = minResubscribeDelay
+			continue
+		}
+
+		// fail over to the next endpoints after a backoff delay
+		logging.Error(ctx, "Subscription failed, retrying in %v: %v", delay, err)
+		endpoints.failover()
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		if delay *= 2; delay > maxResubscribeDelay {
+			delay = maxResubscribeDelay
+		}
+	}
+}
+
+// subscribe subscribes to new heads of the active endpoints and syncs them to
+// DB until the subscription fails, the endpoints change or ctx is done
+func subscribe(ctx context.Context) error {
+	endpointURL, wsEndpointURL := endpoints.current()
 
 	// connect to infura endpoint
-	client, err := ethclient.Dial(endpointURL)
+	client, err := dialClient(ctx, endpointURL)
 	if err != nil {
-		logging.Error(ctx, err.Error())
+		return err
 	}
 	defer client.Close()
 
 	// connect to infura ws endpoint
-	wsclient, err := ethclient.Dial(wsEndpointURL)
+	wsclient, err := ethclient.DialContext(ctx, wsEndpointURL)
 	if err != nil {
-		logging.Error(ctx, err.Error())
+		return err
 	}
 	defer wsclient.Close()
 
+	// sync blocks through a pipeline drained before closing the client
+	blocks := startPipeline(client, realtimeWorkers, nil)
+	defer blocks.close()
+
 	// subscribe for new block head
-	sub, err := wsclient.SubscribeNewHead(context.Background(), headers)
+	headers := make(chan *types.Header)
+	sub, err := wsclient.SubscribeNewHead(ctx, headers)
 	if err != nil {
-		logging.Error(ctx, err.Error())
+		return err
 	}
 	defer sub.Unsubscribe()
+	state.setActiveEndpoint(ctx, endpointURL)
+	logging.Info(ctx, "Subscribed to new heads at %s", redactURL(wsEndpointURL))
 
 	// continuously sync latest block to DB
-SYNC:
 	for {
 		select {
 		case err := <-sub.Err():
-			logging.Error(ctx, err.Error())
+			return err
 		case header := <-headers:
-			go getBlockAndSync(ctx, client, header.Number.Uint64(), false)
-			go getBlockAndSync(ctx, client, header.Number.Uint64()-comfirmedBlock, true)
+			num := header.Number.Uint64()
+			state.setChainHead(ctx, num)
+			// sync under the root context, so in-flight syncs are drained
+			// rather than canceled once the subscription stops
+			syncCtx := logging.WithRequestID(ethRootCtx, logging.NewRequestID())
+			if err := finality.update(syncCtx, client, num); err != nil {
+				logging.Error(syncCtx, "Failed to update finality: %v", err)
+			} else if err := finality.save(syncCtx); err != nil {
+				logging.Error(syncCtx, "Failed to record finality: %v", err)
+			}
+			// sync blocks which became safe or finalized again, raising
+			// their status as they're committed
+			if err := finality.resync(syncCtx, blocks); err != nil {
+				logging.Error(syncCtx, "Failed to resync finalized blocks: %v", err)
+			}
+			blocks.submit(syncCtx, num, finality.statusOf(num))
+		case <-endpoints.changed:
+			logging.Info(ctx, "RPC endpoints changed, reconnecting")
+			return nil
 		case <-ctx.Done():
-			logging.Info(ctx, "stop subscription")
-			break SYNC
-		}
-	}
-}
-
-// getBlockAndSync get 1 block and sync block, transactions, receipt, logs to DB
-func getBlockAndSync(
-	ctx context.Context, client *ethclient.Client, blockNum uint64, stable bool) {
-	blocksCh := getBlocks(ctx, client, []uint64{blockNum})
-
-	block := <-blocksCh
-	db := database.GetSQL()
-	logging.Info(ctx, fmt.Sprintf("Real time Sync block: %d", block.Number().Uint64()))
-
-	// check if the block in DB should be replace
-	saveBlock := compareHashAndUpdate(ctx, db, block)
-	if !saveBlock {
-		return
-	}
-
-	// get block model instance and sync to DB
-	newBlock := models.NewBlock(block)
-	newBlock.SetStable(stable)
-	if err := newBlock.SetBlock(db); err != nil {
-		logging.Error(ctx, err.Error())
-	}
-
-	// sync transactions to DB
-	blockHash := block.Header().Hash().String()
-	saveTransactions(ctx, db, block.Transactions(), blockHash)
-
-	// get receipts by tx hashes
-	receiptCh := getReceipt(ctx, client, block.Transactions())
-	for receipt := range receiptCh {
-		// get receipt model instance and sync to DB
-		newReceipt, err := models.NewReceipt(receipt)
-		if err != nil {
-			logging.Error(ctx, err.Error())
-			continue
+			return nil
 		}
-		if err := newReceipt.SetReceipt(db); err != nil {
-			logging.Error(ctx, err.Error())
-		}
-
-		saveTransactionLogs(ctx, db, receipt.Logs, receipt.TxHash.String())
 	}
 }
 
 // getBlocks parallelly get blocks by provided block numbers
 // return a channel contains blocks
 func getBlocks(
-	ctx context.Context, client *ethclient.Client, blockNums []uint64) chan *types.Block {
+	ctx context.Context, client *chainClient, blockNums []uint64) chan *types.Block {
 	ret := make(chan *types.Block, len(blockNums))
 	wg := sync.WaitGroup{}
 	wg.Add(len(blockNums))
@@ -144,8 +211,11 @@ func getBlocks(
 	for _, num := range blockNums {
 		go func(ctx context.Context, num uint64) {
 			defer wg.Done()
+			ctx, span := tracing.StartWithKind(ctx, "eth_getBlockByNumber",
+				trace.SpanKindClient, attribute.Int64("block.number", int64(num)))
 			n := new(big.Int).SetUint64(num)
 			block, err := client.BlockByNumber(ctx, n)
+			tracing.EndWithError(span, err)
 			if err != nil {
 				logging.Error(ctx, err.Error())
 				return
@@ -169,7 +239,7 @@ func getBlocks(
 // getReceipt parallelly get receipts by provided tx hash
 // return a channel contains receipts
 func getReceipt(
-	ctx context.Context, client *ethclient.Client, txHashes []*types.Transaction) chan *types.Receipt {
+	ctx context.Context, client *chainClient, txHashes []*types.Transaction) chan *types.Receipt {
 	ret := make(chan *types.Receipt, len(txHashes))
 	wg := sync.WaitGroup{}
 	wg.Add(len(txHashes))
@@ -178,7 +248,10 @@ func getReceipt(
 	for _, txHash := range txHashes {
 		go func(txHash common.Hash) {
 			defer wg.Done()
+			ctx, span := tracing.StartWithKind(ctx, "eth_getTransactionReceipt",
+				trace.SpanKindClient, attribute.String("tx.hash", txHash.String()))
 			receipt, err := client.TransactionReceipt(ctx, txHash)
+			tracing.EndWithError(span, err)
 			if err != nil {
 				logging.Error(ctx, err.Error())
 				return
@@ -199,133 +272,45 @@ func getReceipt(
 	return ret
 }
 
-// compareHashAndUpdate compare the requested block hash with block hash in DB
-// if the hashes are the same, update the block in DB to stable
-// else delete the block data in DB
-// returns if the requested block should be save
-func compareHashAndUpdate(ctx context.Context, db *gorm.DB, block *types.Block) bool {
-	old, err := models.Block.GetByNumber(db, block.Number().Uint64())
-	// DB error, shouldn't save the block
-	if err != nil && err != gorm.ErrRecordNotFound {
-		logging.Error(ctx, err.Error())
-		return false
-	}
-	// if hashes are the same, update the block to stable
-	// and don't have to update other data
-	if old != nil && old.GetHash() == block.Hash().String() {
-		if err := old.UpdateBlockStable(db, true); err != nil {
-			logging.Error(ctx, err.Error())
-		}
-		return false
-	}
-	// if hashes not matching, delete the block in the DB
-	if old != nil && old.GetHash() != block.Hash().String() {
-		logging.Info(ctx, fmt.Sprintf("delete: %d\n", old.GetNumber()))
-		// delete fail, shouldn't save the block
-		if err := old.DeleteBlock(db); err != nil {
-			logging.Error(ctx, err.Error())
-			return false
-		}
-		// delete successful, save this newer block
-		return true
-	}
-	// old == nil, save this newer block
-	return true
-}
-
-// SyncLastestBlocks Sync latest N blocks to DB
-func SyncLastestBlocks(ctx context.Context) {
-	logging.Info(ctx, fmt.Sprintf("Sync latest %d blocks...", comfirmedBlock))
-	// init eth client
-	client, err := ethclient.Dial(endpointURL)
-	if err != nil {
-		panic(err)
-	}
-	defer client.Close()
-
-	// get lastest N block numbers
-	num, err := client.BlockNumber(ctx)
-	if err != nil {
-		logging.Critical(ctx, err.Error())
-		return
-	}
-
-	logging.Info(ctx, fmt.Sprintf(
-		"latest block num: %d\nSync blocks from %d to %d",
-		num, num-comfirmedBlock, num))
-
-	blockNums := make([]uint64, comfirmedBlock)
-	for i := uint64(0); i < comfirmedBlock; i++ {
-		blockNums[i] = num - i
-	}
-
-	// query latest N block parallelly
-	blockCh := getBlocks(ctx, client, blockNums)
-
-	// sync to DB ...
-	db := database.GetSQL()
-	for block := range blockCh {
-		logging.Info(ctx, fmt.Sprintf("Sync block [%d]\n", block.Number().Uint64()))
-
-		saveBlock := compareHashAndUpdate(ctx, db, block)
-		if !saveBlock {
-			continue
-		}
-
-		go func(ctx context.Context, db *gorm.DB, block *types.Block) {
-			// get block model instance and sync to DB
-			newBlock := models.NewBlock(block)
-			if err := newBlock.SetBlock(db); err != nil {
-				logging.Error(ctx, err.Error())
-			}
-			// sync transactions to DB
-			blockHash := block.Header().Hash().String()
-			saveTransactions(ctx, db, block.Transactions(), blockHash)
-
-			// get receipts by tx hashes
-			receiptCh := getReceipt(ctx, client, block.Transactions())
-			for receipt := range receiptCh {
-				// get receipt model instance and sync to DB
-				newReceipt, err := models.NewReceipt(receipt)
-				if err != nil {
-					logging.Error(ctx, err.Error())
-					continue
-				}
-				if err := newReceipt.SetReceipt(db); err != nil {
-					logging.Error(ctx, err.Error())
-				}
-
-				saveTransactionLogs(ctx, db, receipt.Logs, receipt.TxHash.String())
-			}
-		}(ctx, db, block)
-
-	}
-}
-
-func saveTransactions(
-	ctx context.Context, db *gorm.DB, transactions []*types.Transaction, blockHash string) {
+// decodeTransactions converts transactions to model instances
+func decodeTransactions(
+	ctx context.Context, transactions []*types.Transaction, blockHash string) []models.TransactionIntf {
+	ret := make([]models.TransactionIntf, 0, len(transactions))
 	for _, t := range transactions {
 		newTransaction, err := models.NewTransaction(t, blockHash)
 		if err != nil {
 			logging.Error(ctx, err.Error())
 			continue
 		}
-		if err := newTransaction.SetTransaction(db); err != nil {
-			logging.Error(ctx, err.Error())
-		}
+		ret = append(ret, newTransaction)
 	}
+
+	return ret
 }
 
-func saveTransactionLogs(
-	ctx context.Context, db *gorm.DB, TxLogs []*types.Log, txHash string) {
-	for _, txLog := range TxLogs {
-		newTxLog, err := models.NewTransactionLog(txLog, txHash)
+// decodeReceipts converts receipts and their logs to model instances
+func decodeReceipts(ctx context.Context, receipts []*types.Receipt) (
+	[]models.ReceiptIntf, []models.TransactionLogIntf) {
+	newReceipts := make([]models.ReceiptIntf, 0, len(receipts))
+	newLogs := []models.TransactionLogIntf{}
+	for _, receipt := range receipts {
+		newReceipt, err := models.NewReceipt(receipt)
 		if err != nil {
 			logging.Error(ctx, err.Error())
 			continue
 		}
-		if err := newTxLog.SetTransactionLog(db); err != nil {
-			logging.Error(ctx, err.Error())
+		newReceipts = append(newReceipts, newReceipt)
+
+		txHash := receipt.TxHash.String()
+		for _, txLog := range receipt.Logs {
+			newTxLog, err := models.NewTransactionLog(txLog, txHash)
+			if err != nil {
+				logging.Error(ctx, err.Error())
+				continue
+			}
+			newLogs = append(newLogs, newTxLog)
 		}
 	}
+
+	return newReceipts, newLogs
 }
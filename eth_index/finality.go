@@ -0,0 +1,243 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/logging"
+	"main/models"
+	"main/store"
+	"main/tracing"
+)
+
+// Finality modes, telling how the safe and finalized heights are decided.
+const (
+	// The heights are the node's safe and finalized block tags.
+	FinalityTags = "tags"
+	// Blocks COMFIRMED_BLOCK deep are both safe and finalized, for nodes
+	// which don't support the tags.
+	FinalityDepth = "depth"
+)
+
+// maxFinalityResync is the maximum number of indexed blocks synced again as
+// safe, and as finalized, on a new head to check that they are still on the
+// canonical chain before raising their status.
+const maxFinalityResync = 128
+
+// Finality describes the safe and finalized heights tracked by the indexer.
+type Finality struct {
+	Mode      string  `json:"mode"`
+	Safe      *uint64 `json:"safe"`
+	Finalized *uint64 `json:"finalized"`
+}
+
+// finalityTracker tracks the safe and finalized heights of the chain. The
+// heights only move up.
+type finalityTracker struct {
+	sync.RWMutex
+	mode         string
+	safe         uint64
+	finalized    uint64
+	hasSafe      bool
+	hasFinalized bool
+	// tagged is set once the node served the tags, after which the depth
+	// isn't used anymore.
+	tagged bool
+}
+
+// finality is the singleton finality tracker.
+var finality = &finalityTracker{}
+
+// update polls the safe and finalized tags of the node at the given chain
+// head, falling back to the confirmation depth if the node doesn't support
+// them. On network and other node errors the heights are left as they are,
+// and so they are if the node stops supporting the tags it served before, as
+// the depth could overtake the heights the tags reported.
+func (f *finalityTracker) update(ctx context.Context, client *chainClient, head uint64) error {
+	mode := FinalityTags
+	safe, err := headerNumberByTag(ctx, client, "safe")
+	var finalized uint64
+	if err == nil {
+		finalized, err = headerNumberByTag(ctx, client, "finalized")
+	}
+	unsupported := isUnsupportedTag(err)
+	if err != nil && !unsupported {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if unsupported {
+		if f.tagged {
+			logging.Warn(ctx, "Node stopped serving safe and finalized tags, keeping the heights: %v", err)
+			return nil
+		}
+		mode = FinalityDepth
+	} else {
+		f.tagged = true
+	}
+	if f.mode != mode {
+		logging.Info(ctx, "Tracking finality by %s", mode)
+		f.mode = mode
+	}
+
+	if mode == FinalityDepth {
+		// blocks shallower than the depth aren't safe yet
+		if head < comfirmedBlock {
+			return nil
+		}
+		safe = head - comfirmedBlock
+		finalized = safe
+	}
+
+	if !f.hasSafe || safe > f.safe {
+		f.safe, f.hasSafe = safe, true
+	}
+	if !f.hasFinalized || finalized > f.finalized {
+		f.finalized, f.hasFinalized = finalized, true
+	}
+	return nil
+}
+
+// statusOf returns the status of the block with the given number.
+func (f *finalityTracker) statusOf(num uint64) models.BlockStatus {
+	f.RLock()
+	defer f.RUnlock()
+	switch {
+	case f.hasFinalized && num <= f.finalized:
+		return models.StatusFinalized
+	case f.hasSafe && num <= f.safe:
+		return models.StatusSafe
+	default:
+		return models.StatusLatest
+	}
+}
+
+// resync submits the indexed blocks up to the finalized and safe heights
+// which are less final than them, the lowest maxFinalityResync of each, to
+// sync them again. Their status is only raised as each of them is committed,
+// once its hash was checked against the node, and blocks replaced by a reorg
+// are switched over. The blocks left are submitted on the next head.
+func (f *finalityTracker) resync(ctx context.Context, blocks *pipeline) error {
+	f.RLock()
+	safe, hasSafe := f.safe, f.hasSafe
+	finalized, hasFinalized := f.finalized, f.hasFinalized
+	f.RUnlock()
+
+	if hasFinalized {
+		stale, err := store.Blocks.GetLessFinal(
+			ctx, maxFinalityResync, finalized, models.StatusFinalized)
+		if err != nil {
+			return err
+		}
+		for _, block := range stale {
+			blocks.submit(ctx, block.GetNumber(), models.StatusFinalized)
+		}
+	}
+	if hasSafe && (!hasFinalized || safe > finalized) {
+		stale, err := store.Blocks.GetLessFinal(
+			ctx, maxFinalityResync, safe, models.StatusSafe)
+		if err != nil {
+			return err
+		}
+		for _, block := range stale {
+			// blocks up to the finalized height are synced as finalized
+			if !hasFinalized || block.GetNumber() > finalized {
+				blocks.submit(ctx, block.GetNumber(), models.StatusSafe)
+			}
+		}
+	}
+	return nil
+}
+
+// save records the safe and finalized heights tracked, so that they're
+// reported by the API of any instance.
+func (f *finalityTracker) save(ctx context.Context) error {
+	f.RLock()
+	mode := f.mode
+	var safe, finalized *uint64
+	if f.hasSafe {
+		height := f.safe
+		safe = &height
+	}
+	if f.hasFinalized {
+		height := f.finalized
+		finalized = &height
+	}
+	f.RUnlock()
+
+	return store.Indexer.SetFinality(ctx, mode, safe, finalized)
+}
+
+// GetFinality returns the safe and finalized heights tracked by the indexer,
+// as last recorded.
+func GetFinality(ctx context.Context) (Finality, error) {
+	indexer, err := store.Indexer.Get(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		return Finality{}, nil
+	} else if err != nil {
+		return Finality{}, err
+	}
+
+	return Finality{
+		Mode:      indexer.GetFinalityMode(),
+		Safe:      indexer.GetSafe(),
+		Finalized: indexer.GetFinalized(),
+	}, nil
+}
+
+// headerNumberByTag returns the number of the block with the given tag.
+func headerNumberByTag(ctx context.Context, client *chainClient, tag string) (uint64, error) {
+	ctx, span := tracing.StartWithKind(ctx, "eth_getBlockByNumber",
+		trace.SpanKindClient, attribute.String("block.tag", tag))
+	header, err := client.HeaderByTag(ctx, tag)
+	tracing.EndWithError(span, err)
+	if err != nil {
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
+
+// JSON-RPC error codes of methods and parameters the node doesn't support.
+const (
+	errCodeMethodNotFound = -32601
+	errCodeInvalidParams  = -32602
+)
+
+// Messages of the errors of nodes which don't know a block tag, such as
+// pre-merge nodes.
+var unknownTagMessages = []string{
+	"unknown block", "invalid block", "block tag", "not supported",
+	"safe block not found", "finalized block not found",
+}
+
+// isUnsupportedTag returns whether the error tells the node doesn't support
+// a block tag, as opposed to a failure to reach it or of the node, such as a
+// rate limit or an internal error.
+func isUnsupportedTag(err error) bool {
+	if errors.Is(err, ethereum.NotFound) {
+		return true
+	}
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	switch rpcErr.ErrorCode() {
+	case errCodeMethodNotFound, errCodeInvalidParams:
+		return true
+	}
+	message := strings.ToLower(rpcErr.Error())
+	for _, m := range unknownTagMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package eth_index
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/models"
+	"main/store"
+	"main/store/storetest"
+)
+
+func TestIsUnsupportedTag(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{ethereum.NotFound, true},
+		{&testRPCError{-32601, "the method eth_getBlockByNumber does not exist"}, true},
+		{&testRPCError{-32602, "invalid argument 0: hex string without 0x prefix"}, true},
+		{&testRPCError{-32000, "finalized block not found"}, true},
+		{&testRPCError{-32000, "Unknown block"}, true},
+		{&testRPCError{-32005, "request rate limited"}, false},
+		{&testRPCError{-32603, "internal error"}, false},
+		{errors.New("connection reset by peer"), false},
+		{nil, false},
+	} {
+		if got := isUnsupportedTag(c.err); got != c.want {
+			t.Errorf("isUnsupportedTag(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+// tagServer serves eth_getBlockByNumber with the given block number for the
+// safe and finalized tags, or the given error.
+func tagServer(t *testing.T, number *string, rpcErr *string) *chainClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if *rpcErr != "" {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":%s}`, req.ID, *rpcErr)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"number":%q,"parentHash":"0x0000000000000000000000000000000000000000000000000000000000000000","sha3Uncles":"0x0000000000000000000000000000000000000000000000000000000000000000","miner":"0x0000000000000000000000000000000000000000","stateRoot":"0x0000000000000000000000000000000000000000000000000000000000000000","transactionsRoot":"0x0000000000000000000000000000000000000000000000000000000000000000","receiptsRoot":"0x0000000000000000000000000000000000000000000000000000000000000000","logsBloom":"0x%0512x","difficulty":"0x0","gasLimit":"0x0","gasUsed":"0x0","timestamp":"0x0","extraData":"0x","mixHash":"0x0000000000000000000000000000000000000000000000000000000000000000","nonce":"0x0000000000000000"}}`,
+			req.ID, *number, 0)
+	}))
+	t.Cleanup(server.Close)
+	client, err := dialClient(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestFinalityUpdate(t *testing.T) {
+	ctx := context.Background()
+	number, rpcErr := "0x64", ""
+	client := tagServer(t, &number, &rpcErr)
+	f := &finalityTracker{}
+
+	// the tags are tracked
+	if err := f.update(ctx, client, 200); err != nil || f.finalized != 100 {
+		t.Fatalf("update() = %v with %d finalized, want 100", err, f.finalized)
+	}
+
+	// a rate limit leaves the heights as they are
+	rpcErr = `{"code":-32005,"message":"request rate limited"}`
+	if err := f.update(ctx, client, 10000); err == nil {
+		t.Fatal("update() = nil, want an error")
+	}
+	if f.mode != FinalityTags || f.finalized != 100 || f.safe != 100 {
+		t.Fatalf("heights moved to %s %d %d", f.mode, f.safe, f.finalized)
+	}
+
+	// the depth doesn't overtake the heights once the tags were served
+	rpcErr = `{"code":-32601,"message":"method not found"}`
+	if err := f.update(ctx, client, 10000); err != nil {
+		t.Fatalf("update() = %v, want no change", err)
+	}
+	if f.mode != FinalityTags || f.finalized != 100 || f.safe != 100 {
+		t.Fatalf("heights moved to %s %d %d", f.mode, f.safe, f.finalized)
+	}
+
+	// nodes which never served the tags fall back to the depth
+	f = &finalityTracker{}
+	if err := f.update(ctx, client, 10000); err != nil ||
+		f.mode != FinalityDepth || f.finalized != 10000-comfirmedBlock {
+		t.Fatalf("update() = %v with %d finalized in %s mode, want the depth",
+			err, f.finalized, f.mode)
+	}
+}
+
+// chainServer serves eth_getBlockByNumber with the given blocks, by number
+// or by tag, and eth_blockNumber with the highest of them.
+func chainServer(t *testing.T, blocks map[string]*storetest.Block) *chainClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == "eth_blockNumber" {
+			var head uint64
+			for _, block := range blocks {
+				if num := block.Block.GetNumber(); num > head {
+					head = num
+				}
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x%x"}`, req.ID, head)
+			return
+		}
+		var id string
+		if len(req.Params) > 0 {
+			json.Unmarshal(req.Params[0], &id)
+		}
+		block, ok := blocks[id]
+		if !ok {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":null}`, req.ID)
+			return
+		}
+		result := map[string]interface{}{}
+		header, _ := json.Marshal(block.Header())
+		json.Unmarshal(header, &result)
+		result["transactions"], result["uncles"] = []string{}, []string{}
+		body, _ := json.Marshal(result)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, body)
+	}))
+	t.Cleanup(server.Close)
+	client, err := dialClient(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestFinalityResync(t *testing.T) {
+	storetest.UseMemory(t)
+	ctx := context.Background()
+
+	// blocks 1 to 4 are indexed as latest, and the node replaced 3 and 4
+	indexed := []*storetest.Block{}
+	parent := common.Hash{}
+	for num := uint64(1); num <= 4; num++ {
+		block := storetest.NewBlock(num, parent, "")
+		block.Commit(t)
+		indexed = append(indexed, block)
+		parent = block.Hash()
+	}
+	replacing3 := storetest.NewBlock(3, indexed[1].Hash(), "new")
+	replacing4 := storetest.NewBlock(4, replacing3.Hash(), "new")
+	client := chainServer(t, map[string]*storetest.Block{
+		"0x1": indexed[0], "0x2": indexed[1], "0x3": replacing3, "0x4": replacing4,
+		"finalized": indexed[1], "safe": replacing3,
+	})
+
+	f := &finalityTracker{}
+	if err := f.update(ctx, client, 4); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	blocks := startPipeline(client, 2, nil)
+	if err := f.resync(ctx, blocks); err != nil {
+		t.Fatalf("resync: %v", err)
+	}
+	blocks.close()
+
+	// only the blocks still served by the node are raised
+	for _, want := range []struct {
+		block  *storetest.Block
+		status models.BlockStatus
+	}{
+		{indexed[0], models.StatusFinalized},
+		{indexed[1], models.StatusFinalized},
+		{replacing3, models.StatusSafe},
+		{indexed[2], models.StatusLatest},
+		{indexed[3], models.StatusLatest},
+	} {
+		block, err := store.Blocks.GetByHash(ctx, want.block.Hash().String())
+		if err != nil {
+			t.Fatalf("GetByHash(%d): %v", want.block.Block.GetNumber(), err)
+		}
+		if block.GetStatus() != want.status {
+			t.Errorf("block %d %s is %s, want %s", block.GetNumber(), block.GetHash(),
+				block.GetStatus(), want.status)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package eth_index
+
+import (
+	"context"
+	"sync"
+)
+
+// syncTracker counts in-flight block syncs so they can be drained on
+// shutdown. Once closed, no new syncs may start.
+type syncTracker struct {
+	sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// inflight is the singleton tracker of in-flight block syncs.
+var inflight = &syncTracker{}
+
+// add counts a new sync and returns whether it may start.
+func (t *syncTracker) add() bool {
+	t.Lock()
+	defer t.Unlock()
+	if t.closed {
+		return false
+	}
+	t.wg.Add(1)
+	return true
+}
+
+// done marks a sync as finished.
+func (t *syncTracker) done() {
+	t.wg.Done()
+}
+
+// close stops new syncs from starting.
+func (t *syncTracker) close() {
+	t.Lock()
+	defer t.Unlock()
+	t.closed = true
+}
+
+// wait waits until all syncs are finished or the context is done.
+func (t *syncTracker) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,274 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/logging"
+	"main/models"
+	"main/store"
+	"main/tracing"
+)
+
+// syncJob is a block synced through the pipeline, carrying the results of
+// each stage to the next.
+type syncJob struct {
+	// The sync context of the block, carrying its root span.
+	ctx  context.Context
+	span trace.Span
+
+	num    uint64
+	status models.BlockStatus
+	seq    uint64
+
+	// Set by the fetch stage. If the block is already indexed with the same
+	// hash, its transactions and receipts aren't fetched.
+	block    *types.Block
+	receipts []*types.Receipt
+	indexed  bool
+	replaced models.BlockIntf
+
+	// Set by the trace stage, if internal transactions are traced.
+	traces []*txTrace
+
+	// Set by the contracts stage.
+	contracts []models.ContractIntf
+	code      []models.ContractCodeIntf
+
+	// Set by the decode stage.
+	decoded store.IndexedBlock
+
+	// The error of the first stage that failed.
+	err error
+}
+
+// pipeline syncs blocks through fetch, contracts, decode and persist stages
+// connected by bounded channels, with a trace stage after fetch if internal
+// transactions are traced. Blocks are fetched and decoded in parallel, and
+// committed one at a time in the order they were submitted. Every committed
+// block advances the indexed watermark over the contiguous blocks.
+type pipeline struct {
+	client *chainClient
+
+	// Called by the persist stage once a block is committed or failed.
+	onDone func(job *syncJob)
+
+	jobs   chan *syncJob
+	window chan struct{}
+	done   chan struct{}
+	seq    uint64
+	failed int
+}
+
+// startPipeline starts a pipeline fetching blocks with the given number of
+// workers.
+func startPipeline(
+	client *chainClient, workers int, onDone func(job *syncJob)) *pipeline {
+	p := &pipeline{
+		client: client,
+		onDone: onDone,
+		jobs:   make(chan *syncJob, workers),
+		window: make(chan struct{}, 2*workers),
+		done:   make(chan struct{}),
+	}
+
+	fetched := make(chan *syncJob, workers)
+	decoded := make(chan *syncJob, workers)
+	go runStage(workers, p.jobs, fetched, p.fetch)
+	if traceInternalTxs {
+		traced := make(chan *syncJob, workers)
+		go runStage(workers, fetched, traced, p.trace)
+		fetched = traced
+	}
+	inspected := make(chan *syncJob, workers)
+	go runStage(workers, fetched, inspected, p.findContracts)
+	go runStage(runtime.GOMAXPROCS(0), inspected, decoded, p.decode)
+	go p.persistInOrder(decoded)
+
+	return p
+}
+
+// submit queues a block to sync under the context with at least the given
+// status. It blocks while the pipeline holds as many blocks as it may
+// reorder, and must not be called concurrently or after close.
+func (p *pipeline) submit(ctx context.Context, num uint64, status models.BlockStatus) {
+	p.window <- struct{}{}
+	p.jobs <- &syncJob{ctx: ctx, num: num, status: status, seq: p.seq}
+	p.seq++
+}
+
+// close waits for the submitted blocks to be committed, and returns the
+// number of blocks that failed.
+func (p *pipeline) close() int {
+	close(p.jobs)
+	<-p.done
+	return p.failed
+}
+
+// runStage runs a stage on the jobs from in with n workers, passing them on
+// to out, which is closed once in is closed and drained.
+func runStage(n int, in <-chan *syncJob, out chan<- *syncJob, stage func(job *syncJob)) {
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range in {
+				stage(job)
+				out <- job
+			}
+		}()
+	}
+	wg.Wait()
+	close(out)
+}
+
+// fetch is the fetch stage, getting the block and the receipts of its
+// transactions.
+func (p *pipeline) fetch(job *syncJob) {
+	job.ctx = logging.WithFields(job.ctx, logging.Fields{
+		"block": job.num, "status": job.status})
+
+	// trace the indexing of the block under a root span
+	job.ctx, job.span = tracing.Start(job.ctx, "eth_index.index_block",
+		attribute.Int64("block.number", int64(job.num)),
+		attribute.String("block.status", string(job.status)))
+
+	fetchCtx, span := tracing.Start(job.ctx, "eth_index.fetch_block")
+	job.block = <-getBlocks(fetchCtx, p.client, []uint64{job.num})
+	span.End()
+	if job.block == nil {
+		job.err = fmt.Errorf("failed to fetch block %d", job.num)
+		return
+	}
+
+	// check if the block in DB should be replaced
+	old, err := store.Blocks.GetByNumber(job.ctx, job.num)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		job.err = err
+		return
+	case old.GetHash() == job.block.Hash().String():
+		job.indexed = true
+		return
+	default:
+		job.replaced = old
+	}
+
+	// get receipts by tx hashes
+	fetchCtx, span = tracing.Start(job.ctx, "eth_index.fetch_receipts")
+	for receipt := range getReceipt(fetchCtx, p.client, job.block.Transactions()) {
+		job.receipts = append(job.receipts, receipt)
+	}
+	span.End()
+	if missing := len(job.block.Transactions()) - len(job.receipts); missing > 0 {
+		job.err = fmt.Errorf("failed to fetch %d receipts of block %d", missing, job.num)
+	}
+}
+
+// decode is the decode stage, converting the block, transactions, receipts
+// and logs to model instances.
+func (p *pipeline) decode(job *syncJob) {
+	if job.err != nil {
+		return
+	}
+
+	_, span := tracing.Start(job.ctx, "eth_index.decode_block")
+	defer span.End()
+	job.decoded.Block = models.NewBlock(job.block)
+	job.decoded.Block.SetStatus(job.status)
+	if job.indexed {
+		return
+	}
+	blockHash := job.block.Hash().String()
+	job.decoded.Transactions = decodeTransactions(
+		job.ctx, job.block.Transactions(), blockHash)
+	job.decoded.Receipts, job.decoded.Logs = decodeReceipts(job.ctx, job.receipts)
+	if job.traces != nil {
+		job.decoded.InternalTransactions = decodeInternalTransactions(job)
+	}
+	job.decoded.Contracts, job.decoded.ContractCode = job.contracts, job.code
+	job.decoded.TokenTransfers, job.decoded.Tokens = decodeTokenTransfers(job)
+	job.decoded.BalanceChanges = decodeBalanceChanges(job, job.decoded.TokenTransfers)
+}
+
+// persistInOrder is the persist stage, committing the blocks in the order
+// they were submitted. Blocks decoded ahead of their turn wait in pending.
+func (p *pipeline) persistInOrder(decoded <-chan *syncJob) {
+	defer close(p.done)
+
+	pending := map[uint64]*syncJob{}
+	next := uint64(0)
+	for job := range decoded {
+		pending[job.seq] = job
+		for {
+			job, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			p.persist(job)
+			<-p.window
+		}
+	}
+}
+
+// persist commits a block with its transactions, receipts and logs. A block
+// which doesn't follow the indexed chain switches it over to the chain of
+// the block.
+func (p *pipeline) persist(job *syncJob) {
+	reorg := false
+	if job.err == nil {
+		reorg, job.err = isReorg(job)
+	}
+	if reorg {
+		p.switchChain(job)
+	} else {
+		p.commit(job)
+	}
+
+	if p.onDone != nil {
+		p.onDone(job)
+	}
+}
+
+// commit commits a block with its transactions, receipts and logs, then
+// advances the indexed watermark. A block that failed requests a catch-up, so
+// it's synced again.
+func (p *pipeline) commit(job *syncJob) {
+	if job.err == nil {
+		persistCtx, span := tracing.Start(job.ctx, "eth_index.persist_block",
+			attribute.Int("block.transactions", len(job.decoded.Transactions)),
+			attribute.Int("block.logs", len(job.decoded.Logs)),
+			attribute.Int("block.internal_transactions", len(job.decoded.InternalTransactions)),
+			attribute.Int("block.contracts", len(job.decoded.Contracts)),
+			attribute.Int("block.token_transfers", len(job.decoded.TokenTransfers)),
+			attribute.Int("block.balance_changes", len(job.decoded.BalanceChanges)))
+		job.err = store.Blocks.CommitBlock(persistCtx, job.decoded)
+		tracing.EndWithError(span, job.err)
+	}
+	tracing.EndWithError(job.span, job.err)
+
+	if job.err != nil {
+		logging.Error(job.ctx, "Failed to sync block: %v", job.err)
+		p.failed++
+		requestCatchUp()
+		return
+	}
+	if job.replaced != nil {
+		logging.Info(job.ctx, "Orphaned block %s", job.replaced.GetHash())
+	}
+	logging.Info(job.ctx, "Synced block")
+	if err := advanceWatermark(job.ctx); err != nil {
+		logging.Error(job.ctx, "Failed to advance the indexed watermark: %v", err)
+	}
+}
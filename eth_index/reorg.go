@@ -0,0 +1,170 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"main/logging"
+	"main/models"
+	"main/store"
+	"main/tracing"
+)
+
+// maxReorgDepth is the maximum number of blocks walked down the new chain to
+// find the fork point of a reorg.
+const maxReorgDepth = 128
+
+// isReorg returns whether a block doesn't follow the indexed chain: it
+// replaces an indexed block of the same number, or its parent isn't the
+// indexed block below it. The indexed chain is read again, since it may have
+// been switched since the block was fetched.
+func isReorg(job *syncJob) (bool, error) {
+	stored, err := store.Blocks.GetByNumber(job.ctx, job.num)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		job.replaced = nil
+	case err != nil:
+		return false, err
+	case stored.GetHash() == job.block.Hash().String():
+		job.replaced = nil
+		return false, nil
+	default:
+		job.replaced = stored
+	}
+
+	// the transactions of a block indexed when fetched weren't decoded
+	if job.indexed {
+		return false, fmt.Errorf("block %d was replaced while syncing", job.num)
+	}
+	if job.replaced != nil {
+		return true, nil
+	}
+	if job.num == 0 {
+		return false, nil
+	}
+
+	parent, err := store.Blocks.GetByNumber(job.ctx, job.num-1)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return parent.GetHash() != job.block.ParentHash().String(), nil
+}
+
+// switchChain commits a block switching the indexed chain over to the chain
+// of the block. The indexed blocks down to the fork point, the highest block
+// on both chains, are synced again, and so are the indexed blocks above the
+// block. Blocks of the old chain beyond the new one are orphaned. The reorg
+// is recorded once the block is committed.
+func (p *pipeline) switchChain(job *syncJob) {
+	oldTip, err := store.Blocks.GetHighest(job.ctx, models.StatusLatest)
+	if err != nil {
+		job.err = err
+		p.commit(job)
+		return
+	}
+
+	// fetch the new chain down to the fork point, which is indexed with the
+	// same hash, or not indexed at all
+	below := []*syncJob{}
+	fork := job.num
+	for fork > 0 && job.err == nil {
+		fork--
+		if job.num-fork > maxReorgDepth {
+			job.err = fmt.Errorf("reorg deeper than %d blocks", maxReorgDepth)
+			break
+		}
+		resync := p.resync(job, fork)
+		if resync.err != nil {
+			tracing.EndWithError(resync.span, resync.err)
+			job.err = resync.err
+			break
+		}
+		if resync.replaced == nil {
+			resync.span.End()
+			break
+		}
+		below = append(below, resync)
+	}
+	if job.err == nil {
+		logging.Warn(job.ctx, "Chain reorganized above block %d, indexed up to block %d",
+			fork, oldTip.GetNumber())
+	}
+
+	// commit the new chain up to the block in order
+	for i := len(below) - 1; i >= 0; i-- {
+		resync := below[i]
+		if job.err != nil {
+			tracing.EndWithError(resync.span, job.err)
+			continue
+		}
+		p.decode(resync)
+		p.commit(resync)
+		if resync.err != nil {
+			job.err = fmt.Errorf("failed to sync block %d of the new chain", resync.num)
+		}
+	}
+	p.commit(job)
+	if job.err != nil {
+		return
+	}
+
+	// sync the indexed blocks above the block again, and orphan those beyond
+	// the new chain
+	newTip := job.decoded.Block
+	for num := job.num + 1; num <= oldTip.GetNumber(); num++ {
+		resync := p.resync(job, num)
+		if resync.err != nil {
+			tracing.EndWithError(resync.span, resync.err)
+			if err := orphanRange(job.ctx, num, oldTip.GetNumber()); err != nil {
+				logging.Error(job.ctx, "Failed to orphan blocks from %d: %v", num, err)
+			}
+			break
+		}
+		p.decode(resync)
+		p.commit(resync)
+		if resync.err != nil {
+			break
+		}
+		newTip = resync.decoded.Block
+	}
+
+	// record the reorg
+	reorg := models.NewReorg(fork, oldTip, newTip)
+	if err := store.Reorgs.SetReorg(job.ctx, reorg); err != nil {
+		logging.Error(job.ctx, "Failed to record the reorg: %v", err)
+	}
+}
+
+// resync fetches a block synced again while switching the indexed chain
+// over to the chain of the job.
+func (p *pipeline) resync(job *syncJob, num uint64) *syncJob {
+	resync := &syncJob{ctx: job.ctx, num: num, status: finality.statusOf(num)}
+	p.fetch(resync)
+	if traceInternalTxs {
+		p.trace(resync)
+	}
+	p.findContracts(resync)
+	return resync
+}
+
+// orphanRange orphans the indexed blocks in the range [from, to], and
+// rewinds the indexed watermark below them.
+func orphanRange(ctx context.Context, from, to uint64) error {
+	blocks, err := store.Blocks.GetRange(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if err := store.Blocks.OrphanBlock(ctx, block); err != nil {
+			return err
+		}
+		logging.Info(ctx, "Orphaned block %d %s", block.GetNumber(), block.GetHash())
+	}
+	if from == 0 {
+		return nil
+	}
+	return store.Watermarks.Rewind(ctx, store.WatermarkIndexed, from-1)
+}
@@ -0,0 +1,103 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"main/logging"
+)
+
+// headerRequestID is the HTTP header carrying the request ID to the endpoint.
+const headerRequestID = "X-Request-ID"
+
+// requestIDTransport is an HTTP transport which forwards the request ID found
+// in the request context to the RPC endpoint, so that RPC calls can be
+// correlated with the logs of the sync that issued them.
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if requestID := logging.GetRequestID(req.Context()); len(requestID) > 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set(headerRequestID, requestID)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// errCodeReverted is the JSON-RPC error code of reverted calls.
+const errCodeReverted = 3
+
+// Messages of the errors of calls which were executed and failed, returned
+// by nodes which don't use errCodeReverted.
+var revertMessages = []string{"execution reverted", "invalid opcode", "vm execution error"}
+
+// isReverted returns whether a call was executed and reverted, as opposed to
+// failing to execute, e.g. by a rate limit or state the node doesn't have.
+func isReverted(err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.ErrorCode() == errCodeReverted {
+		return true
+	}
+	message := strings.ToLower(rpcErr.Error())
+	for _, m := range revertMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// chainClient is an Ethereum client which can also request blocks by tag.
+type chainClient struct {
+	*ethclient.Client
+	rpc *rpc.Client
+}
+
+// HeaderByTag returns the header of the block with the given tag, such as
+// "safe" or "finalized".
+func (c *chainClient) HeaderByTag(ctx context.Context, tag string) (*types.Header, error) {
+	var header *types.Header
+	err := c.rpc.CallContext(ctx, &header, "eth_getBlockByNumber", tag, false)
+	if err == nil && header == nil {
+		err = ethereum.NotFound
+	}
+	return header, err
+}
+
+// dialClient connects to the RPC endpoint. HTTP endpoints forward the request
+// ID of the call context with every request.
+func dialClient(ctx context.Context, endpoint string) (*chainClient, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var client *rpc.Client
+	switch u.Scheme {
+	case "http", "https":
+		httpClient := &http.Client{
+			Transport: &requestIDTransport{base: http.DefaultTransport},
+		}
+		client, err = rpc.DialHTTPWithClient(endpoint, httpClient)
+	default:
+		client, err = rpc.DialContext(ctx, endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &chainClient{ethclient.NewClient(client), client}, nil
+}
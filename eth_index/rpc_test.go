@@ -0,0 +1,37 @@
+package eth_index
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testRPCError is a JSON-RPC error returned by a node.
+type testRPCError struct {
+	code    int
+	message string
+}
+
+func (e *testRPCError) Error() string  { return e.message }
+func (e *testRPCError) ErrorCode() int { return e.code }
+
+func TestIsReverted(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{&testRPCError{3, "execution reverted"}, true},
+		{&testRPCError{-32000, "execution reverted: not owner"}, true},
+		{&testRPCError{-32015, "VM execution error."}, true},
+		{fmt.Errorf("call: %w", &testRPCError{3, "reverted"}), true},
+		{&testRPCError{-32005, "daily request count exceeded, request rate limited"}, false},
+		{&testRPCError{-32000, "missing trie node 1a2b (path )"}, false},
+		{&testRPCError{-32603, "internal error"}, false},
+		{errors.New("connection refused"), false},
+		{nil, false},
+	} {
+		if got := isReverted(c.err); got != c.want {
+			t.Errorf("isReverted(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
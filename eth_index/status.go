@@ -0,0 +1,167 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"main/logging"
+	"main/store"
+)
+
+// ReorgInfo describes the most recent chain reorganization seen by the
+// indexer, by the tips of the old and new chains.
+type ReorgInfo struct {
+	Number     uint64    `json:"block_num"`
+	Depth      uint64    `json:"depth"`
+	OldHash    string    `json:"old_hash"`
+	NewHash    string    `json:"new_hash"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// BackfillProgress describes the progress of the startup block sync.
+type BackfillProgress struct {
+	Total     uint64     `json:"total"`
+	Done      uint64     `json:"done"`
+	Running   bool       `json:"running"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	ETA       *time.Time `json:"eta,omitempty"`
+}
+
+// Status is a snapshot of the indexer state. The chain head, the active
+// endpoint and the last reorg are read from DB, as recorded by the indexing
+// instance, and the backfill progress is the one of this process.
+type Status struct {
+	ChainHead      uint64           `json:"chain_head"`
+	ChainHeadSeen  *time.Time       `json:"chain_head_seen_at,omitempty"`
+	ActiveEndpoint string           `json:"active_endpoint"`
+	LastReorg      *ReorgInfo       `json:"last_reorg,omitempty"`
+	Backfill       BackfillProgress `json:"backfill"`
+}
+
+// indexerState holds the runtime state shared by the indexer goroutines.
+type indexerState struct {
+	sync.RWMutex
+	chainHead      uint64
+	backfillTotal  uint64
+	backfillDone   uint64
+	backfillStart  time.Time
+	backfillActive bool
+}
+
+// state is the singleton indexer runtime state.
+var state = &indexerState{}
+
+// GetStatus returns a snapshot of the indexer state.
+func GetStatus(ctx context.Context) (Status, error) {
+	status := Status{Backfill: state.getBackfill()}
+
+	indexer, err := store.Indexer.Get(ctx)
+	switch {
+	case err == nil:
+		status.ChainHead = indexer.GetChainHead()
+		status.ActiveEndpoint = indexer.GetActiveEndpoint()
+		if seenAt := indexer.GetChainHeadSeenAt(); seenAt != nil {
+			seen := time.UnixMilli(*seenAt)
+			status.ChainHeadSeen = &seen
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		return status, err
+	}
+
+	reorgs, err := store.Reorgs.GetLatest(ctx, 1)
+	if err != nil {
+		return status, err
+	}
+	if len(reorgs) > 0 {
+		status.LastReorg = &ReorgInfo{
+			Number:     reorgs[0].GetNewTipNumber(),
+			Depth:      reorgs[0].GetDepth(),
+			OldHash:    reorgs[0].GetOldTipHash(),
+			NewHash:    reorgs[0].GetNewTipHash(),
+			DetectedAt: time.UnixMilli(reorgs[0].GetDetectedAt()),
+		}
+	}
+
+	return status, nil
+}
+
+// getBackfill returns the progress of the backfill of this process.
+func (s *indexerState) getBackfill() BackfillProgress {
+	s.RLock()
+	defer s.RUnlock()
+
+	backfill := BackfillProgress{
+		Total:   s.backfillTotal,
+		Done:    s.backfillDone,
+		Running: s.backfillActive,
+	}
+
+	// Estimate the remaining backfill time from the average time per block.
+	if !s.backfillStart.IsZero() {
+		start := s.backfillStart
+		backfill.StartedAt = &start
+		if s.backfillActive && s.backfillDone > 0 {
+			elapsed := time.Since(start)
+			perBlock := elapsed / time.Duration(s.backfillDone)
+			remaining := s.backfillTotal - s.backfillDone
+			eta := time.Now().Add(perBlock * time.Duration(remaining))
+			backfill.ETA = &eta
+		}
+	}
+
+	return backfill
+}
+
+// setChainHead records the latest block number seen from the RPC endpoint.
+func (s *indexerState) setChainHead(ctx context.Context, num uint64) {
+	s.Lock()
+	if num > s.chainHead {
+		s.chainHead = num
+	}
+	head := s.chainHead
+	s.Unlock()
+
+	if err := store.Indexer.SetChainHead(ctx, head); err != nil {
+		logging.Error(ctx, "Failed to record the chain head: %v", err)
+	}
+}
+
+// setActiveEndpoint records the RPC endpoint currently in use.
+func (s *indexerState) setActiveEndpoint(ctx context.Context, endpoint string) {
+	if err := store.Indexer.SetActiveEndpoint(ctx, redactURL(endpoint)); err != nil {
+		logging.Error(ctx, "Failed to record the active endpoint: %v", err)
+	}
+}
+
+// startBackfill resets the backfill progress counters.
+func (s *indexerState) startBackfill(total uint64) {
+	s.Lock()
+	defer s.Unlock()
+	s.backfillTotal = total
+	s.backfillDone = 0
+	s.backfillStart = time.Now()
+	s.backfillActive = total > 0
+}
+
+// backfillBlockDone marks one more backfilled block as done.
+func (s *indexerState) backfillBlockDone() {
+	s.Lock()
+	defer s.Unlock()
+	s.backfillDone++
+	if s.backfillDone >= s.backfillTotal {
+		s.backfillActive = false
+	}
+}
+
+// redactURL strips the path and credentials from an endpoint URL, since RPC
+// providers usually embed the API key in the path.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(u.Host) <= 0 {
+		return "<invalid>"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,260 @@
+package eth_index
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/logging"
+	"main/models"
+	"main/store"
+	"main/tracing"
+)
+
+// topicTransfer is the topic of the Transfer(address,address,uint256) event.
+// ERC-721 tokens emit it too, with the token ID as a fourth topic.
+var topicTransfer = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// Selectors of the token metadata functions.
+var (
+	selectorName        = []byte{0x06, 0xfd, 0xde, 0x03}
+	selectorSymbol      = []byte{0x95, 0xd8, 0x9b, 0x41}
+	selectorDecimals    = []byte{0x31, 0x3c, 0xe5, 0x67}
+	selectorTotalSupply = []byte{0x18, 0x16, 0x0d, 0xdd}
+)
+
+// tokenResolveBatch is the number of tokens resolved per round.
+const tokenResolveBatch = 50
+
+// maxTokenStringLength is the maximum length in bytes of a token name or
+// symbol, as stored. Longer ones are left unknown.
+const maxTokenStringLength = 255
+
+// minTokenRetryDelay is the delay before retrying to resolve the metadata of
+// a token the first time it fails, doubled on every failure up to the
+// refresh interval.
+const minTokenRetryDelay = time.Minute
+
+// decodeTokenTransfers decodes the ERC-20 transfers of the block from the
+// logs of its receipts, and returns them with the tokens seen in the block:
+// the contracts emitting them, and the ERC-20 contracts deployed.
+func decodeTokenTransfers(job *syncJob) ([]models.TokenTransferIntf, []models.TokenIntf) {
+	transfers := []models.TokenTransferIntf{}
+	tokens := []models.TokenIntf{}
+	seen := map[string]bool{}
+	addToken := func(address string) {
+		if !seen[address] {
+			seen[address] = true
+			tokens = append(tokens, models.NewToken(address))
+		}
+	}
+
+	blockHash := job.block.Hash().String()
+	for _, receipt := range job.receipts {
+		if receipt.Status != 1 {
+			continue
+		}
+		for _, log := range receipt.Logs {
+			if len(log.Topics) != 3 || log.Topics[0] != topicTransfer || len(log.Data) != 32 {
+				continue
+			}
+			token := log.Address.String()
+			transfers = append(transfers, models.NewTokenTransfer(
+				blockHash, job.num, log.TxHash.String(), int64(log.Index), token,
+				common.BytesToAddress(log.Topics[1].Bytes()).String(),
+				common.BytesToAddress(log.Topics[2].Bytes()).String(),
+				new(big.Int).SetBytes(log.Data).String()))
+			addToken(token)
+		}
+	}
+	for _, contract := range job.contracts {
+		if contract.GetStandard() == models.StandardERC20 {
+			addToken(contract.GetAddress())
+		}
+	}
+	return transfers, tokens
+}
+
+// RunTokenResolver resolves the metadata of the tokens due for a refresh
+// every TOKEN_RESOLVE_INTERVAL_MS, until the context is done or the module is
+// finalized. Newly seen tokens are due right away, and resolved tokens are
+// refreshed every TOKEN_REFRESH_INTERVAL_MS.
+func RunTokenResolver(ctx context.Context) {
+	if !inflight.add() {
+		return
+	}
+	defer inflight.done()
+
+	// stop with the module as well
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(tokenResolveInterval)
+	defer ticker.Stop()
+	for {
+		roundCtx := logging.WithRequestID(ctx, logging.NewRequestID())
+		if err := resolveDueTokens(roundCtx); err != nil && ctx.Err() == nil {
+			logging.Error(roundCtx, "Failed to resolve tokens: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// resolveDueTokens resolves the metadata of a batch of tokens due for a
+// refresh. A token which can't be resolved is retried with a backoff.
+func resolveDueTokens(ctx context.Context) error {
+	tokens, err := store.Tokens.GetDue(ctx, time.Now().UnixMilli(), tokenResolveBatch)
+	if err != nil || len(tokens) == 0 {
+		return err
+	}
+
+	endpointURL, _ := endpoints.current()
+	client, err := dialClient(ctx, endpointURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, token := range tokens {
+		if ctx.Err() != nil {
+			return nil
+		}
+		tokenCtx := logging.WithFields(ctx, logging.Fields{"token": token.GetAddress()})
+		if err := resolveToken(tokenCtx, client, token); err != nil {
+			delay := minTokenRetryDelay << uint(token.GetAttempts())
+			if delay > tokenRefreshInterval || delay <= 0 {
+				delay = tokenRefreshInterval
+			}
+			logging.Warn(tokenCtx, "Failed to resolve token metadata, retrying in %v: %v", delay, err)
+			token.SetFailed(time.Now().Add(delay).UnixMilli())
+		}
+		if err := store.Tokens.UpdateToken(tokenCtx, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveToken calls name(), symbol(), decimals() and totalSupply() on a
+// token at the latest block, and sets its metadata. Functions the token
+// doesn't implement are left unknown.
+func resolveToken(ctx context.Context, client *chainClient, token models.TokenIntf) error {
+	ctx, span := tracing.Start(ctx, "eth_index.resolve_token",
+		attribute.String("token.address", token.GetAddress()))
+	address := common.HexToAddress(token.GetAddress())
+
+	var name, symbol, totalSupply *string
+	var decimals *uint64
+	result, err := callToken(ctx, client, address, selectorName)
+	if err == nil {
+		name = decodeTokenString(result)
+		result, err = callToken(ctx, client, address, selectorSymbol)
+	}
+	if err == nil {
+		symbol = decodeTokenString(result)
+		result, err = callToken(ctx, client, address, selectorDecimals)
+	}
+	if err == nil {
+		if value := decodeUint(result); value != nil && value.IsUint64() && value.Uint64() <= 255 {
+			d := value.Uint64()
+			decimals = &d
+		}
+		result, err = callToken(ctx, client, address, selectorTotalSupply)
+	}
+	if err == nil {
+		if value := decodeUint(result); value != nil {
+			s := value.String()
+			totalSupply = &s
+		}
+	}
+	tracing.EndWithError(span, err)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	token.SetMetadata(name, symbol, decimals, totalSupply,
+		now.UnixMilli(), now.Add(tokenRefreshInterval).UnixMilli())
+	logging.Info(ctx, "Resolved token metadata")
+	return nil
+}
+
+// callToken calls a function without arguments on a token at the latest
+// block. A reverted call returns no result, as a token which doesn't
+// implement the function, other errors are returned.
+func callToken(ctx context.Context, client *chainClient,
+	address common.Address, selector []byte) ([]byte, error) {
+	ctx, span := tracing.StartWithKind(ctx, "eth_call", trace.SpanKindClient,
+		attribute.String("contract.address", address.String()))
+	result, err := client.CallContract(ctx, ethereum.CallMsg{To: &address, Data: selector}, nil)
+	tracing.EndWithError(span, err)
+	if isReverted(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// decodeUint decodes a uint256 return value, or returns nil if malformed.
+func decodeUint(result []byte) *big.Int {
+	if len(result) != 32 {
+		return nil
+	}
+	return new(big.Int).SetBytes(result)
+}
+
+// decodeTokenString decodes a string return value, or returns nil if
+// malformed or too long. Legacy tokens such as MKR return a bytes32 padded with zeros
+// instead.
+func decodeTokenString(result []byte) *string {
+	var value []byte
+	switch {
+	case len(result) == 32:
+		// bytes32, up to the first zero byte
+		value = result
+		for i, b := range result {
+			if b == 0 {
+				value = result[:i]
+				break
+			}
+		}
+	case len(result) >= 64:
+		// ABI encoded string: the offset of the length, followed by the bytes
+		offset := new(big.Int).SetBytes(result[:32])
+		if !offset.IsUint64() || offset.Uint64() > uint64(len(result)-32) {
+			return nil
+		}
+		start := offset.Uint64() + 32
+		length := new(big.Int).SetBytes(result[offset.Uint64():start])
+		if !length.IsUint64() || length.Uint64() > uint64(len(result))-start {
+			return nil
+		}
+		value = bytes.TrimRight(result[start:start+length.Uint64()], "\x00")
+	default:
+		return nil
+	}
+	if len(value) == 0 || len(value) > maxTokenStringLength ||
+		bytes.IndexByte(value, 0) >= 0 || !utf8.Valid(value) {
+		return nil
+	}
+	s := string(value)
+	return &s
+}
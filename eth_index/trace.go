@@ -0,0 +1,120 @@
+package eth_index
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/models"
+	"main/tracing"
+)
+
+// callFrame is a call in the call tree returned by the callTracer.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to"`
+	Value   *hexutil.Big    `json:"value"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Error   string          `json:"error"`
+	Calls   []callFrame     `json:"calls"`
+}
+
+// txTrace is the call trace of a transaction, or the error tracing it.
+type txTrace struct {
+	Result *callFrame `json:"result"`
+	Error  string     `json:"error"`
+}
+
+// TraceBlockByNumber returns the call traces of the transactions of the
+// block with the given number, in transaction order. Only archive or trace
+// nodes support it.
+func (c *chainClient) TraceBlockByNumber(ctx context.Context, num uint64) ([]*txTrace, error) {
+	var traces []*txTrace
+	err := c.rpc.CallContext(ctx, &traces, "debug_traceBlockByNumber",
+		hexutil.EncodeUint64(num), map[string]string{"tracer": "callTracer"})
+	return traces, err
+}
+
+// trace is the trace stage, getting the call traces of the transactions of
+// the block. It's only run when internal transactions are traced, and a
+// block which can't be traced fails.
+func (p *pipeline) trace(job *syncJob) {
+	if job.err != nil || job.indexed {
+		return
+	}
+
+	traceCtx, span := tracing.StartWithKind(job.ctx, "debug_traceBlockByNumber",
+		trace.SpanKindClient, attribute.Int64("block.number", int64(job.num)))
+	traces, err := p.client.TraceBlockByNumber(traceCtx, job.num)
+	tracing.EndWithError(span, err)
+	if err != nil {
+		job.err = fmt.Errorf("failed to trace block %d: %w", job.num, err)
+		return
+	}
+	if len(traces) != len(job.block.Transactions()) {
+		job.err = fmt.Errorf("got %d traces of the %d transactions of block %d",
+			len(traces), len(job.block.Transactions()), job.num)
+		return
+	}
+	for i, t := range traces {
+		if t == nil || t.Result == nil {
+			job.err = fmt.Errorf("failed to trace transaction %d of block %d: %s",
+				i, job.num, t.errorString())
+			return
+		}
+	}
+	job.traces = traces
+}
+
+// errorString returns the error of a failed trace.
+func (t *txTrace) errorString() string {
+	if t == nil || len(t.Error) <= 0 {
+		return "no trace returned"
+	}
+	return t.Error
+}
+
+// decodeInternalTransactions flattens the call traces of the transactions of
+// a block into internal transactions, in depth-first order. The top level
+// call of a transaction is the transaction itself, and isn't included.
+func decodeInternalTransactions(job *syncJob) []models.InternalTransactionIntf {
+	ret := []models.InternalTransactionIntf{}
+	blockHash := job.block.Hash().String()
+	for i, tx := range job.block.Transactions() {
+		txHash := tx.Hash().String()
+		position := int64(0)
+
+		// walk the call tree, tracking the path of each call
+		var walk func(calls []callFrame, path []string)
+		walk = func(calls []callFrame, path []string) {
+			for j, call := range calls {
+				callPath := append(path[:len(path):len(path)], strconv.Itoa(j))
+				to := ""
+				if call.To != nil {
+					to = call.To.String()
+				}
+				value := "0"
+				if call.Value != nil {
+					value = call.Value.ToInt().String()
+				}
+				ret = append(ret, models.NewInternalTransaction(
+					blockHash, job.num, txHash, position,
+					strings.Join(callPath, ","), strings.ToLower(call.Type),
+					call.From.String(), to, value, uint64(call.Gas),
+					uint64(call.GasUsed), call.Error, int64(len(callPath))))
+				position++
+				walk(call.Calls, callPath)
+			}
+		}
+		walk(job.traces[i].Result.Calls, nil)
+	}
+	return ret
+}
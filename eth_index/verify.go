@@ -0,0 +1,111 @@
+package eth_index
+
+import (
+	"context"
+	"fmt"
+
+	"main/models"
+	"main/store"
+)
+
+// verifyBatchSize is the number of blocks loaded from DB at a time.
+const verifyBatchSize = 1000
+
+// Verify checks the indexed blocks in the range [from, to] and returns the
+// problems found: missing blocks, blocks not linked to their parent, and if
+// checkChain is set, blocks or transaction counts not matching the chain.
+func Verify(ctx context.Context, from, to uint64, checkChain bool) ([]string, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	problems := []string{}
+
+	// init eth client to compare blocks with the chain
+	var getChainBlock func(num uint64) (string, int, error)
+	if checkChain {
+		endpointURL, _ := endpoints.current()
+		client, err := dialClient(ctx, endpointURL)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		getChainBlock = func(num uint64) (string, int, error) {
+			block := <-getBlocks(ctx, client, []uint64{num})
+			if block == nil {
+				return "", 0, fmt.Errorf("failed to fetch block %d", num)
+			}
+			return block.Hash().String(), len(block.Transactions()), nil
+		}
+	}
+
+	var prev models.BlockIntf
+	next := from
+	for start := from; start <= to; start += verifyBatchSize {
+		if err := ctx.Err(); err != nil {
+			return problems, err
+		}
+		end := start + verifyBatchSize - 1
+		if end > to || end < start {
+			end = to
+		}
+		blocks, err := store.Blocks.GetRange(ctx, start, end)
+		if err != nil {
+			return problems, err
+		}
+
+		for _, block := range blocks {
+			num := block.GetNumber()
+
+			// check for missing blocks
+			if num > next {
+				problems = append(problems,
+					fmt.Sprintf("blocks %d-%d are missing", next, num-1))
+				prev = nil
+			}
+			next = num + 1
+
+			// check the link to the parent block
+			if prev != nil && block.GetParent() != prev.GetHash() {
+				problems = append(problems, fmt.Sprintf(
+					"block %d parent %s doesn't match block %d hash %s",
+					num, block.GetParent(), prev.GetNumber(), prev.GetHash()))
+			}
+			prev = block
+
+			if getChainBlock == nil {
+				continue
+			}
+
+			// compare the block and transaction count with the chain
+			hash, txCount, err := getChainBlock(num)
+			if err != nil {
+				return problems, err
+			}
+			if hash != block.GetHash() {
+				problems = append(problems, fmt.Sprintf(
+					"block %d hash %s doesn't match chain hash %s",
+					num, block.GetHash(), hash))
+				continue
+			}
+			txs, err := store.Txs.GetByBlockHash(ctx, block.GetHash())
+			if err != nil {
+				return problems, err
+			}
+			if len(txs) != txCount {
+				problems = append(problems, fmt.Sprintf(
+					"block %d has %d transactions indexed, chain has %d",
+					num, len(txs), txCount))
+			}
+		}
+
+		// stop before the batch end overflows
+		if end == to {
+			break
+		}
+	}
+	if next <= to {
+		problems = append(problems, fmt.Sprintf("blocks %d-%d are missing", next, to))
+	}
+
+	return problems, nil
+}
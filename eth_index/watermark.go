@@ -0,0 +1,46 @@
+package eth_index
+
+import (
+	"context"
+	"errors"
+
+	"main/store"
+)
+
+// advanceWatermark moves the indexed watermark up over the contiguous blocks
+// following it. Without a watermark, it starts at the lowest indexed block.
+func advanceWatermark(ctx context.Context) error {
+	var from uint64
+	watermark, err := store.Watermarks.Get(ctx, store.WatermarkIndexed)
+	switch {
+	case err == nil:
+		from = watermark + 1
+	case errors.Is(err, store.ErrNotFound):
+		lowest, err := store.Blocks.GetLowest(ctx)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		from = lowest.GetNumber()
+	default:
+		return err
+	}
+
+	// the block following the watermark isn't indexed yet
+	end, err := store.Blocks.GetContiguousEnd(ctx, from)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return store.Watermarks.Advance(ctx, store.WatermarkIndexed, end)
+}
+
+// GetWatermark returns the indexed watermark: every block from the lowest
+// indexed block up to it is completely indexed. It returns store.ErrNotFound
+// if no block is indexed yet.
+func GetWatermark(ctx context.Context) (uint64, error) {
+	return store.Watermarks.Get(ctx, store.WatermarkIndexed)
+}
@@ -0,0 +1,15 @@
+package leader
+
+// Depose makes this instance act as a leader which lost its session, until
+// the returned function restores the election state.
+func Depose() func() {
+	mutex.Lock()
+	wasElecting, pid := electing, sessionPID
+	electing, sessionPID = true, 0
+	mutex.Unlock()
+	return func() {
+		mutex.Lock()
+		electing, sessionPID = wasElecting, pid
+		mutex.Unlock()
+	}
+}
@@ -0,0 +1,266 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"main/config"
+	"main/database"
+	"main/lifecycle"
+	"main/logging"
+)
+
+// lockKey is the advisory lock key held by the leader instance.
+const lockKey = 7245683922
+
+// fenceLockKey is the advisory lock key shared by the database transactions
+// writing as the leader. A newly elected leader takes it exclusively once, to
+// wait for the transactions of the previous leader to end.
+const fenceLockKey = 7245683924
+
+// ErrNotLeader is returned by Check if this instance lost the leader lock.
+var ErrNotLeader = errors.New("not the leader")
+
+// Status is the leader election state of this instance.
+type Status struct {
+	Enabled  bool       `json:"enabled"`
+	Leader   bool       `json:"leader"`
+	Instance string     `json:"instance"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// Static configuration variables initalized at runtime.
+var enabled bool
+var pollInterval time.Duration
+var instance string
+
+// The leader election state, and the backend process ID of the database
+// session holding the leader lock while elected by advisory lock.
+var mutex sync.RWMutex
+var isLeader bool
+var since time.Time
+var electing bool
+var sessionPID int
+
+// Leader root context and the channel closed once the campaign has ended.
+var leaderCtx context.Context
+var leaderCancel context.CancelFunc
+var ended = make(chan struct{})
+
+// init loads the leader election configurations.
+func init() {
+	enabled = config.GetBool("LEADER_ELECTION_ENABLED")
+	pollInterval = config.GetMilliseconds("LEADER_ELECTION_POLL_INTERVAL_MS")
+	hostname, _ := os.Hostname()
+	instance = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// Initialize initializes the leader module. It must be initialized before
+// the modules started by the leader, so that they're stopped before the
+// leader lock is released.
+func Initialize(ctx context.Context) {
+	leaderCtx, leaderCancel = context.WithCancel(ctx)
+
+	// release the leader lock on shutdown
+	lifecycle.Register("leader", func(ctx context.Context) error {
+		leaderCancel()
+		select {
+		case <-ended:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Start campaigns for leadership in the background until the module is
+// stopped. Each time this instance is elected, lead is called with a context
+// canceled when the leadership is lost, and must return once it's canceled.
+// If leader election is disabled or the database has no advisory locks, this
+// instance always leads.
+func Start(lead func(ctx context.Context)) {
+	go func() {
+		defer close(ended)
+
+		// without advisory locks a single instance is assumed
+		if !enabled || !database.SupportsAdvisoryLocks() {
+			setLeader(true)
+			lead(leaderCtx)
+			setLeader(false)
+			return
+		}
+
+		mutex.Lock()
+		electing = true
+		mutex.Unlock()
+		for {
+			if err := campaign(leaderCtx, lead); err != nil {
+				logging.Error(leaderCtx, "Leader election failed: %v", err)
+			}
+
+			// retry after the poll interval
+			select {
+			case <-time.After(pollInterval):
+			case <-leaderCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// GetStatus returns the leader election state of this instance.
+func GetStatus() Status {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	status := Status{
+		Enabled:  enabled,
+		Leader:   isLeader,
+		Instance: instance,
+	}
+	if !since.IsZero() {
+		s := since
+		status.Since = &s
+	}
+
+	return status
+}
+
+// Check returns ErrNotLeader unless the database session of this instance
+// still holds the leader lock, as seen from the database transaction. Writes
+// made as the leader call it first within their transaction, which fences
+// them from a newly elected leader. Without election by advisory lock it
+// returns nil.
+func Check(tx *gorm.DB) error {
+	mutex.RLock()
+	checked, pid := electing, sessionPID
+	mutex.RUnlock()
+	if !checked {
+		return nil
+	}
+	if pid == 0 {
+		return ErrNotLeader
+	}
+
+	// a new leader waits for this transaction to end before leading
+	if err := tx.Exec("SELECT pg_advisory_xact_lock_shared(?)", fenceLockKey).Error; err != nil {
+		return err
+	}
+
+	// bigint advisory keys are split in the class and object IDs
+	var held bool
+	err := tx.Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND granted AND pid = ?
+				AND classid::bigint = ? AND objid::bigint = ? AND objsubid = 1
+		)`, pid, int64(lockKey)>>32, int64(lockKey)&0xffffffff).Row().Scan(&held)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// campaign tries to acquire the leader lock once, and leads while the lock is
+// held. The lock is held by a dedicated database session, which is checked
+// every poll interval; if the session is lost, so is the lock, and writes
+// made as the leader are rejected by Check.
+func campaign(ctx context.Context, lead func(ctx context.Context)) error {
+	conn, err := database.GetSQL().DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// try to acquire the leader lock
+	var acquired bool
+	err = conn.QueryRowContext(ctx,
+		"SELECT pg_try_advisory_lock($1)", lockKey).Scan(&acquired)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		setLeader(false)
+		return nil
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(),
+			"SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			logging.Warn(ctx, "Failed to release leader lock: %v", err)
+		}
+	}()
+
+	// wait for the writes of the previous leader, which can't pass Check
+	// anymore, then let Check pass the writes of this instance
+	var pid int
+	if err := conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", fenceLockKey); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", fenceLockKey); err != nil {
+		return err
+	}
+	setSession(pid)
+	defer setSession(0)
+
+	// lead until the lock is lost or the module is stopped
+	logging.Info(ctx, "Elected leader as %s", instance)
+	setLeader(true)
+	defer setLeader(false)
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// check the session holding the lock
+			if _, err := conn.ExecContext(ctx, "SELECT 1"); err != nil {
+				logging.Error(ctx, "Lost leader lock: %v", err)
+				stop()
+				return nil
+			}
+		case <-ctx.Done():
+			stop()
+			return nil
+		}
+	}
+}
+
+// setSession records the backend process ID of the session holding the
+// leader lock, or 0 if none.
+func setSession(pid int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	sessionPID = pid
+}
+
+// setLeader records whether this instance is the leader.
+func setLeader(leader bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if leader != isLeader || since.IsZero() {
+		isLeader = leader
+		since = time.Now()
+	}
+}
@@ -0,0 +1,96 @@
+package leader_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/leader"
+	"main/models"
+	"main/store"
+	"main/store/storetest"
+)
+
+func TestDeposedLeaderCantWrite(t *testing.T) {
+	storetest.UseSQLite(t)
+	ctx := context.Background()
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	holder := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	block := storetest.NewBlock(1, common.Hash{}, "")
+	block.AddTransfer(t, storetest.Tx(t, 0), 0, token, common.Address{}, holder, 10)
+	block.Commit(t)
+	stored, err := store.Blocks.GetByNumber(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetByNumber: %v", err)
+	}
+	balances, err := store.Balances.GetByToken(ctx, token.String(), []string{holder.String()})
+	if err != nil || len(balances) != 1 {
+		t.Fatalf("GetByToken = %v, %v, want one balance", balances, err)
+	}
+	balance := balances[0]
+	onchain := "20"
+	balance.SetChecked(1, 1, &onchain, true)
+
+	defer leader.Depose()()
+	next := storetest.NewBlock(2, block.Hash(), "")
+	for name, write := range map[string]func() error{
+		"CommitBlock": func() error { return store.Blocks.CommitBlock(ctx, next.IndexedBlock) },
+		"OrphanBlock": func() error { return store.Blocks.OrphanBlock(ctx, stored) },
+		"DeleteBlock": func() error { return store.Blocks.DeleteBlock(ctx, stored) },
+		"UpdateCheck": func() error { return store.Balances.UpdateCheck(ctx, balance) },
+		"UpdateToken": func() error {
+			return store.Tokens.UpdateToken(ctx, models.NewToken(token.String()))
+		},
+		"SetReorg": func() error {
+			return store.Reorgs.SetReorg(ctx, models.NewReorg(1, stored, next.Block))
+		},
+		"Advance": func() error {
+			return store.Watermarks.Advance(ctx, store.WatermarkIndexed, 2)
+		},
+		"Rewind": func() error {
+			return store.Watermarks.Rewind(ctx, store.WatermarkIndexed, 0)
+		},
+		"SetChainHead": func() error { return store.Indexer.SetChainHead(ctx, 2) },
+		"SetActiveEndpoint": func() error {
+			return store.Indexer.SetActiveEndpoint(ctx, "https://node")
+		},
+		"SetFinality": func() error { return store.Indexer.SetFinality(ctx, "tags", nil, nil) },
+	} {
+		if err := write(); !errors.Is(err, leader.ErrNotLeader) {
+			t.Errorf("%s error = %v, want ErrNotLeader", name, err)
+		}
+	}
+
+	// nothing was written
+	got, err := store.Blocks.GetByNumber(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetByNumber: %v", err)
+	}
+	if got.GetHash() != block.Hash().String() || !got.GetCanonical() ||
+		got.GetStatus() != stored.GetStatus() {
+		t.Errorf("block = %s canonical %v status %v, want it unchanged",
+			got.GetHash(), got.GetCanonical(), got.GetStatus())
+	}
+	if _, err := store.Blocks.GetByNumber(ctx, 2); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetByNumber(2) error = %v, want ErrNotFound", err)
+	}
+	if num, err := store.Watermarks.Get(ctx, store.WatermarkIndexed); err != nil || num != 1 {
+		t.Errorf("watermark = %d, %v, want 1", num, err)
+	}
+	if reorgs, err := store.Reorgs.GetLatest(ctx, 1); err != nil || len(reorgs) != 0 {
+		t.Errorf("reorgs = %v, %v, want none", reorgs, err)
+	}
+	if _, err := store.Indexer.Get(ctx); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("indexer status error = %v, want ErrNotFound", err)
+	}
+	balances, err = store.Balances.GetByToken(ctx, token.String(), []string{holder.String()})
+	if err != nil || len(balances) != 1 {
+		t.Fatalf("GetByToken = %v, %v, want one balance", balances, err)
+	}
+	if balances[0].GetBalance() != "10" || balances[0].GetMismatch() {
+		t.Errorf("balance = %s mismatch %v, want 10 unchecked",
+			balances[0].GetBalance(), balances[0].GetMismatch())
+	}
+}
@@ -0,0 +1,114 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"main/config"
+	"main/global"
+	"main/logging"
+)
+
+// module is a registered module and the function stopping it.
+type module struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Static configuration variables initalized at runtime.
+var gracePeriod time.Duration
+
+// Registered modules and the shutdown state.
+var (
+	mutex    sync.Mutex
+	modules  []module
+	once     sync.Once
+	stopping = make(chan struct{})
+	stopped  = make(chan struct{})
+)
+
+// Lifecycle root context.
+var rootCtx = context.Background()
+
+// init loads the lifecycle configurations.
+func init() {
+	gracePeriod = config.GetMilliseconds("SERVER_SHUTDOWN_GRACE_PERIOD_MS")
+}
+
+// Initialize initializes the lifecycle module, starting the shutdown on
+// SIGINT or SIGTERM.
+func Initialize(ctx context.Context) {
+	rootCtx = ctx
+
+	// Catch signals in a separate goroutine.
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			logging.Warn(ctx, "Received signal: %s.", sig.String())
+			Shutdown()
+		case <-stopping:
+		}
+		signal.Stop(sigChan)
+	}()
+}
+
+// Register registers a module to be stopped on shutdown. Modules are stopped
+// one at a time in the reverse order of registration, so a module should be
+// registered after the modules it depends on. The context passed to stop
+// expires at the end of the shutdown grace period.
+func Register(name string, stop func(ctx context.Context) error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	modules = append(modules, module{name, stop})
+}
+
+// Stopping returns a channel that's closed once the shutdown has begun.
+func Stopping() <-chan struct{} {
+	return stopping
+}
+
+// Shutdown stops all registered modules and returns once they're stopped.
+// Concurrent and later calls wait for the first shutdown to complete.
+func Shutdown() {
+	once.Do(func() {
+		go shutdown()
+	})
+	<-stopped
+}
+
+// Wait blocks until the shutdown has begun and all modules are stopped.
+func Wait() {
+	<-stopped
+}
+
+// shutdown stops all registered modules in reverse order within the grace
+// period.
+func shutdown() {
+	defer close(stopped)
+
+	// Mark the service as shutting down.
+	logging.Warn(rootCtx, "Initiating graceful shutdown...")
+	global.Alive = false
+	close(stopping)
+
+	ctx, cancel := context.WithTimeout(rootCtx, gracePeriod)
+	defer cancel()
+
+	// Stop modules in the reverse order of registration.
+	mutex.Lock()
+	registered := modules
+	mutex.Unlock()
+	for i := len(registered) - 1; i >= 0; i-- {
+		m := registered[i]
+		logging.Info(rootCtx, "Stopping %s...", m.name)
+		if err := m.stop(ctx); err != nil {
+			logging.Error(rootCtx, "Failed to stop %s: %v", m.name, err)
+		}
+	}
+}
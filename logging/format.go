@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Fields are structured key/value pairs attached to log entries.
+type Fields map[string]interface{}
+
+// contextKeyFields is the context key for the fields attached to a context.
+type contextKeyFields struct{}
+
+// Log level to plain level name.
+var logLevelNames = []string{
+	"",
+	"CRITICAL",
+	"ERROR",
+	"WARNING",
+	"INFO",
+	"DEBUG",
+	"",
+}
+
+// ansiEscape matches ANSI terminal escape sequences.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Entry is a single log entry.
+type Entry struct {
+	Time      time.Time
+	Level     uint
+	Module    string
+	RequestID string
+	UserID    string
+	Caller    string
+	Message   string
+	Fields    Fields
+}
+
+// WithFields returns a copy of the context carrying the given fields merged
+// over the fields already attached to it. All entries logged with the context
+// include the fields.
+func WithFields(ctx context.Context, fields Fields) context.Context {
+	return context.WithValue(ctx, contextKeyFields{},
+		mergeFields(getFields(ctx), fields))
+}
+
+// mergeFields returns a new set of fields with b merged over a.
+func mergeFields(a, b Fields) Fields {
+	merged := make(Fields, len(a)+len(b))
+	for key, value := range a {
+		merged[key] = value
+	}
+	for key, value := range b {
+		merged[key] = value
+	}
+	return merged
+}
+
+// getFields returns the fields attached to the context.
+func getFields(ctx context.Context) Fields {
+	fields, _ := ctx.Value(contextKeyFields{}).(Fields)
+	return fields
+}
+
+// writeText writes the entry as colored text meant for terminals.
+func writeText(w io.Writer, e *Entry) {
+	// now is the entry Unix timestamp in floating point.
+	now := float64(e.Time.UnixNano()) / float64(time.Second)
+
+	// Append the caller to errors only, to keep the output compact.
+	message := e.Message
+	if e.Level <= logLevelError && len(e.Caller) > 0 {
+		message = fmt.Sprintf("%s (%s)", message, e.Caller)
+	}
+
+	// Append fields sorted by key.
+	if len(e.Fields) > 0 {
+		keys := make([]string, 0, len(e.Fields))
+		for key := range e.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var b strings.Builder
+		b.WriteString(message)
+		for _, key := range keys {
+			fmt.Fprintf(&b, " \x1b[36m%s\x1b[m=%v", key, e.Fields[key])
+		}
+		message = b.String()
+	}
+
+	fmt.Fprintf(w,
+		"\x1b[m\r\x1b[100m%f\x1b[m %s\x1b[m \x1b[100m%12s\x1b[m %s\n",
+		now, logLabels[e.Level], e.RequestID, message)
+}
+
+// jsonEntry is the JSON representation of an entry.
+type jsonEntry struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Module    string `json:"module,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	Caller    string `json:"caller,omitempty"`
+	Message   string `json:"message"`
+	Fields    Fields `json:"fields,omitempty"`
+}
+
+// writeJSON writes the entry as a single line JSON object.
+func writeJSON(w io.Writer, e *Entry) {
+	je := jsonEntry{
+		Timestamp: e.Time.UTC().Format(time.RFC3339Nano),
+		Level:     logLevelNames[e.Level],
+		Module:    e.Module,
+		RequestID: e.RequestID,
+		UserID:    e.UserID,
+		Caller:    e.Caller,
+		Message:   ansiEscape.ReplaceAllString(e.Message, ""),
+		Fields:    e.Fields,
+	}
+	line, err := json.Marshal(je)
+	if err != nil {
+		// Fall back to logging fields as strings if values can't be encoded.
+		je.Fields = Fields{}
+		for key, value := range e.Fields {
+			je.Fields[key] = fmt.Sprint(value)
+		}
+		line, _ = json.Marshal(je)
+	}
+
+	w.Write(append(line, '\n'))
+}
@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Levels is the set of level thresholds applied before entries reach sinks.
+type Levels struct {
+	// Default is the level of modules without an explicit level.
+	Default uint `json:"default"`
+
+	// Modules maps module names, e.g. "eth_index" or "api", to their level.
+	// A module level also applies to the module's subpackages.
+	Modules map[string]uint `json:"modules"`
+}
+
+// Current level thresholds and the highest level of any module.
+var levelsMutex sync.RWMutex
+var defaultLevel uint
+var moduleLevels = map[string]uint{}
+var maxModuleLevel uint
+
+// ParseLevel parses a level given as number (1-5) or name, e.g. "debug".
+func ParseLevel(value string) (uint, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if level, err := strconv.ParseUint(value, 10, 32); err == nil {
+		if level <= logLevelFirst || level >= logLevelLast {
+			return 0, fmt.Errorf("log level out of range: %d", level)
+		}
+		return uint(level), nil
+	}
+
+	switch value {
+	case "critical", "crit":
+		return logLevelCritical, nil
+	case "error":
+		return logLevelError, nil
+	case "warning", "warn":
+		return logLevelWarn, nil
+	case "info":
+		return logLevelInfo, nil
+	case "debug":
+		return logLevelDebug, nil
+	}
+	return 0, fmt.Errorf("invalid log level: %s", value)
+}
+
+// parseModuleLevels parses a comma separated list of module=level pairs.
+func parseModuleLevels(value string) (map[string]uint, error) {
+	levels := map[string]uint{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) <= 0 {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) <= 0 {
+			return nil, fmt.Errorf("invalid module level: %s", pair)
+		}
+		level, err := ParseLevel(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(parts[0])] = level
+	}
+	return levels, nil
+}
+
+// GetLevels returns the current level thresholds.
+func GetLevels() Levels {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	levels := Levels{Default: defaultLevel, Modules: map[string]uint{}}
+	for module, level := range moduleLevels {
+		levels.Modules[module] = level
+	}
+	return levels
+}
+
+// SetLevel changes the level of modules without an explicit level.
+func SetLevel(level uint) error {
+	if level <= logLevelFirst || level >= logLevelLast {
+		return fmt.Errorf("log level out of range: %d", level)
+	}
+
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	defaultLevel = level
+	updateMaxModuleLevel()
+	return nil
+}
+
+// SetModuleLevel changes the level of a module and its subpackages.
+func SetModuleLevel(module string, level uint) error {
+	if level <= logLevelFirst || level >= logLevelLast {
+		return fmt.Errorf("log level out of range: %d", level)
+	}
+	if len(module) <= 0 {
+		return fmt.Errorf("empty module name")
+	}
+
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	moduleLevels[module] = level
+	updateMaxModuleLevel()
+	return nil
+}
+
+// setModuleLevels replaces the levels of all modules.
+func setModuleLevels(levels map[string]uint) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	moduleLevels = map[string]uint{}
+	for module, level := range levels {
+		moduleLevels[module] = level
+	}
+	updateMaxModuleLevel()
+}
+
+// ClearModuleLevel makes a module fall back to the default level.
+func ClearModuleLevel(module string) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	delete(moduleLevels, module)
+	updateMaxModuleLevel()
+}
+
+// updateMaxModuleLevel recomputes the highest level of any module. The caller
+// must hold the levels mutex.
+func updateMaxModuleLevel() {
+	maxModuleLevel = defaultLevel
+	for _, level := range moduleLevels {
+		if level > maxModuleLevel {
+			maxModuleLevel = level
+		}
+	}
+}
+
+// getMaxModuleLevel returns the highest level of any module.
+func getMaxModuleLevel() uint {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+	return maxModuleLevel
+}
+
+// moduleLevel returns the level of the module, using the level of the
+// closest configured parent module, or the default level.
+func moduleLevel(module string) uint {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	for name := module; len(name) > 0; {
+		if level, exists := moduleLevels[name]; exists {
+			return level
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return defaultLevel
+}
+
+// moduleOf returns the module name of the function at the program counter,
+// which is its package path relative to the main module, e.g. "eth_index" or
+// "api/middleware".
+func moduleOf(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	// Function names have the form "main/api/middleware.Logger.func1".
+	name := fn.Name()
+	pkg := name
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		if j := strings.Index(name[i:], "."); j >= 0 {
+			pkg = name[:i+j]
+		}
+	} else if j := strings.Index(name, "."); j >= 0 {
+		pkg = name[:j]
+	}
+
+	return strings.TrimPrefix(pkg, "main/")
+}
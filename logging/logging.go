@@ -1,15 +1,16 @@
 package logging
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"main/config"
 	"main/global"
-	"os"
 	"path"
 	"runtime"
 	"time"
 
-	"cloud.google.com/go/logging"
 	"golang.org/x/net/context"
 )
 
@@ -19,22 +20,12 @@ var ContextKeyRequestID = "request_id"
 // ContextKeyUserID ...
 var ContextKeyUserID = "user_id"
 
-// Logger is our logger instance abstraction.
-type Logger struct {
-	*logging.Logger
-}
-
-// Singleton StackDriver client.
-var stackDriverClient *logging.Client
-
-// StackDriverLogger is the singleton StackDriver logger instance.
-var StackDriverLogger = &Logger{}
-
 // Static configuration variables initalized at runtime.
-var logLevel uint
-var stackDriverEnabled bool
-var gRPCConnectTimeout time.Duration
-var projectID string
+var logFormat string
+var sinkNames []string
+
+// appliedConfig is the last applied logging configuration.
+var appliedConfig config.LogConfig
 
 // Log levels.
 const (
@@ -58,76 +49,90 @@ var logLabels = []string{
 	"",
 }
 
-// Log level to StackDriver severity.
-var logSeverities = []logging.Severity{
-	logging.Default,
-	logging.Critical,
-	logging.Error,
-	logging.Warning,
-	logging.Info,
-	logging.Debug,
-	logging.Default,
-}
-
 // init loads the logging configurations.
 func init() {
-	logLevel = config.GetUint("LOG_LEVEL")
-	stackDriverEnabled = config.GetBool("STACKDRIVER_ENABLED")
-	gRPCConnectTimeout = config.GetMilliseconds("GRPC_CONNECT_TIMEOUT_MS")
-	projectID = config.GetString("PROJECT_ID")
-}
+	logFormat = config.GetString("LOG_FORMAT")
+	if logFormat != FormatText && logFormat != FormatJSON {
+		panic("invalid log format: " + logFormat)
+	}
+	sinkNames = parseSinkNames(config.GetString("LOG_SINKS"))
+	if config.GetBool("STACKDRIVER_ENABLED") {
+		sinkNames = append(sinkNames, sinkStackDriver)
+	}
 
-// Initialize initializes the logger module.
-func Initialize(ctx context.Context) {
-	// Do not setup StackDriver client if not configured.
-	if !stackDriverEnabled {
-		return
+	// Apply levels and rate limits, and again whenever they are reloaded.
+	if err := applyConfig(config.Get().Log); err != nil {
+		panic(err)
 	}
+	config.Subscribe(reloadConfig)
 
-	// Setup timeout context for connecting to StackDriver.
-	timeoutCtx, cancel := context.WithTimeout(ctx, gRPCConnectTimeout)
-	defer cancel()
+	// Log to standard output until the configured sinks are initialized.
+	resetSinks()
+}
 
-	// Create StackDriver logger client.
-	var err error
-	stackDriverClient, err = logging.NewClient(timeoutCtx, projectID)
+// applyConfig applies the reloadable level and rate limit settings.
+func applyConfig(cfg config.LogConfig) error {
+	levels, err := parseModuleLevels(cfg.ModuleLevels)
 	if err != nil {
-		panic(err)
+		return err
 	}
-
-	// Check StackDriver connection.
-	if err = stackDriverClient.Ping(timeoutCtx); err != nil {
-		panic(err)
+	if err := SetLevel(cfg.Level); err != nil {
+		return err
 	}
+	setModuleLevels(levels)
+	SetRateLimit(cfg.RateLimitBurst,
+		time.Duration(cfg.RateLimitInterval)*time.Millisecond)
+	updateSinkLevels()
 
-	// Create StackDriver logger instance.
-	StackDriverLogger = &Logger{stackDriverClient.Logger(global.ServiceName)}
+	appliedConfig = cfg
+	return nil
 }
 
-// Finalize finalizes the logging module.
-func Finalize() {
-	// Check if client and logger are valid.
-	if stackDriverClient == nil || StackDriverLogger == nil {
+// reloadConfig applies reloaded logging settings. Levels changed at runtime
+// through the admin API are kept unless the logging settings changed.
+func reloadConfig(cfg *config.Config) {
+	if cfg.Log == appliedConfig {
+		return
+	}
+	if err := applyConfig(cfg.Log); err != nil {
+		Error(context.Background(), "Failed to apply logging settings: %v", err)
 		return
 	}
+	Info(context.Background(), "Applied reloaded logging settings")
+}
 
-	// Flush logs and properly close logging service connection.
-	if err := stackDriverClient.Close(); err != nil {
-		now := float64(time.Now().UnixNano()) / float64(time.Second)
-		fmt.Fprintf(os.Stderr,
-			"\r\x1b[100m%f\x1b[m %s\x1b[m \x1b[100m%12s\x1b[m %s\n",
-			now, logLabels[logLevelError], global.ServiceName, err.Error())
+// Initialize initializes the logger module and the configured log sinks.
+func Initialize(ctx context.Context) {
+	handles := []*sinkHandle{}
+	for _, name := range sinkNames {
+		sink, err := newSink(ctx, name)
+		if err != nil {
+			panic(fmt.Sprintf("failed to create log sink %s: %v", name, err))
+		}
+		handles = append(handles, &sinkHandle{
+			name:  name,
+			sink:  sink,
+			level: sinkLevel(name),
+		})
 	}
+	setSinks(handles)
+}
+
+// Finalize finalizes the logging module, flushing and closing all log sinks.
+// Entries logged afterwards are written to standard output only.
+func Finalize() {
+	closeSinks()
+	resetSinks()
 }
 
 // Critical logs a message of critical severity.
 func Critical(requestCtx context.Context, format string, args ...interface{}) {
-	logWithLineNumber(requestCtx, logLevelCritical, format, args...)
+	log(requestCtx, logLevelCritical, format, args...)
 }
 
 // Error logs a message of error severity.
 func Error(requestCtx context.Context, format string, args ...interface{}) {
-	logWithLineNumber(requestCtx, logLevelError, format, args...)
+	log(requestCtx, logLevelError, format, args...)
 }
 
 // Warn logs a message of warning severity.
@@ -145,53 +150,85 @@ func Debug(requestCtx context.Context, format string, args ...interface{}) {
 	log(requestCtx, logLevelDebug, format, args...)
 }
 
-// log is the general logging utility function used by all log levels.
+// WithRequestID returns a copy of the context carrying the given request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ContextKeyRequestID, requestID)
+}
+
+// GetRequestID returns the request ID carried by the context, if any.
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(ContextKeyRequestID).(string)
+	return requestID
+}
+
+// NewRequestID generates a random 12 hex digit request ID.
+func NewRequestID() string {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%012x", time.Now().UnixNano()&0xffffffffffff)
+	}
+	return hex.EncodeToString(id)
+}
+
+// log is the general logging utility function used by all log levels. It
+// must be called directly by the exported logging functions, so that the
+// caller of those can be determined.
 func log(requestCtx context.Context, level uint, format string, args ...interface{}) {
-	// Perform logging only if configured above and within valid log level.
-	if level <= logLevelFirst || level >= logLevelLast || level > logLevel {
+	// Perform logging only if any module and any sink accept the level.
+	if level <= logLevelFirst || level >= logLevelLast ||
+		level > getMaxModuleLevel() || level > getMaxLevel() {
 		return
 	}
 
-	// Compose log message.
-	message := fmt.Sprintf(format, args...)
-	userID, _ := requestCtx.Value(ContextKeyUserID).(string)
-	requestID, _ := requestCtx.Value(ContextKeyRequestID).(string)
-	if len(requestID) <= 0 {
-		requestID = global.ServiceName
+	// Get the caller and check the level of its module.
+	pc, filepath, line, ok := runtime.Caller(2)
+	module := ""
+	if ok {
+		module = moduleOf(pc)
 	}
-
-	// Log to StackDriver logging service
-	if stackDriverClient != nil && StackDriverLogger != nil {
-		StackDriverLogger.Log(logging.Entry{
-			Severity: logSeverities[level],
-			Payload:  message,
-			Labels: map[string]string{
-				"request_id": requestID,
-				"user_id":    userID,
-			},
-		})
+	if level > moduleLevel(module) {
+		return
 	}
 
-	// now is the current Unix timestamp in floating point.
-	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	// Drop repetitive warnings and errors logged by the same call site.
+	var suppressed uint
+	if ok && level <= logLevelWarn {
+		var allowed bool
+		if allowed, suppressed = allowEntry(pc, time.Now()); !allowed {
+			return
+		}
+	}
 
-	// Reset terminal color.
-	fmt.Print("\x1b[m")
+	// Compose log entry.
+	e := &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Module:  module,
+		Message: fmt.Sprintf(format, args...),
+		Fields:  getFields(requestCtx),
+	}
+	e.UserID, _ = requestCtx.Value(ContextKeyUserID).(string)
+	e.RequestID, _ = requestCtx.Value(ContextKeyRequestID).(string)
+	if len(e.RequestID) <= 0 {
+		e.RequestID = global.ServiceName
+	}
+	if ok {
+		e.Caller = fmt.Sprintf("%s:%d", path.Base(filepath), line)
+	}
+	if suppressed > 0 {
+		e.Fields = mergeFields(e.Fields, Fields{"suppressed": suppressed})
+	}
 
-	// Log to standard output.
-	fmt.Fprintf(os.Stdout,
-		"\r\x1b[100m%f\x1b[m %s\x1b[m \x1b[100m%12s\x1b[m %s\n",
-		now, logLabels[level], requestID, message)
+	// Dispatch the entry to all sinks accepting the level.
+	dispatch(e)
 }
 
-// logWithLineNumber performs usual logging but with an extra line number arg.
-func logWithLineNumber(requestCtx context.Context, level uint, format string,
-	args ...interface{}) {
-	// Get caller file name and line number.
-	_, filepath, line, ok := runtime.Caller(2)
-	if ok {
-		filename := path.Base(filepath)
-		format = fmt.Sprintf("%s (%s:%d)", format, filename, line)
+// writeEntry writes the entry in the given output format.
+func writeEntry(w io.Writer, format string, e *Entry) {
+	switch format {
+	case FormatJSON:
+		writeJSON(w, e)
+	default:
+		writeText(w, e)
 	}
-	log(requestCtx, level, format, args...)
 }
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// callSiteLimit tracks the entries logged by a call site in the current window.
+type callSiteLimit struct {
+	windowStart time.Time
+	count       uint
+	suppressed  uint
+}
+
+// Per call site rate limits for warnings and errors. A zero burst disables
+// rate limiting.
+var rateLimitMutex sync.Mutex
+var rateLimits = map[uintptr]*callSiteLimit{}
+var rateLimitBurst uint
+var rateLimitInterval time.Duration
+
+// SetRateLimit limits warnings and errors to burst entries per call site
+// within each interval. Entries beyond the limit are dropped and their count
+// is reported with the next entry logged by the call site. A zero burst or
+// interval disables rate limiting.
+func SetRateLimit(burst uint, interval time.Duration) {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	rateLimitBurst = burst
+	rateLimitInterval = interval
+	rateLimits = map[uintptr]*callSiteLimit{}
+}
+
+// allowEntry returns whether an entry from the call site at pc may be logged,
+// and how many entries of the call site were dropped since the last one.
+func allowEntry(pc uintptr, now time.Time) (bool, uint) {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+
+	if rateLimitBurst <= 0 || rateLimitInterval <= 0 {
+		return true, 0
+	}
+
+	// Start a new window if the current one has expired.
+	limit, exists := rateLimits[pc]
+	if !exists {
+		limit = &callSiteLimit{windowStart: now}
+		rateLimits[pc] = limit
+	}
+	if now.Sub(limit.windowStart) >= rateLimitInterval {
+		limit.windowStart = now
+		limit.count = 0
+	}
+
+	// Drop the entry if the burst is exhausted.
+	if limit.count >= rateLimitBurst {
+		limit.suppressed++
+		return false, 0
+	}
+	limit.count++
+
+	suppressed := limit.suppressed
+	limit.suppressed = 0
+	return true, suppressed
+}
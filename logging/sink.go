@@ -0,0 +1,209 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"main/config"
+)
+
+// Names of the built-in sinks, as used in LOG_SINKS.
+const (
+	sinkStdout      = "stdout"
+	sinkFile        = "file"
+	sinkSyslog      = "syslog"
+	sinkOTLP        = "otlp"
+	sinkStackDriver = "stackdriver"
+)
+
+// Sink is a destination for log entries.
+type Sink interface {
+	// Write writes a single entry. Writes to a sink are serialized.
+	Write(e *Entry) error
+
+	// Close flushes pending entries and releases the sink's resources.
+	Close() error
+}
+
+// sinkHandle is a registered sink with its level threshold.
+type sinkHandle struct {
+	sync.Mutex
+	name  string
+	sink  Sink
+	level uint
+}
+
+// Registered sinks and the highest level accepted by any of them.
+var sinksMutex sync.RWMutex
+var sinks []*sinkHandle
+var maxLevel uint
+
+// AddSink registers a sink receiving entries up to the given level, using the
+// same numbering as LOG_LEVEL.
+func AddSink(name string, sink Sink, level uint) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	sinks = append(sinks, &sinkHandle{name: name, sink: sink, level: level})
+	updateMaxLevel()
+}
+
+// setSinks replaces the registered sinks.
+func setSinks(handles []*sinkHandle) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	sinks = handles
+	updateMaxLevel()
+}
+
+// resetSinks registers standard output as the only sink.
+func resetSinks() {
+	setSinks([]*sinkHandle{{
+		name:  sinkStdout,
+		sink:  &writerSink{w: os.Stdout, format: logFormat},
+		level: sinkLevel(sinkStdout),
+	}})
+}
+
+// updateSinkLevels reloads the level thresholds of the built-in sinks.
+func updateSinkLevels() {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	for _, h := range sinks {
+		if config.Has(sinkLevelKey(h.name)) {
+			h.level = sinkLevel(h.name)
+		}
+	}
+	updateMaxLevel()
+}
+
+// closeSinks flushes and closes all registered sinks.
+func closeSinks() {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+	for _, h := range sinks {
+		h.Lock()
+		if err := h.sink.Close(); err != nil {
+			reportSinkError(h.name, err)
+		}
+		h.Unlock()
+	}
+}
+
+// updateMaxLevel recomputes the highest level accepted by any sink. The
+// caller must hold the sinks mutex.
+func updateMaxLevel() {
+	maxLevel = logLevelFirst
+	for _, h := range sinks {
+		if h.level > maxLevel {
+			maxLevel = h.level
+		}
+	}
+}
+
+// getMaxLevel returns the highest level accepted by any sink.
+func getMaxLevel() uint {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+	return maxLevel
+}
+
+// dispatch writes the entry to every sink accepting its level.
+func dispatch(e *Entry) {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+	for _, h := range sinks {
+		if e.Level > h.level {
+			continue
+		}
+		h.Lock()
+		err := h.sink.Write(e)
+		h.Unlock()
+		if err != nil {
+			reportSinkError(h.name, err)
+		}
+	}
+}
+
+// reportSinkError reports a failing sink on standard error. It must not log
+// through the sinks to avoid recursion.
+func reportSinkError(name string, err error) {
+	writeEntry(os.Stderr, logFormat, &Entry{
+		Time:    time.Now(),
+		Level:   logLevelError,
+		Message: fmt.Sprintf("Log sink %s failed: %v", name, err),
+	})
+}
+
+// parseSinkNames parses the comma separated list of sink names.
+func parseSinkNames(value string) []string {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sinkLevel returns the level threshold of the named sink, configured with
+// LOG_<NAME>_LEVEL.
+func sinkLevel(name string) uint {
+	return config.GetUint(sinkLevelKey(name))
+}
+
+// sinkLevelKey returns the setting of the named sink's level threshold.
+func sinkLevelKey(name string) string {
+	return fmt.Sprintf("LOG_%s_LEVEL", strings.ToUpper(name))
+}
+
+// newSink creates the named built-in sink from its configuration.
+func newSink(ctx context.Context, name string) (Sink, error) {
+	switch name {
+	case sinkStdout:
+		return &writerSink{w: os.Stdout, format: logFormat}, nil
+	case sinkFile:
+		return newFileSink(
+			config.GetString("LOG_FILE_PATH"),
+			config.GetString("LOG_FILE_FORMAT"),
+			config.GetInt64("LOG_FILE_MAX_SIZE_MB")*1024*1024,
+			config.GetMilliseconds("LOG_FILE_MAX_AGE_MS"),
+			config.GetInt("LOG_FILE_MAX_BACKUPS"))
+	case sinkSyslog:
+		return newSyslogSink(
+			config.GetString("LOG_SYSLOG_NETWORK"),
+			config.GetString("LOG_SYSLOG_ADDRESS"))
+	case sinkOTLP:
+		return newOTLPSink(ctx, config.GetString("LOG_OTLP_ENDPOINT"))
+	case sinkStackDriver:
+		return newStackDriverSink(ctx,
+			config.GetString("PROJECT_ID"),
+			config.GetMilliseconds("GRPC_CONNECT_TIMEOUT_MS"))
+	default:
+		return nil, fmt.Errorf("unknown sink")
+	}
+}
+
+// writerSink writes entries to an open file such as standard output.
+type writerSink struct {
+	w      *os.File
+	format string
+}
+
+// Write implements the Sink interface.
+func (s *writerSink) Write(e *Entry) error {
+	writeEntry(s.w, s.format, e)
+	return nil
+}
+
+// Close implements the Sink interface.
+func (s *writerSink) Close() error {
+	// Syncing fails on terminals and pipes, which is harmless.
+	s.w.Sync()
+	return nil
+}
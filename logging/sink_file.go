@@ -0,0 +1,174 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the timestamp format appended to rotated log files.
+const backupTimeFormat = "20060102T150405.000"
+
+// fileSink writes entries to a local file, rotating it once it exceeds the
+// maximum size or age. Rotated files are renamed with a timestamp suffix and
+// only the newest maxBackups of them are kept.
+type fileSink struct {
+	path       string
+	format     string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// newFileSink creates a rotating file sink. A zero maxSize or maxAge disables
+// the respective rotation trigger.
+func newFileSink(path, format string, maxSize int64, maxAge time.Duration,
+	maxBackups int) (*fileSink, error) {
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("invalid log format: %s", format)
+	}
+
+	s := &fileSink{
+		path:       path,
+		format:     format,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Write implements the Sink interface.
+func (s *fileSink) Write(e *Entry) error {
+	// Rotate before writing if the current file is too large or too old.
+	if s.shouldRotate() {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	w := &countingWriter{w: s.file}
+	writeEntry(w, s.format, e)
+	s.size += w.n
+	return w.err
+}
+
+// Close implements the Sink interface.
+func (s *fileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// open opens the log file for appending.
+func (s *fileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+// shouldRotate returns whether the current file exceeds the size or age limit.
+func (s *fileSink) shouldRotate() bool {
+	if s.maxSize > 0 && s.size >= s.maxSize {
+		return true
+	}
+	if s.maxAge > 0 && time.Since(s.openedAt) >= s.maxAge {
+		return true
+	}
+	return false
+}
+
+// rotate renames the current file to a timestamped backup, opens a new file
+// and removes backups beyond the retention count.
+func (s *fileSink) rotate() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.%s", s.path, time.Now().Format(backupTimeFormat))
+	if err := os.Rename(s.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	return s.removeOldBackups()
+}
+
+// removeOldBackups removes all but the newest maxBackups rotated files.
+func (s *fileSink) removeOldBackups() error {
+	if s.maxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return err
+	}
+
+	// Backup suffixes sort chronologically.
+	names := []string{}
+	for _, backup := range backups {
+		suffix := strings.TrimPrefix(backup, s.path+".")
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			names = append(names, backup)
+		}
+	}
+	sort.Strings(names)
+
+	for len(names) > s.maxBackups {
+		if err := os.Remove(names[0]); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+
+	return nil
+}
+
+// countingWriter counts the bytes written and keeps the first error.
+type countingWriter struct {
+	w   *os.File
+	n   int64
+	err error
+}
+
+// Write implements the io.Writer interface.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"golang.org/x/net/context"
+
+	"main/global"
+)
+
+// Log level to OpenTelemetry severity.
+var otlpSeverities = []otellog.Severity{
+	otellog.SeverityUndefined,
+	otellog.SeverityFatal,
+	otellog.SeverityError,
+	otellog.SeverityWarn,
+	otellog.SeverityInfo,
+	otellog.SeverityDebug,
+	otellog.SeverityUndefined,
+}
+
+// otlpSink exports entries as OpenTelemetry log records over OTLP/HTTP.
+type otlpSink struct {
+	provider *sdklog.LoggerProvider
+	logger   otellog.Logger
+}
+
+// newOTLPSink creates an OTLP log exporter sending to the given endpoint.
+func newOTLPSink(ctx context.Context, endpoint string) (Sink, error) {
+	exporter, err := otlploghttp.New(ctx,
+		otlploghttp.WithEndpoint(endpoint),
+		otlploghttp.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", global.ServiceName))),
+	)
+
+	return &otlpSink{
+		provider: provider,
+		logger:   provider.Logger("main/logging"),
+	}, nil
+}
+
+// Write implements the Sink interface.
+func (s *otlpSink) Write(e *Entry) error {
+	var record otellog.Record
+	record.SetTimestamp(e.Time)
+	record.SetSeverity(otlpSeverities[e.Level])
+	record.SetSeverityText(logLevelNames[e.Level])
+	record.SetBody(otellog.StringValue(ansiEscape.ReplaceAllString(e.Message, "")))
+	record.AddAttributes(
+		otellog.String("request_id", e.RequestID),
+		otellog.String("user_id", e.UserID),
+		otellog.String("code.caller", e.Caller))
+	for key, value := range e.Fields {
+		record.AddAttributes(otellog.String(key, fmt.Sprint(value)))
+	}
+
+	s.logger.Emit(context.Background(), record)
+	return nil
+}
+
+// Close implements the Sink interface.
+func (s *otlpSink) Close() error {
+	return s.provider.Shutdown(context.Background())
+}
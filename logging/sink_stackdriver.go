@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"golang.org/x/net/context"
+
+	"main/global"
+)
+
+// Logger is our logger instance abstraction.
+type Logger struct {
+	*logging.Logger
+}
+
+// StackDriverLogger is the singleton StackDriver logger instance.
+var StackDriverLogger = &Logger{}
+
+// Log level to StackDriver severity.
+var logSeverities = []logging.Severity{
+	logging.Default,
+	logging.Critical,
+	logging.Error,
+	logging.Warning,
+	logging.Info,
+	logging.Debug,
+	logging.Default,
+}
+
+// stackDriverSink writes entries to Google Cloud Logging.
+type stackDriverSink struct {
+	client *logging.Client
+}
+
+// newStackDriverSink connects to Google Cloud Logging for the project.
+func newStackDriverSink(ctx context.Context, projectID string,
+	connectTimeout time.Duration) (Sink, error) {
+	// Setup timeout context for connecting to StackDriver.
+	timeoutCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	// Create StackDriver logger client.
+	client, err := logging.NewClient(timeoutCtx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check StackDriver connection.
+	if err = client.Ping(timeoutCtx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	// Create StackDriver logger instance.
+	StackDriverLogger = &Logger{client.Logger(global.ServiceName)}
+
+	return &stackDriverSink{client: client}, nil
+}
+
+// Write implements the Sink interface.
+func (s *stackDriverSink) Write(e *Entry) error {
+	labels := map[string]string{
+		"request_id": e.RequestID,
+		"user_id":    e.UserID,
+		"caller":     e.Caller,
+	}
+	for key, value := range e.Fields {
+		labels[key] = fmt.Sprint(value)
+	}
+
+	StackDriverLogger.Log(logging.Entry{
+		Severity: logSeverities[e.Level],
+		Payload:  e.Message,
+		Labels:   labels,
+	})
+	return nil
+}
+
+// Close implements the Sink interface. It flushes buffered entries.
+func (s *stackDriverSink) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,57 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logging
+
+import (
+	"bytes"
+	"log/syslog"
+
+	"main/global"
+)
+
+// syslogSink writes entries to the system logger as JSON messages.
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+// newSyslogSink connects to the syslog daemon. An empty network and address
+// connect to the local daemon.
+func newSyslogSink(network, address string) (Sink, error) {
+	tag := global.ServiceName
+	if len(tag) <= 0 {
+		tag = "assignment"
+	}
+
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{writer: writer}, nil
+}
+
+// Write implements the Sink interface.
+func (s *syslogSink) Write(e *Entry) error {
+	var buf bytes.Buffer
+	writeJSON(&buf, e)
+	message := buf.String()
+
+	switch e.Level {
+	case logLevelCritical:
+		return s.writer.Crit(message)
+	case logLevelError:
+		return s.writer.Err(message)
+	case logLevelWarn:
+		return s.writer.Warning(message)
+	case logLevelInfo:
+		return s.writer.Info(message)
+	default:
+		return s.writer.Debug(message)
+	}
+}
+
+// Close implements the Sink interface.
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,11 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package logging
+
+import "errors"
+
+// newSyslogSink reports that syslog is not supported on this platform.
+func newSyslogSink(network, address string) (Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
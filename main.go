@@ -1,53 +1,82 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"main/config"
-	"main/database"
-	"main/eth_index"
-	"main/global"
-	"main/logging"
-	"main/server"
 	_ "net/http/pprof"
+	"os"
+	"strings"
+
+	"main/config"
 )
 
+// command is a subcommand of the binary.
+type command struct {
+	// A one line description of the command.
+	description string
+
+	// Runs the command with the arguments following its name.
+	run func(args []string) error
+
+	// Whether the command requires the RPC endpoints to be configured.
+	rpc bool
+}
+
+// commands are the subcommands by name. Without a subcommand, "all" is run.
+var commands = map[string]command{
+	"all":      {"run the API, the realtime indexer and the startup sync", runAll, true},
+	"serve":    {"run the API only", runServe, false},
+	"index":    {"run the realtime indexer only", runIndex, true},
+	"backfill": {"sync a range of blocks and exit", runBackfill, true},
+	"verify":   {"check indexed blocks for gaps, broken links and chain mismatches", runVerify, true},
+	"migrate":  {"apply, roll back or list schema migrations", runMigrate, false},
+	"export":   {"export indexed blocks with their transactions as JSON lines", runExport, false},
+	"config":   {"print the effective configuration with secrets masked", runConfig, false},
+	"bench":    {"measure the rows per second of the database write paths", runBench, true},
+}
+
+// commandOrder is the order commands are listed in the usage.
+var commandOrder = []string{
+	"all", "serve", "index", "backfill", "verify", "migrate", "export", "config", "bench"}
+
 func main() {
-	// Prepare readiness & liveness flags.
-	global.Ready = false
-	global.Alive = false
-
-	// Create root context.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Setup logging module.
-	// NOTE: This should always be first.
-	logging.Initialize(ctx)
-	defer logging.Finalize()
-
-	// Setup database module
-	database.Initialize(ctx)
-	defer database.Finalize()
-
-	// Setup etn_index module
-	eth_index.Initialize(ctx)
-	defer eth_index.Finalize()
-	eth_index.SyncLastestBlocks(ctx)
-
-	// Create HTTP server instance to listen on all interfaces.
-	address := fmt.Sprintf("%s:%s",
-		config.GetString("SERVER_LISTEN_ADDRESS"),
-		config.GetString("SERVER_LISTEN_PORT"))
-	server := server.CreateServer(ctx, address)
-
-	// Set readiness & liveness flags.
-	global.Ready = true
-	global.Alive = true
-
-	// Start servicing requests.
-	logging.Info(ctx, "Initialization complete, listening on %s...", address)
-	if err := server.ListenAndServe(); err != nil {
-		logging.Info(ctx, err.Error())
+	// Select the subcommand, running everything if none is given.
+	name, args := "all", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	// Report an invalid configuration, which "config print" lists itself.
+	if err := config.Err(); err != nil && name != "config" {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+	if err := config.RPCErr(); err != nil && cmd.rpc {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+
+	// Run the subcommand.
+	if err := cmd.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the list of subcommands.
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [arguments]\n\ncommands:\n", os.Args[0])
+	for _, name := range commandOrder {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", name, commands[name].description)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n",
+		os.Args[0])
 }
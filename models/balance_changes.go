@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// BalanceChangeIntf ...
+type BalanceChangeIntf interface {
+	GetToken() string
+	GetHolder() string
+	GetBlockHash() string
+	GetBlockNumber() uint64
+	GetDelta() string
+	GetByBlockHash(db *gorm.DB, hash string) ([]BalanceChangeIntf, error)
+	GetAfter(db *gorm.DB, token, holder string, num uint64) ([]BalanceChangeIntf, error)
+	SetBalanceChanges(db *gorm.DB, changes []BalanceChangeIntf) error
+	CopyBalanceChanges(db *gorm.DB, changes []BalanceChangeIntf) error
+}
+
+// BalanceChange is the exported static model interface.
+var BalanceChange balanceChange
+
+// balanceChangesTable inserts changes keyed on their token, holder and block.
+// The transfers of a block don't change, so existing rows are kept.
+var balanceChangesTable = bulkTable{
+	name: "balance_changes",
+	columns: []string{"token", "holder", "block_hash", "block_number", "delta",
+		"created_at", "updated_at"},
+	key: []string{"token", "holder", "block_hash"},
+}
+
+// balanceChange is the net change of the balance of a holder of a token in a
+// block.
+type balanceChange struct {
+	Token       string `gorm:"column:token" json:"token"`
+	Holder      string `gorm:"column:holder" json:"holder"`
+	BlockHash   string `gorm:"column:block_hash" json:"-"`
+	BlockNumber uint64 `gorm:"column:block_number" json:"block_num"`
+	Delta       string `gorm:"column:delta" json:"delta"`
+	CreatedAt   int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt   int64  `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (c *balanceChange) TableName() string {
+	return "balance_changes"
+}
+
+// GetToken returns the address of the token contract.
+func (c *balanceChange) GetToken() string {
+	return c.Token
+}
+
+// GetHolder ...
+func (c *balanceChange) GetHolder() string {
+	return c.Holder
+}
+
+// GetBlockHash ...
+func (c *balanceChange) GetBlockHash() string {
+	return c.BlockHash
+}
+
+// GetBlockNumber ...
+func (c *balanceChange) GetBlockNumber() uint64 {
+	return c.BlockNumber
+}
+
+// GetDelta returns the net change in the smallest unit of the token, as a
+// signed decimal.
+func (c *balanceChange) GetDelta() string {
+	return c.Delta
+}
+
+// NewBalanceChange
+func NewBalanceChange(token, holder, blockHash string, blockNumber uint64,
+	delta string) BalanceChangeIntf {
+	newBalanceChange := balanceChange{
+		Token:       token,
+		Holder:      holder,
+		BlockHash:   blockHash,
+		BlockNumber: blockNumber,
+		Delta:       delta,
+	}
+
+	return &newBalanceChange
+}
+
+// GetByBlockHash returns the balance changes of a block, canonical or
+// orphaned.
+func (c *balanceChange) GetByBlockHash(db *gorm.DB, hash string) ([]BalanceChangeIntf, error) {
+	changes := []*balanceChange{}
+	err := db.Model(c).Where("block_hash = ?", hash).Find(&changes).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into BalanceChangeIntf slice.
+	changeIntfs := []BalanceChangeIntf{}
+	for _, change := range changes {
+		changeIntfs = append(changeIntfs, change)
+	}
+
+	return changeIntfs, nil
+}
+
+// GetAfter returns the balance changes of canonical blocks numbered above
+// num, of the token and of the holder unless empty.
+func (c *balanceChange) GetAfter(
+	db *gorm.DB, token, holder string, num uint64) ([]BalanceChangeIntf, error) {
+	condition, args := "", []interface{}{num}
+	if len(token) > 0 {
+		condition += " AND c.token = ?"
+		args = append(args, token)
+	}
+	if len(holder) > 0 {
+		condition += " AND c.holder = ?"
+		args = append(args, holder)
+	}
+
+	changes := []*balanceChange{}
+	err := db.Raw(`
+		SELECT c.*
+		FROM balance_changes c
+		JOIN blocks b ON b.hash = c.block_hash
+		WHERE b.canonical AND c.block_number > ?`+condition, args...).
+		Scan(&changes).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into BalanceChangeIntf slice.
+	changeIntfs := []BalanceChangeIntf{}
+	for _, change := range changes {
+		changeIntfs = append(changeIntfs, change)
+	}
+
+	return changeIntfs, nil
+}
+
+// SetBalanceChanges inserts balance changes with multi-row statements.
+func (c *balanceChange) SetBalanceChanges(db *gorm.DB, changes []BalanceChangeIntf) error {
+	return upsertRows(db, balanceChangesTable, balanceChangeRows(changes))
+}
+
+// CopyBalanceChanges inserts balance changes with COPY, for PostgreSQL only.
+func (c *balanceChange) CopyBalanceChanges(db *gorm.DB, changes []BalanceChangeIntf) error {
+	return copyRows(db, balanceChangesTable, balanceChangeRows(changes))
+}
+
+// balanceChangeRows returns the balanceChangesTable rows of changes.
+func balanceChangeRows(changes []BalanceChangeIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(changes))
+	for i, c := range changes {
+		rows[i] = []interface{}{c.GetToken(), c.GetHolder(), c.GetBlockHash(),
+			int64(c.GetBlockNumber()), c.GetDelta(), now, now}
+	}
+	return rows
+}
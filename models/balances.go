@@ -0,0 +1,268 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// BalanceIntf ...
+type BalanceIntf interface {
+	GetToken() string
+	GetHolder() string
+	GetBalance() string
+	GetCheckedAt() int64
+	GetCheckedBlock() *uint64
+	GetOnchainBalance() *string
+	GetMismatch() bool
+	SetBalance(amount string)
+	SetChecked(checkedAt int64, checkedBlock uint64, onchainBalance *string, mismatch bool)
+	GetByHolder(db *gorm.DB, holder string) ([]BalanceIntf, error)
+	GetByToken(db *gorm.DB, token string, holders []string) ([]BalanceIntf, error)
+	GetTopHolders(db *gorm.DB, token string, n uint64) ([]BalanceIntf, error)
+	GetDue(db *gorm.DB, n uint64) ([]BalanceIntf, error)
+	ApplyChanges(db *gorm.DB, changes []BalanceChangeIntf, revert bool) error
+	UpdateCheck(db *gorm.DB) error
+}
+
+// Balance is the exported static model interface.
+var Balance balance
+
+// balancesTable upserts balances on their token and holder, replacing the
+// balance. The spot check results are only written by the reconciler.
+var balancesTable = bulkTable{
+	name:    "balances",
+	columns: []string{"token", "holder", "balance", "created_at", "updated_at"},
+	key:     []string{"token", "holder"},
+	update:  []string{"balance", "updated_at"},
+}
+
+// balance is the balance of a holder of a token, with the result of its last
+// spot check.
+type balance struct {
+	Token          string  `gorm:"column:token;primary_key" json:"token"`
+	Holder         string  `gorm:"column:holder;primary_key" json:"holder"`
+	Balance        string  `gorm:"column:balance" json:"balance"`
+	CheckedAt      int64   `gorm:"column:checked_at" json:"checked_at"`
+	CheckedBlock   *uint64 `gorm:"column:checked_block" json:"checked_block"`
+	OnchainBalance *string `gorm:"column:onchain_balance" json:"onchain_balance"`
+	Mismatch       bool    `gorm:"column:mismatch" json:"mismatch"`
+	CreatedAt      int64   `gorm:"column:created_at" json:"-"`
+	UpdatedAt      int64   `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (b *balance) TableName() string {
+	return "balances"
+}
+
+// GetToken returns the address of the token contract.
+func (b *balance) GetToken() string {
+	return b.Token
+}
+
+// GetHolder ...
+func (b *balance) GetHolder() string {
+	return b.Holder
+}
+
+// GetBalance returns the balance in the smallest unit of the token, as a
+// signed decimal. It's negative if transfers to the holder weren't indexed.
+func (b *balance) GetBalance() string {
+	return b.Balance
+}
+
+// GetCheckedAt returns the time the balance was last spot checked, in
+// milliseconds, or 0 if never.
+func (b *balance) GetCheckedAt() int64 {
+	return b.CheckedAt
+}
+
+// GetCheckedBlock returns the block number the balance was last spot checked
+// at, or nil if never.
+func (b *balance) GetCheckedBlock() *uint64 {
+	return b.CheckedBlock
+}
+
+// GetOnchainBalance returns the balanceOf the holder when last spot checked,
+// or nil if unknown.
+func (b *balance) GetOnchainBalance() *string {
+	return b.OnchainBalance
+}
+
+// GetMismatch returns whether the balance didn't match balanceOf when last
+// spot checked.
+func (b *balance) GetMismatch() bool {
+	return b.Mismatch
+}
+
+// SetBalance ...
+func (b *balance) SetBalance(amount string) {
+	b.Balance = amount
+}
+
+// SetChecked sets the result of a spot check.
+func (b *balance) SetChecked(checkedAt int64, checkedBlock uint64,
+	onchainBalance *string, mismatch bool) {
+	b.CheckedAt = checkedAt
+	b.CheckedBlock = &checkedBlock
+	b.OnchainBalance = onchainBalance
+	b.Mismatch = mismatch
+}
+
+// NewBalance returns the balance of a holder of a token, due for a spot
+// check.
+func NewBalance(token, holder, amount string) BalanceIntf {
+	newBalance := balance{
+		Token:   token,
+		Holder:  holder,
+		Balance: amount,
+	}
+
+	return &newBalance
+}
+
+// GetByHolder returns the balances of a holder of any token, including zero
+// ones.
+func (b *balance) GetByHolder(db *gorm.DB, holder string) ([]BalanceIntf, error) {
+	balances := []*balance{}
+	err := db.Model(b).Where("holder = ?", holder).Order("token asc").Find(&balances).Error
+	return toBalanceIntfs(balances, err)
+}
+
+// GetByToken returns the balances of the given holders of a token, read in
+// chunks of holders.
+func (b *balance) GetByToken(db *gorm.DB, token string, holders []string) ([]BalanceIntf, error) {
+	balances := []*balance{}
+	for start := 0; start < len(holders); start += maxBulkParams {
+		end := start + maxBulkParams
+		if end > len(holders) {
+			end = len(holders)
+		}
+		chunk := []*balance{}
+		err := db.Model(b).
+			Where("token = ? AND holder IN (?)", token, holders[start:end]).
+			Find(&chunk).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		balances = append(balances, chunk...)
+	}
+	return toBalanceIntfs(balances, nil)
+}
+
+// GetTopHolders returns the n balances of a token with the highest positive
+// balances, the highest first. Balances are ordered as decimal strings, by
+// length first.
+func (b *balance) GetTopHolders(db *gorm.DB, token string, n uint64) ([]BalanceIntf, error) {
+	balances := []*balance{}
+	err := db.Model(b).
+		Where("token = ? AND balance <> '0' AND balance NOT LIKE '-%'", token).
+		Order("LENGTH(balance) desc, balance desc, holder asc").
+		Limit(n).
+		Find(&balances).Error
+	return toBalanceIntfs(balances, err)
+}
+
+// GetDue returns up to n balances the least recently spot checked first.
+func (b *balance) GetDue(db *gorm.DB, n uint64) ([]BalanceIntf, error) {
+	balances := []*balance{}
+	err := db.Model(b).Order("checked_at asc").Limit(n).Find(&balances).Error
+	return toBalanceIntfs(balances, err)
+}
+
+// toBalanceIntfs organizes balances into a BalanceIntf slice, with no error
+// if none was found.
+func toBalanceIntfs(balances []*balance, err error) ([]BalanceIntf, error) {
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into BalanceIntf slice.
+	balanceIntfs := []BalanceIntf{}
+	for _, balance := range balances {
+		balanceIntfs = append(balanceIntfs, balance)
+	}
+
+	return balanceIntfs, nil
+}
+
+// ApplyChanges adds balance changes to the balances of their holders, or
+// subtracts them if reverting. The balances are read and written back in
+// bulk, so concurrent transactions applying changes must be serialized by
+// the caller.
+func (b *balance) ApplyChanges(db *gorm.DB, changes []BalanceChangeIntf, revert bool) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// read the balances of the holders in chunks of (token, holder) pairs
+	type key struct{ token, holder string }
+	balances := map[key]*big.Int{}
+	perStatement := maxBulkParams / 2
+	for start := 0; start < len(changes); start += perStatement {
+		end := start + perStatement
+		if end > len(changes) {
+			end = len(changes)
+		}
+		conditions := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*2)
+		for _, change := range changes[start:end] {
+			conditions = append(conditions, "(token = ? AND holder = ?)")
+			args = append(args, change.GetToken(), change.GetHolder())
+		}
+		stored := []*balance{}
+		err := db.Model(b).Where(strings.Join(conditions, " OR "), args...).Find(&stored).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		for _, s := range stored {
+			amount, ok := new(big.Int).SetString(s.Balance, 10)
+			if !ok {
+				return fmt.Errorf("invalid balance %q of %s in %s", s.Balance, s.Holder, s.Token)
+			}
+			balances[key{s.Token, s.Holder}] = amount
+		}
+	}
+
+	// add up the changes, then write the balances back
+	order := []key{}
+	for _, change := range changes {
+		delta, ok := new(big.Int).SetString(change.GetDelta(), 10)
+		if !ok {
+			return fmt.Errorf("invalid balance change %q of %s in %s",
+				change.GetDelta(), change.GetHolder(), change.GetToken())
+		}
+		if revert {
+			delta.Neg(delta)
+		}
+		k := key{change.GetToken(), change.GetHolder()}
+		amount, ok := balances[k]
+		if !ok {
+			amount = new(big.Int)
+			balances[k] = amount
+		}
+		amount.Add(amount, delta)
+		order = append(order, k)
+	}
+	now := nowMillis()
+	rows := make([][]interface{}, len(order))
+	for i, k := range order {
+		rows[i] = []interface{}{k.token, k.holder, balances[k].String(), now, now}
+	}
+	return upsertRows(db, balancesTable, rows)
+}
+
+// UpdateCheck writes the result of the last spot check of the balance.
+func (b *balance) UpdateCheck(db *gorm.DB) error {
+	return db.Model(b).UpdateColumns(map[string]interface{}{
+		"checked_at":      b.CheckedAt,
+		"checked_block":   b.CheckedBlock,
+		"onchain_balance": b.OnchainBalance,
+		"mismatch":        b.Mismatch,
+		"updated_at":      nowMillis(),
+	}).Error
+}
@@ -14,33 +14,92 @@ type BlockIntf interface {
 	GetHash() string
 	GetTime() uint64
 	GetParent() string
-	GetStable() bool
-	SetStable(stable bool)
+	GetStatus() BlockStatus
+	SetStatus(status BlockStatus)
+	GetCanonical() bool
+	SetCanonical(canonical bool)
 	GetCreatedAt() int64
 	GetUpdatedAt() int64
-	GetBlocks(db *gorm.DB, num uint64) ([]BlockIntf, error)
+	GetBlocks(db *gorm.DB, num, maxNumber uint64, minStatus BlockStatus) ([]BlockIntf, error)
 	GetByNumber(db *gorm.DB, num uint64) (BlockIntf, error)
+	GetByHash(db *gorm.DB, hash string) (BlockIntf, error)
+	GetRange(db *gorm.DB, from, to uint64) ([]BlockIntf, error)
+	GetHighest(db *gorm.DB, minStatus BlockStatus) (BlockIntf, error)
+	GetLowest(db *gorm.DB) (BlockIntf, error)
+	GetGaps(db *gorm.DB, limit uint64) ([]BlockGap, error)
+	GetContiguousEnd(db *gorm.DB, from uint64) (uint64, error)
 	SetBlock(db *gorm.DB) error
-	UpdateBlockStable(db *gorm.DB, stable bool) error
+	UpdateBlockStatus(db *gorm.DB, status BlockStatus) error
+	UpdateBlockCanonical(db *gorm.DB, canonical bool) error
+	GetLessFinal(db *gorm.DB, n, maxNumber uint64, status BlockStatus) ([]BlockIntf, error)
 	DeleteBlock(db *gorm.DB) error
 }
 
+// BlockGap is a range of missing block numbers between indexed blocks.
+type BlockGap struct {
+	From uint64 `gorm:"column:gap_from" json:"from"`
+	To   uint64 `gorm:"column:gap_to" json:"to"`
+}
+
+// BlockStatus is the finality status of a block.
+type BlockStatus string
+
+// Block statuses, from the least to the most final.
+const (
+	StatusLatest    BlockStatus = "latest"
+	StatusSafe      BlockStatus = "safe"
+	StatusFinalized BlockStatus = "finalized"
+)
+
+// blockStatuses are the block statuses, from the least to the most final.
+var blockStatuses = []BlockStatus{StatusLatest, StatusSafe, StatusFinalized}
+
+// ParseBlockStatus returns the block status of the given name.
+func ParseBlockStatus(name string) (BlockStatus, bool) {
+	for _, status := range blockStatuses {
+		if string(status) == name {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// AtLeast returns whether the status is at least as final as the other.
+func (s BlockStatus) AtLeast(other BlockStatus) bool {
+	return s.rank() >= other.rank()
+}
+
+// StatusesAtLeast returns the statuses at least as final as the given one.
+func StatusesAtLeast(status BlockStatus) []BlockStatus {
+	return blockStatuses[status.rank():]
+}
+
+// rank returns the position of the status in blockStatuses, unknown
+// statuses ranking as latest.
+func (s BlockStatus) rank() int {
+	for i, status := range blockStatuses {
+		if status == s {
+			return i
+		}
+	}
+	return 0
+}
+
 // Block is the exported static model interface.
 var Block block
 
-// block ...
+// block is a block of the chain. Blocks replaced by a reorg are kept as
+// orphans, which aren't canonical, so blocks are keyed by hash rather than
+// number.
 type block struct {
-	Number    uint64 `gorm:"column:number;primary_key" json:"block_num"`
-	Hash      string `gorm:"column:hash" json:"block_hash"`
-	Time      uint64 `gorm:"column:time" json:"block_time"`
-	Parent    string `gorm:"column:parent" json:"parent_hash"`
-	Stable    bool   `gorm:"column:stable;defalt:false" json:"stable"`
-	CreatedAt int64  `gorm:"column:created_at;default:extract(epoch from now())*1000" json:"-"`
-	UpdatedAt int64  `gorm:"column:updated_at;default:extract(epoch from now())*1000" json:"-"`
-}
-
-func init() {
-	registerModelForAutoMigration(&block{})
+	Number    uint64      `gorm:"column:number" json:"block_num"`
+	Hash      string      `gorm:"column:hash;primary_key" json:"block_hash"`
+	Time      uint64      `gorm:"column:time" json:"block_time"`
+	Parent    string      `gorm:"column:parent" json:"parent_hash"`
+	Status    BlockStatus `gorm:"column:status" json:"status"`
+	Canonical bool        `gorm:"column:canonical" json:"canonical"`
+	CreatedAt int64       `gorm:"column:created_at" json:"-"`
+	UpdatedAt int64       `gorm:"column:updated_at" json:"-"`
 }
 
 // TableName is used by GORM to choose which table to use.
@@ -48,18 +107,16 @@ func (b *block) TableName() string {
 	return "blocks"
 }
 
-// createIndexes ...
-func (b *block) createIndexes(db *gorm.DB) error {
-	return nil
-}
-
-// createUniqueIndexes ...
-func (b *block) createUniqueIndexes(db *gorm.DB) error {
+// BeforeCreate is called by GORM to set the creation and update times.
+func (b *block) BeforeCreate() error {
+	b.CreatedAt = nowMillis()
+	b.UpdatedAt = b.CreatedAt
 	return nil
 }
 
-// createForeignKeys ...
-func (b *block) createForeignKeys(db *gorm.DB) error {
+// BeforeUpdate is called by GORM to set the update time.
+func (b *block) BeforeUpdate() error {
+	b.UpdatedAt = nowMillis()
 	return nil
 }
 
@@ -83,14 +140,24 @@ func (b *block) GetParent() string {
 	return b.Parent
 }
 
-// GetStable ...
-func (b *block) GetStable() bool {
-	return b.Stable
+// GetStatus ...
+func (b *block) GetStatus() BlockStatus {
+	return b.Status
 }
 
-// SetStable ...
-func (b *block) SetStable(stable bool) {
-	b.Stable = stable
+// SetStatus ...
+func (b *block) SetStatus(status BlockStatus) {
+	b.Status = status
+}
+
+// GetCanonical ...
+func (b *block) GetCanonical() bool {
+	return b.Canonical
+}
+
+// SetCanonical ...
+func (b *block) SetCanonical(canonical bool) {
+	b.Canonical = canonical
 }
 
 // GetCreatedAt ...
@@ -106,20 +173,29 @@ func (b *block) GetUpdatedAt() int64 {
 // NewBlock
 func NewBlock(ethBlock *types.Block) BlockIntf {
 	newBlock := block{
-		Number: ethBlock.Header().Number.Uint64(),
-		Hash:   ethBlock.Hash().String(),
-		Time:   ethBlock.Time(),
-		Parent: ethBlock.Header().ParentHash.String(),
+		Number:    ethBlock.Header().Number.Uint64(),
+		Hash:      ethBlock.Hash().String(),
+		Time:      ethBlock.Time(),
+		Parent:    ethBlock.Header().ParentHash.String(),
+		Status:    StatusLatest,
+		Canonical: true,
 	}
 
 	return &newBlock
 }
 
-// GetBlocks ...
-func (b *block) GetBlocks(db *gorm.DB, n uint64) ([]BlockIntf, error) {
+// GetBlocks returns the latest n canonical blocks numbered up to maxNumber,
+// at least as final as minStatus.
+func (b *block) GetBlocks(db *gorm.DB, n, maxNumber uint64,
+	minStatus BlockStatus) ([]BlockIntf, error) {
+	query := db.Model(b).Where("canonical AND number <= ?", maxNumber)
+	if minStatus.AtLeast(StatusSafe) {
+		query = query.Where("status IN (?)", StatusesAtLeast(minStatus))
+	}
+
 	// Get latest n blocks
 	blocks := []*block{}
-	err := db.Model(b).
+	err := query.
 		Order("number desc").
 		Limit(n).
 		Find(&blocks).Error
@@ -136,11 +212,11 @@ func (b *block) GetBlocks(db *gorm.DB, n uint64) ([]BlockIntf, error) {
 	return blockIntfs, nil
 }
 
-// GetByNumber ...
+// GetByNumber returns the canonical block of the given number.
 func (b *block) GetByNumber(db *gorm.DB, num uint64) (BlockIntf, error) {
 	// Get block based on given number
 	block := block{}
-	err := db.Model(b).Where("number = ?", num).First(&block).Error
+	err := db.Model(b).Where("canonical AND number = ?", num).First(&block).Error
 	if err != nil {
 		return nil, err
 	}
@@ -148,17 +224,157 @@ func (b *block) GetByNumber(db *gorm.DB, num uint64) (BlockIntf, error) {
 	return &block, nil
 }
 
+// GetByHash returns the block of the given hash, canonical or orphaned.
+func (b *block) GetByHash(db *gorm.DB, hash string) (BlockIntf, error) {
+	block := block{}
+	err := db.Model(b).Where("hash = ?", hash).First(&block).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// GetRange returns the canonical blocks in the range [from, to] in ascending
+// order.
+func (b *block) GetRange(db *gorm.DB, from, to uint64) ([]BlockIntf, error) {
+	blocks := []*block{}
+	err := db.Model(b).
+		Where("canonical AND number BETWEEN ? AND ?", from, to).
+		Order("number asc").
+		Find(&blocks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Organize into BlockIntf slice.
+	blockIntfs := []BlockIntf{}
+	for _, block := range blocks {
+		blockIntfs = append(blockIntfs, block)
+	}
+
+	return blockIntfs, nil
+}
+
+// GetHighest returns the canonical block with the highest number.
+func (b *block) GetHighest(db *gorm.DB, minStatus BlockStatus) (BlockIntf, error) {
+	query := db.Model(b).Where("canonical")
+	if minStatus.AtLeast(StatusSafe) {
+		query = query.Where("status IN (?)", StatusesAtLeast(minStatus))
+	}
+
+	block := block{}
+	err := query.Order("number desc").First(&block).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// GetLowest returns the canonical block with the lowest number.
+func (b *block) GetLowest(db *gorm.DB) (BlockIntf, error) {
+	block := block{}
+	err := db.Model(b).Where("canonical").Order("number asc").First(&block).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// GetGaps returns up to limit ranges of missing block numbers between the
+// lowest and highest indexed block, the most recent first.
+func (b *block) GetGaps(db *gorm.DB, limit uint64) ([]BlockGap, error) {
+	gaps := []BlockGap{}
+	err := db.Raw(`
+		SELECT number + 1 AS gap_from, next_number - 1 AS gap_to
+		FROM (
+			SELECT number, LEAD(number) OVER (ORDER BY number) AS next_number
+			FROM blocks
+			WHERE canonical
+		) AS t
+		WHERE next_number - number > 1
+		ORDER BY number DESC
+		LIMIT ?`, limit).Scan(&gaps).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return gaps, nil
+}
+
+// GetContiguousEnd returns the highest block number such that every block
+// from the given number up to it is indexed. The block of the given number
+// must be indexed.
+func (b *block) GetContiguousEnd(db *gorm.DB, from uint64) (uint64, error) {
+	if _, err := b.GetByNumber(db, from); err != nil {
+		return 0, err
+	}
+
+	var end struct {
+		Number uint64 `gorm:"column:number"`
+	}
+	err := db.Raw(`
+		SELECT MIN(b.number) AS number
+		FROM blocks b
+		WHERE b.canonical AND b.number >= ?
+		  AND NOT EXISTS (
+		    SELECT 1 FROM blocks n WHERE n.canonical AND n.number = b.number + 1)`,
+		from).Scan(&end).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return end.Number, nil
+}
+
 // SetBlocks ...
 func (b *block) SetBlock(db *gorm.DB) error {
-	return db.Where("number = ?", b.Number).FirstOrCreate(b).Error
+	return db.Where("hash = ?", b.Hash).FirstOrCreate(b).Error
 }
 
-// UpdateBlocks ...
-func (b *block) UpdateBlockStable(db *gorm.DB, stable bool) error {
-	b.Stable = stable
+// UpdateBlockStatus ...
+func (b *block) UpdateBlockStatus(db *gorm.DB, status BlockStatus) error {
+	b.Status = status
 	return db.Save(b).Error
 }
 
+// UpdateBlockCanonical flags the block canonical or orphaned.
+func (b *block) UpdateBlockCanonical(db *gorm.DB, canonical bool) error {
+	b.Canonical = canonical
+	return db.Model(b).
+		UpdateColumns(map[string]interface{}{"canonical": canonical, "updated_at": nowMillis()}).Error
+}
+
+// GetLessFinal returns the lowest n canonical blocks numbered up to
+// maxNumber which are less final than the given status.
+func (b *block) GetLessFinal(db *gorm.DB, n, maxNumber uint64,
+	status BlockStatus) ([]BlockIntf, error) {
+	lower := blockStatuses[:status.rank()]
+	if len(lower) == 0 {
+		return []BlockIntf{}, nil
+	}
+
+	blocks := []*block{}
+	err := db.Model(b).
+		Where("canonical AND number <= ? AND status IN (?)", maxNumber, lower).
+		Order("number asc").
+		Limit(n).
+		Find(&blocks).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into BlockIntf slice.
+	blockIntfs := []BlockIntf{}
+	for _, block := range blocks {
+		blockIntfs = append(blockIntfs, block)
+	}
+
+	return blockIntfs, nil
+}
+
 // DeleteBlocks ...
 func (b *block) DeleteBlock(db *gorm.DB) error {
 	return db.Delete(b).Error
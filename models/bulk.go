@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// maxBulkParams bounds the bind parameters of a multi-row statement, below
+// the limits of PostgreSQL (65535) and SQLite (32766).
+const maxBulkParams = 30000
+
+// bulkTable describes how rows are bulk written to a table.
+type bulkTable struct {
+	// The name of the table.
+	name string
+
+	// The columns written, in the order of the row values.
+	columns []string
+
+	// The natural key columns the rows are upserted on.
+	key []string
+
+	// The columns updated when the key already exists. If empty, existing
+	// rows are kept.
+	update []string
+}
+
+// onConflict returns the ON CONFLICT clause upserting on the natural key.
+func (t bulkTable) onConflict() string {
+	clause := fmt.Sprintf("ON CONFLICT (%s) DO ", strings.Join(t.key, ", "))
+	if len(t.update) == 0 {
+		return clause + "NOTHING"
+	}
+	sets := make([]string, len(t.update))
+	for i, column := range t.update {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", column, column)
+	}
+	return clause + "UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// dedupe drops all but the last row of each natural key, as a single
+// statement can't upsert the same key twice.
+func (t bulkTable) dedupe(rows [][]interface{}) [][]interface{} {
+	positions := make([]int, 0, len(t.key))
+	for _, key := range t.key {
+		for i, column := range t.columns {
+			if column == key {
+				positions = append(positions, i)
+			}
+		}
+	}
+
+	last := make(map[string]int, len(rows))
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		parts := make([]string, len(positions))
+		for j, pos := range positions {
+			parts[j] = fmt.Sprint(row[pos])
+		}
+		keys[i] = strings.Join(parts, "\x00")
+		last[keys[i]] = i
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
+
+	unique := make([][]interface{}, 0, len(last))
+	for i, row := range rows {
+		if last[keys[i]] == i {
+			unique = append(unique, row)
+		}
+	}
+	return unique
+}
+
+// upsertRows writes rows with multi-row INSERT ... ON CONFLICT statements.
+func upsertRows(db *gorm.DB, t bulkTable, rows [][]interface{}) error {
+	rows = t.dedupe(rows)
+	placeholder := "(" + strings.Repeat("?, ", len(t.columns)-1) + "?)"
+	perStatement := maxBulkParams / len(t.columns)
+
+	for start := 0; start < len(rows); start += perStatement {
+		end := start + perStatement
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		// build the statement for the chunk of rows
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(t.columns))
+		for _, row := range rows[start:end] {
+			values = append(values, placeholder)
+			args = append(args, row...)
+		}
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s %s",
+			t.name, strings.Join(t.columns, ", "), strings.Join(values, ", "),
+			t.onConflict())
+		if err := db.Exec(query, args...).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyRows bulk loads rows into a temporary table with COPY FROM STDIN, then
+// upserts them into the table with a single INSERT ... SELECT. The database
+// must be PostgreSQL.
+func copyRows(db *gorm.DB, t bulkTable, rows [][]interface{}) error {
+	rows = t.dedupe(rows)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// the temporary table only exists in the session of a transaction
+	switch conn := db.CommonDB().(type) {
+	case *sql.Tx:
+		return copyRowsTx(conn, t, rows)
+	case *sql.DB:
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		if err := copyRowsTx(tx, t, rows); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	default:
+		return fmt.Errorf("unsupported database handle %T", conn)
+	}
+}
+
+// copyRowsTx copies rows within the transaction.
+func copyRowsTx(tx *sql.Tx, t bulkTable, rows [][]interface{}) error {
+	staging := "staging_" + t.name
+	if _, err := tx.Exec(fmt.Sprintf(
+		"CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP",
+		staging, t.name)); err != nil {
+		return err
+	}
+
+	// stream the rows to the staging table
+	stmt, err := tx.Prepare(pq.CopyIn(staging, t.columns...))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	// upsert from the staging table, dropping it so it can be reused within
+	// the transaction
+	columns := strings.Join(t.columns, ", ")
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s %s",
+		t.name, columns, columns, staging, t.onConflict())); err != nil {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("DROP TABLE %s", staging))
+	return err
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// ContractABIIntf ...
+type ContractABIIntf interface {
+	GetAddress() string
+	GetABI() string
+	GetUpdatedAt() int64
+	GetByAddress(db *gorm.DB, address string) (ContractABIIntf, error)
+	SetContractABI(db *gorm.DB) error
+}
+
+// ContractABI is the exported static model interface.
+var ContractABI contractABI
+
+// contractABIsTable upserts ABIs on their contract address, replacing the
+// ABI registered before.
+var contractABIsTable = bulkTable{
+	name:    "contract_abis",
+	columns: []string{"address", "abi", "created_at", "updated_at"},
+	key:     []string{"address"},
+	update:  []string{"abi", "updated_at"},
+}
+
+// contractABI is the ABI registered for a contract, as JSON.
+type contractABI struct {
+	Address   string `gorm:"column:address;primary_key" json:"address"`
+	ABI       string `gorm:"column:abi" json:"abi"`
+	CreatedAt int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt int64  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (c *contractABI) TableName() string {
+	return "contract_abis"
+}
+
+// GetAddress ...
+func (c *contractABI) GetAddress() string {
+	return c.Address
+}
+
+// GetABI returns the ABI as JSON.
+func (c *contractABI) GetABI() string {
+	return c.ABI
+}
+
+// GetUpdatedAt ...
+func (c *contractABI) GetUpdatedAt() int64 {
+	return c.UpdatedAt
+}
+
+// NewContractABI returns the ABI of a contract, given as JSON.
+func NewContractABI(address, abi string) ContractABIIntf {
+	newContractABI := contractABI{
+		Address: address,
+		ABI:     abi,
+	}
+
+	return &newContractABI
+}
+
+// GetByAddress ...
+func (c *contractABI) GetByAddress(db *gorm.DB, address string) (ContractABIIntf, error) {
+	abi := contractABI{}
+	err := db.Model(c).Where("address = ?", address).First(&abi).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &abi, nil
+}
+
+// SetContractABI registers the ABI, replacing the one registered before.
+func (c *contractABI) SetContractABI(db *gorm.DB) error {
+	now := nowMillis()
+	c.UpdatedAt = now
+	return upsertRows(db, contractABIsTable, [][]interface{}{{c.Address, c.ABI, now, now}})
+}
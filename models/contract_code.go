@@ -0,0 +1,101 @@
+package models
+
+import (
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jinzhu/gorm"
+)
+
+// ContractCodeIntf ...
+type ContractCodeIntf interface {
+	GetCodeHash() string
+	GetBytecode() []byte
+	GetSize() uint64
+	GetByHash(db *gorm.DB, codeHash string) (ContractCodeIntf, error)
+	SetContractCode(db *gorm.DB, code []ContractCodeIntf) error
+	CopyContractCode(db *gorm.DB, code []ContractCodeIntf) error
+}
+
+// ContractCode is the exported static model interface.
+var ContractCode contractCode
+
+// contractCodeTable inserts bytecode keyed on its hash, so the bytecode shared
+// by many contracts is only stored once.
+var contractCodeTable = bulkTable{
+	name:    "contract_code",
+	columns: []string{"code_hash", "bytecode", "size", "created_at", "updated_at"},
+	key:     []string{"code_hash"},
+}
+
+// contractCode is the deployed bytecode of contracts.
+type contractCode struct {
+	CodeHash  string        `gorm:"column:code_hash;primary_key" json:"code_hash"`
+	Bytecode  hexutil.Bytes `gorm:"column:bytecode" json:"bytecode"`
+	Size      uint64        `gorm:"column:size" json:"size"`
+	CreatedAt int64         `gorm:"column:created_at" json:"-"`
+	UpdatedAt int64         `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (c *contractCode) TableName() string {
+	return "contract_code"
+}
+
+// GetCodeHash ...
+func (c *contractCode) GetCodeHash() string {
+	return c.CodeHash
+}
+
+// GetBytecode ...
+func (c *contractCode) GetBytecode() []byte {
+	return c.Bytecode
+}
+
+// GetSize returns the size of the bytecode in bytes.
+func (c *contractCode) GetSize() uint64 {
+	return c.Size
+}
+
+// NewContractCode returns the bytecode with its hash.
+func NewContractCode(bytecode []byte) ContractCodeIntf {
+	newContractCode := contractCode{
+		CodeHash: crypto.Keccak256Hash(bytecode).String(),
+		Bytecode: bytecode,
+		Size:     uint64(len(bytecode)),
+	}
+
+	return &newContractCode
+}
+
+// GetByHash ...
+func (c *contractCode) GetByHash(db *gorm.DB, codeHash string) (ContractCodeIntf, error) {
+	code := contractCode{}
+	err := db.Model(c).Where("code_hash = ?", codeHash).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// SetContractCode inserts bytecode with multi-row statements, keeping the
+// bytecode already stored.
+func (c *contractCode) SetContractCode(db *gorm.DB, code []ContractCodeIntf) error {
+	return upsertRows(db, contractCodeTable, contractCodeRows(code))
+}
+
+// CopyContractCode inserts bytecode with COPY, for PostgreSQL only.
+func (c *contractCode) CopyContractCode(db *gorm.DB, code []ContractCodeIntf) error {
+	return copyRows(db, contractCodeTable, contractCodeRows(code))
+}
+
+// contractCodeRows returns the contractCodeTable rows of bytecode.
+func contractCodeRows(code []ContractCodeIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(code))
+	for i, c := range code {
+		rows[i] = []interface{}{c.GetCodeHash(), c.GetBytecode(),
+			int64(c.GetSize()), now, now}
+	}
+	return rows
+}
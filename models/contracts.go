@@ -0,0 +1,164 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// ContractStandard is the token standard a contract implements, as far as the
+// indexer can tell.
+type ContractStandard string
+
+// Contract standards.
+const (
+	StandardNone    ContractStandard = ""
+	StandardERC20   ContractStandard = "erc20"
+	StandardERC721  ContractStandard = "erc721"
+	StandardERC1155 ContractStandard = "erc1155"
+)
+
+// ContractIntf ...
+type ContractIntf interface {
+	GetAddress() string
+	GetBlockHash() string
+	GetBlockNumber() uint64
+	GetCreationTxHash() string
+	GetCreator() string
+	GetCodeHash() string
+	GetStandard() ContractStandard
+	GetSupportsERC165() bool
+	GetByAddress(db *gorm.DB, address string) (ContractIntf, error)
+	SetContracts(db *gorm.DB, contracts []ContractIntf) error
+	CopyContracts(db *gorm.DB, contracts []ContractIntf) error
+}
+
+// Contract is the exported static model interface.
+var Contract contract
+
+// contractsTable inserts contracts keyed on their address and block. A
+// deployment in a block doesn't change, so existing rows are kept.
+var contractsTable = bulkTable{
+	name: "contracts",
+	columns: []string{"address", "block_hash", "block_number",
+		"creation_tx_hash", "creator", "code_hash", "standard",
+		"supports_erc165", "created_at", "updated_at"},
+	key: []string{"address", "block_hash"},
+}
+
+// contract is a contract deployed by a transaction.
+type contract struct {
+	Address        string           `gorm:"column:address" json:"address"`
+	BlockHash      string           `gorm:"column:block_hash" json:"-"`
+	BlockNumber    uint64           `gorm:"column:block_number" json:"block_num"`
+	CreationTxHash string           `gorm:"column:creation_tx_hash" json:"creation_tx_hash"`
+	Creator        string           `gorm:"column:creator" json:"creator"`
+	CodeHash       string           `gorm:"column:code_hash" json:"code_hash"`
+	Standard       ContractStandard `gorm:"column:standard" json:"standard,omitempty"`
+	SupportsERC165 bool             `gorm:"column:supports_erc165" json:"supports_erc165"`
+	CreatedAt      int64            `gorm:"column:created_at" json:"-"`
+	UpdatedAt      int64            `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (c *contract) TableName() string {
+	return "contracts"
+}
+
+// GetAddress ...
+func (c *contract) GetAddress() string {
+	return c.Address
+}
+
+// GetBlockHash ...
+func (c *contract) GetBlockHash() string {
+	return c.BlockHash
+}
+
+// GetBlockNumber ...
+func (c *contract) GetBlockNumber() uint64 {
+	return c.BlockNumber
+}
+
+// GetCreationTxHash ...
+func (c *contract) GetCreationTxHash() string {
+	return c.CreationTxHash
+}
+
+// GetCreator returns the address which deployed the contract: the sender of
+// the transaction, or the contract which ran CREATE or CREATE2.
+func (c *contract) GetCreator() string {
+	return c.Creator
+}
+
+// GetCodeHash ...
+func (c *contract) GetCodeHash() string {
+	return c.CodeHash
+}
+
+// GetStandard ...
+func (c *contract) GetStandard() ContractStandard {
+	return c.Standard
+}
+
+// GetSupportsERC165 ...
+func (c *contract) GetSupportsERC165() bool {
+	return c.SupportsERC165
+}
+
+// NewContract
+func NewContract(address, blockHash string, blockNumber uint64,
+	creationTxHash, creator, codeHash string, standard ContractStandard,
+	supportsERC165 bool) ContractIntf {
+	newContract := contract{
+		Address:        address,
+		BlockHash:      blockHash,
+		BlockNumber:    blockNumber,
+		CreationTxHash: creationTxHash,
+		Creator:        creator,
+		CodeHash:       codeHash,
+		Standard:       standard,
+		SupportsERC165: supportsERC165,
+	}
+
+	return &newContract
+}
+
+// GetByAddress returns the latest deployment of a contract by a canonical
+// transaction.
+func (c *contract) GetByAddress(db *gorm.DB, address string) (ContractIntf, error) {
+	contract := contract{}
+	err := db.Raw(`
+		SELECT c.*
+		FROM contracts c
+		JOIN transactions t ON t.tx_hash = c.creation_tx_hash AND t.block_hash = c.block_hash
+		WHERE t.canonical AND c.address = ?
+		ORDER BY c.block_number DESC
+		LIMIT 1`, address).Scan(&contract).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &contract, nil
+}
+
+// SetContracts inserts contracts with multi-row statements.
+func (c *contract) SetContracts(db *gorm.DB, contracts []ContractIntf) error {
+	return upsertRows(db, contractsTable, contractRows(contracts))
+}
+
+// CopyContracts inserts contracts with COPY, for PostgreSQL only.
+func (c *contract) CopyContracts(db *gorm.DB, contracts []ContractIntf) error {
+	return copyRows(db, contractsTable, contractRows(contracts))
+}
+
+// contractRows returns the contractsTable rows of contracts.
+func contractRows(contracts []ContractIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(contracts))
+	for i, c := range contracts {
+		rows[i] = []interface{}{c.GetAddress(), c.GetBlockHash(),
+			int64(c.GetBlockNumber()), c.GetCreationTxHash(), c.GetCreator(),
+			c.GetCodeHash(), string(c.GetStandard()), c.GetSupportsERC165(),
+			now, now}
+	}
+	return rows
+}
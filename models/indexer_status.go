@@ -0,0 +1,134 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// IndexerStatusIntf ...
+type IndexerStatusIntf interface {
+	GetChainHead() uint64
+	GetChainHeadSeenAt() *int64
+	GetActiveEndpoint() string
+	GetFinalityMode() string
+	GetSafe() *uint64
+	GetFinalized() *uint64
+	Get(db *gorm.DB) (IndexerStatusIntf, error)
+	SetChainHead(db *gorm.DB, num uint64) error
+	SetActiveEndpoint(db *gorm.DB, endpoint string) error
+	SetFinality(db *gorm.DB, mode string, safe, finalized *uint64) error
+}
+
+// IndexerStatus is the exported static model interface.
+var IndexerStatus indexerStatus
+
+// indexerStatus is the state of the indexer: the chain head seen from the
+// active RPC endpoint and the finality heights tracked. The table has a single
+// row, written by the indexer and read by the API.
+type indexerStatus struct {
+	ID              uint64  `gorm:"column:id;primary_key" json:"-"`
+	ChainHead       uint64  `gorm:"column:chain_head" json:"chain_head"`
+	ChainHeadSeenAt *int64  `gorm:"column:chain_head_seen_at" json:"chain_head_seen_at"`
+	ActiveEndpoint  string  `gorm:"column:active_endpoint" json:"active_endpoint"`
+	FinalityMode    string  `gorm:"column:finality_mode" json:"finality_mode"`
+	Safe            *uint64 `gorm:"column:safe" json:"safe"`
+	Finalized       *uint64 `gorm:"column:finalized" json:"finalized"`
+	CreatedAt       int64   `gorm:"column:created_at" json:"-"`
+	UpdatedAt       int64   `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (s *indexerStatus) TableName() string {
+	return "indexer_status"
+}
+
+// GetChainHead ...
+func (s *indexerStatus) GetChainHead() uint64 {
+	return s.ChainHead
+}
+
+// GetChainHeadSeenAt returns when the chain head was last polled, or nil if
+// it never was.
+func (s *indexerStatus) GetChainHeadSeenAt() *int64 {
+	return s.ChainHeadSeenAt
+}
+
+// GetActiveEndpoint ...
+func (s *indexerStatus) GetActiveEndpoint() string {
+	return s.ActiveEndpoint
+}
+
+// GetFinalityMode ...
+func (s *indexerStatus) GetFinalityMode() string {
+	return s.FinalityMode
+}
+
+// GetSafe ...
+func (s *indexerStatus) GetSafe() *uint64 {
+	return s.Safe
+}
+
+// GetFinalized ...
+func (s *indexerStatus) GetFinalized() *uint64 {
+	return s.Finalized
+}
+
+// Get ...
+func (s *indexerStatus) Get(db *gorm.DB) (IndexerStatusIntf, error) {
+	status := indexerStatus{}
+	err := db.Model(s).Where("id = 1").First(&status).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// SetChainHead records the chain head polled now.
+func (s *indexerStatus) SetChainHead(db *gorm.DB, num uint64) error {
+	now := nowMillis()
+	return db.Exec(`
+		INSERT INTO indexer_status (id, chain_head, chain_head_seen_at, created_at, updated_at)
+		VALUES (1, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE
+		SET chain_head = EXCLUDED.chain_head,
+			chain_head_seen_at = EXCLUDED.chain_head_seen_at,
+			updated_at = EXCLUDED.updated_at`,
+		num, now, now, now).Error
+}
+
+// SetActiveEndpoint records the RPC endpoint in use.
+func (s *indexerStatus) SetActiveEndpoint(db *gorm.DB, endpoint string) error {
+	now := nowMillis()
+	return db.Exec(`
+		INSERT INTO indexer_status (id, active_endpoint, created_at, updated_at)
+		VALUES (1, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE
+		SET active_endpoint = EXCLUDED.active_endpoint, updated_at = EXCLUDED.updated_at`,
+		endpoint, now, now).Error
+}
+
+// SetFinality records the finality heights tracked and how.
+func (s *indexerStatus) SetFinality(db *gorm.DB, mode string, safe, finalized *uint64) error {
+	now := nowMillis()
+	return db.Exec(`
+		INSERT INTO indexer_status (id, finality_mode, safe, finalized, created_at, updated_at)
+		VALUES (1, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE
+		SET finality_mode = EXCLUDED.finality_mode, safe = EXCLUDED.safe,
+			finalized = EXCLUDED.finalized, updated_at = EXCLUDED.updated_at`,
+		mode, safe, finalized, now, now).Error
+}
+
+// NewIndexerStatus returns the indexer status with the given state.
+func NewIndexerStatus(chainHead uint64, chainHeadSeenAt *int64, activeEndpoint,
+	finalityMode string, safe, finalized *uint64) IndexerStatusIntf {
+	return &indexerStatus{
+		ID:              1,
+		ChainHead:       chainHead,
+		ChainHeadSeenAt: chainHeadSeenAt,
+		ActiveEndpoint:  activeEndpoint,
+		FinalityMode:    finalityMode,
+		Safe:            safe,
+		Finalized:       finalized,
+	}
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// InternalTransactionIntf ...
+type InternalTransactionIntf interface {
+	GetBlockHash() string
+	GetBlockNumber() uint64
+	GetTxHash() string
+	GetPosition() int64
+	GetTraceAddress() string
+	GetCallType() string
+	GetTxFrom() string
+	GetTxTo() string
+	GetValue() string
+	GetGas() uint64
+	GetGasUsed() uint64
+	GetError() string
+	GetDepth() int64
+	GetByTxHash(db *gorm.DB, txHash, blockHash string) ([]InternalTransactionIntf, error)
+	GetByAddress(db *gorm.DB, address string, n, maxNumber uint64) ([]InternalTransactionIntf, error)
+	SetInternalTransactions(db *gorm.DB, internalTxs []InternalTransactionIntf) error
+	CopyInternalTransactions(db *gorm.DB, internalTxs []InternalTransactionIntf) error
+}
+
+// InternalTransaction is the exported static model interface.
+var InternalTransaction internalTransaction
+
+// internalTransactionsTable inserts internal transactions keyed on their
+// block, transaction and position in the trace. The trace of a transaction in
+// a block doesn't change, so existing rows are kept.
+var internalTransactionsTable = bulkTable{
+	name: "internal_transactions",
+	columns: []string{"block_hash", "block_number", "tx_hash", "position",
+		"trace_address", "call_type", "tx_from", "tx_to", "value", "gas",
+		"gas_used", "error", "depth", "created_at", "updated_at"},
+	key: []string{"block_hash", "tx_hash", "position"},
+}
+
+// internalTransaction is a call made inside the execution of a transaction.
+type internalTransaction struct {
+	BlockHash    string `gorm:"column:block_hash" json:"-"`
+	BlockNumber  uint64 `gorm:"column:block_number" json:"block_num"`
+	TxHash       string `gorm:"column:tx_hash" json:"tx_hash"`
+	Position     int64  `gorm:"column:position" json:"-"`
+	TraceAddress string `gorm:"column:trace_address" json:"trace_address"`
+	CallType     string `gorm:"column:call_type" json:"call_type"`
+	TxFrom       string `gorm:"column:tx_from" json:"from"`
+	TxTo         string `gorm:"column:tx_to" json:"to"`
+	Value        string `gorm:"column:value" json:"value"`
+	Gas          uint64 `gorm:"column:gas" json:"gas"`
+	GasUsed      uint64 `gorm:"column:gas_used" json:"gas_used"`
+	Error        string `gorm:"column:error" json:"error,omitempty"`
+	Depth        int64  `gorm:"column:depth" json:"depth"`
+	CreatedAt    int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt    int64  `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (t *internalTransaction) TableName() string {
+	return "internal_transactions"
+}
+
+// GetBlockHash ...
+func (t *internalTransaction) GetBlockHash() string {
+	return t.BlockHash
+}
+
+// GetBlockNumber ...
+func (t *internalTransaction) GetBlockNumber() uint64 {
+	return t.BlockNumber
+}
+
+// GetTxHash ...
+func (t *internalTransaction) GetTxHash() string {
+	return t.TxHash
+}
+
+// GetPosition returns the position of the call in the flattened trace.
+func (t *internalTransaction) GetPosition() int64 {
+	return t.Position
+}
+
+// GetTraceAddress ...
+func (t *internalTransaction) GetTraceAddress() string {
+	return t.TraceAddress
+}
+
+// GetCallType ...
+func (t *internalTransaction) GetCallType() string {
+	return t.CallType
+}
+
+// GetTxFrom ...
+func (t *internalTransaction) GetTxFrom() string {
+	return t.TxFrom
+}
+
+// GetTxTo ...
+func (t *internalTransaction) GetTxTo() string {
+	return t.TxTo
+}
+
+// GetValue ...
+func (t *internalTransaction) GetValue() string {
+	return t.Value
+}
+
+// GetGas ...
+func (t *internalTransaction) GetGas() uint64 {
+	return t.Gas
+}
+
+// GetGasUsed ...
+func (t *internalTransaction) GetGasUsed() uint64 {
+	return t.GasUsed
+}
+
+// GetError ...
+func (t *internalTransaction) GetError() string {
+	return t.Error
+}
+
+// GetDepth ...
+func (t *internalTransaction) GetDepth() int64 {
+	return t.Depth
+}
+
+// NewInternalTransaction
+func NewInternalTransaction(blockHash string, blockNumber uint64, txHash string,
+	position int64, traceAddress, callType, from, to, value string,
+	gas, gasUsed uint64, callError string, depth int64) InternalTransactionIntf {
+	newInternalTransaction := internalTransaction{
+		BlockHash:    blockHash,
+		BlockNumber:  blockNumber,
+		TxHash:       txHash,
+		Position:     position,
+		TraceAddress: traceAddress,
+		CallType:     callType,
+		TxFrom:       from,
+		TxTo:         to,
+		Value:        value,
+		Gas:          gas,
+		GasUsed:      gasUsed,
+		Error:        callError,
+		Depth:        depth,
+	}
+
+	return &newInternalTransaction
+}
+
+// GetByTxHash returns the internal transactions of a transaction in a block,
+// in trace order.
+func (t *internalTransaction) GetByTxHash(
+	db *gorm.DB, txHash, blockHash string) ([]InternalTransactionIntf, error) {
+	internalTxs := []*internalTransaction{}
+	err := db.Model(t).
+		Where("tx_hash = ? AND block_hash = ?", txHash, blockHash).
+		Order("position asc").
+		Find(&internalTxs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into InternalTransactionIntf slice.
+	internalTxIntfs := []InternalTransactionIntf{}
+	for _, internalTx := range internalTxs {
+		internalTxIntfs = append(internalTxIntfs, internalTx)
+	}
+
+	return internalTxIntfs, nil
+}
+
+// GetByAddress returns the latest n internal transactions from or to an
+// address in canonical transactions of blocks numbered up to maxNumber, the
+// most recent first.
+func (t *internalTransaction) GetByAddress(
+	db *gorm.DB, address string, n, maxNumber uint64) ([]InternalTransactionIntf, error) {
+	internalTxs := []*internalTransaction{}
+	err := db.Raw(`
+		SELECT i.*
+		FROM internal_transactions i
+		JOIN transactions t ON t.tx_hash = i.tx_hash AND t.block_hash = i.block_hash
+		WHERE t.canonical AND i.block_number <= ? AND (i.tx_from = ? OR i.tx_to = ?)
+		ORDER BY i.block_number DESC, i.tx_hash, i.position
+		LIMIT ?`, maxNumber, address, address, n).Scan(&internalTxs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into InternalTransactionIntf slice.
+	internalTxIntfs := []InternalTransactionIntf{}
+	for _, internalTx := range internalTxs {
+		internalTxIntfs = append(internalTxIntfs, internalTx)
+	}
+
+	return internalTxIntfs, nil
+}
+
+// SetInternalTransactions inserts internal transactions with multi-row
+// statements.
+func (t *internalTransaction) SetInternalTransactions(
+	db *gorm.DB, internalTxs []InternalTransactionIntf) error {
+	return upsertRows(db, internalTransactionsTable, internalTransactionRows(internalTxs))
+}
+
+// CopyInternalTransactions inserts internal transactions with COPY, for
+// PostgreSQL only.
+func (t *internalTransaction) CopyInternalTransactions(
+	db *gorm.DB, internalTxs []InternalTransactionIntf) error {
+	return copyRows(db, internalTransactionsTable, internalTransactionRows(internalTxs))
+}
+
+// internalTransactionRows returns the internalTransactionsTable rows of
+// internal transactions.
+func internalTransactionRows(internalTxs []InternalTransactionIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(internalTxs))
+	for i, t := range internalTxs {
+		rows[i] = []interface{}{t.GetBlockHash(), int64(t.GetBlockNumber()),
+			t.GetTxHash(), t.GetPosition(), t.GetTraceAddress(), t.GetCallType(),
+			t.GetTxFrom(), t.GetTxTo(), t.GetValue(), int64(t.GetGas()),
+			int64(t.GetGasUsed()), t.GetError(), t.GetDepth(), now, now}
+	}
+	return rows
+}
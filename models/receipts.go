@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/jinzhu/gorm"
 )
@@ -8,22 +10,30 @@ import (
 // ReceiptIntf ...
 type ReceiptIntf interface {
 	GetTxHash() string
-	GetByHash(db *gorm.DB, txHash string) (ReceiptIntf, error)
+	GetBlockHash() string
+	GetByHash(db *gorm.DB, txHash, blockHash string) (ReceiptIntf, error)
 	SetReceipt(db *gorm.DB) error
+	SetReceipts(db *gorm.DB, receipts []ReceiptIntf) error
+	CopyReceipts(db *gorm.DB, receipts []ReceiptIntf) error
 }
 
 // Receipt is the exported static model interface.
 var Receipt receipt
 
+// receiptsTable inserts receipts on their block and transaction hash, so
+// an orphaned block keeps the receipts of its transactions.
+var receiptsTable = bulkTable{
+	name:    "receipts",
+	columns: []string{"tx_hash", "block_hash", "created_at", "updated_at"},
+	key:     []string{"block_hash", "tx_hash"},
+}
+
 // receipt ...
 type receipt struct {
 	TxHash    string `gorm:"column:tx_hash" json:"tx_hash"`
-	CreatedAt int64  `gorm:"column:created_at;default:extract(epoch from now())*1000" json:"-"`
-	UpdatedAt int64  `gorm:"column:updated_at;default:extract(epoch from now())*1000" json:"-"`
-}
-
-func init() {
-	registerModelForAutoMigration(&receipt{})
+	BlockHash string `gorm:"column:block_hash" json:"-"`
+	CreatedAt int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt int64  `gorm:"column:updated_at" json:"-"`
 }
 
 // TableName is used by GORM to choose which table to use.
@@ -31,18 +41,16 @@ func (r *receipt) TableName() string {
 	return "receipts"
 }
 
-// createIndexes ...
-func (r *receipt) createIndexes(db *gorm.DB) error {
+// BeforeCreate is called by GORM to set the creation and update times.
+func (r *receipt) BeforeCreate() error {
+	r.CreatedAt = nowMillis()
+	r.UpdatedAt = r.CreatedAt
 	return nil
 }
 
-// createUniqueIndexes ...
-func (r *receipt) createUniqueIndexes(db *gorm.DB) error {
-	return nil
-}
-
-// createForeignKeys ...
-func (r *receipt) createForeignKeys(db *gorm.DB) error {
+// BeforeUpdate is called by GORM to set the update time.
+func (r *receipt) BeforeUpdate() error {
+	r.UpdatedAt = nowMillis()
 	return nil
 }
 
@@ -51,6 +59,11 @@ func (r *receipt) GetTxHash() string {
 	return r.TxHash
 }
 
+// GetBlockHash returns the hash of the block the transaction was executed in.
+func (r *receipt) GetBlockHash() string {
+	return r.BlockHash
+}
+
 // GetCreatedAt ...
 func (r *receipt) GetCreatedAt() int64 {
 	return r.CreatedAt
@@ -64,25 +77,53 @@ func (r *receipt) GetUpdatedAt() int64 {
 // NewReceipt
 func NewReceipt(r *types.Receipt) (ReceiptIntf, error) {
 	newReceipt := receipt{
-		TxHash: r.TxHash.String(),
+		TxHash:    r.TxHash.String(),
+		BlockHash: r.BlockHash.String(),
 	}
 
 	return &newReceipt, nil
 }
 
-// GetByHash ...
-func (r *receipt) GetByHash(db *gorm.DB, txHash string) (ReceiptIntf, error) {
-	// Get receipt based on given transaction hash
-	receipt := receipt{}
-	err := db.Model(r).Where("tx_hash = ?", txHash).First(&receipt).Error
-	if err != nil {
+// GetByHash returns the receipt of a transaction in a block.
+func (r *receipt) GetByHash(db *gorm.DB, txHash, blockHash string) (ReceiptIntf, error) {
+	// Get receipt based on given transaction and block hashes
+	receipts := []*receipt{}
+	err := db.Raw(`
+		SELECT r.*
+		FROM receipts r
+		WHERE r.tx_hash = ? AND r.block_hash = ?`, txHash, blockHash).Scan(&receipts).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
+	if len(receipts) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
 
-	return &receipt, nil
+	return receipts[0], nil
 }
 
 // SetReceipt ...
 func (r *receipt) SetReceipt(db *gorm.DB) error {
-	return db.Where("tx_hash = ?", r.TxHash).FirstOrCreate(r).Error
+	return db.Where("block_hash = ? AND tx_hash = ?", r.BlockHash, r.TxHash).
+		FirstOrCreate(r).Error
+}
+
+// SetReceipts inserts receipts with multi-row statements.
+func (r *receipt) SetReceipts(db *gorm.DB, receipts []ReceiptIntf) error {
+	return upsertRows(db, receiptsTable, receiptRows(receipts))
+}
+
+// CopyReceipts inserts receipts with COPY, for PostgreSQL only.
+func (r *receipt) CopyReceipts(db *gorm.DB, receipts []ReceiptIntf) error {
+	return copyRows(db, receiptsTable, receiptRows(receipts))
+}
+
+// receiptRows returns the receiptsTable rows of receipts.
+func receiptRows(receipts []ReceiptIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(receipts))
+	for i, r := range receipts {
+		rows[i] = []interface{}{r.GetTxHash(), r.GetBlockHash(), now, now}
+	}
+	return rows
 }
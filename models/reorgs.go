@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ReorgIntf ...
+type ReorgIntf interface {
+	GetID() uint64
+	GetForkNumber() uint64
+	GetDepth() uint64
+	GetOldTipNumber() uint64
+	GetOldTipHash() string
+	GetNewTipNumber() uint64
+	GetNewTipHash() string
+	GetDetectedAt() int64
+	GetLatest(db *gorm.DB, n uint64) ([]ReorgIntf, error)
+	SetReorg(db *gorm.DB) error
+}
+
+// Reorg is the exported static model interface.
+var Reorg reorg
+
+// reorg is a chain reorganization seen by the indexer. The old chain, from
+// the block following the fork point up to its tip, was replaced by the new
+// one.
+type reorg struct {
+	ID           uint64 `gorm:"column:id;primary_key" json:"id"`
+	ForkNumber   uint64 `gorm:"column:fork_number" json:"fork_block_num"`
+	Depth        uint64 `gorm:"column:depth" json:"depth"`
+	OldTipNumber uint64 `gorm:"column:old_tip_number" json:"old_tip_num"`
+	OldTipHash   string `gorm:"column:old_tip_hash" json:"old_tip_hash"`
+	NewTipNumber uint64 `gorm:"column:new_tip_number" json:"new_tip_num"`
+	NewTipHash   string `gorm:"column:new_tip_hash" json:"new_tip_hash"`
+	DetectedAt   int64  `gorm:"column:detected_at" json:"detected_at"`
+	CreatedAt    int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt    int64  `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (r *reorg) TableName() string {
+	return "reorgs"
+}
+
+// BeforeCreate is called by GORM to set the creation and update times.
+func (r *reorg) BeforeCreate() error {
+	r.CreatedAt = nowMillis()
+	r.UpdatedAt = r.CreatedAt
+	return nil
+}
+
+// GetID ...
+func (r *reorg) GetID() uint64 {
+	return r.ID
+}
+
+// GetForkNumber returns the number of the highest block on both chains.
+func (r *reorg) GetForkNumber() uint64 {
+	return r.ForkNumber
+}
+
+// GetDepth returns the number of blocks of the old chain replaced.
+func (r *reorg) GetDepth() uint64 {
+	return r.Depth
+}
+
+// GetOldTipNumber ...
+func (r *reorg) GetOldTipNumber() uint64 {
+	return r.OldTipNumber
+}
+
+// GetOldTipHash ...
+func (r *reorg) GetOldTipHash() string {
+	return r.OldTipHash
+}
+
+// GetNewTipNumber ...
+func (r *reorg) GetNewTipNumber() uint64 {
+	return r.NewTipNumber
+}
+
+// GetNewTipHash ...
+func (r *reorg) GetNewTipHash() string {
+	return r.NewTipHash
+}
+
+// GetDetectedAt returns the detection time in milliseconds.
+func (r *reorg) GetDetectedAt() int64 {
+	return r.DetectedAt
+}
+
+// NewReorg returns a reorg from the fork point to the old and new tips,
+// detected now.
+func NewReorg(forkNumber uint64, oldTip, newTip BlockIntf) ReorgIntf {
+	newReorg := reorg{
+		ForkNumber:   forkNumber,
+		OldTipNumber: oldTip.GetNumber(),
+		OldTipHash:   oldTip.GetHash(),
+		NewTipNumber: newTip.GetNumber(),
+		NewTipHash:   newTip.GetHash(),
+		DetectedAt:   nowMillis(),
+	}
+	if oldTip.GetNumber() > forkNumber {
+		newReorg.Depth = oldTip.GetNumber() - forkNumber
+	}
+
+	return &newReorg
+}
+
+// GetLatest returns the latest n reorgs, the most recent first.
+func (r *reorg) GetLatest(db *gorm.DB, n uint64) ([]ReorgIntf, error) {
+	reorgs := []*reorg{}
+	err := db.Model(r).Order("id desc").Limit(n).Find(&reorgs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into ReorgIntf slice.
+	reorgIntfs := []ReorgIntf{}
+	for _, reorg := range reorgs {
+		reorgIntfs = append(reorgIntfs, reorg)
+	}
+
+	return reorgIntfs, nil
+}
+
+// SetReorg ...
+func (r *reorg) SetReorg(db *gorm.DB) error {
+	return db.Create(r).Error
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TokenTransferIntf ...
+type TokenTransferIntf interface {
+	GetBlockHash() string
+	GetBlockNumber() uint64
+	GetTxHash() string
+	GetLogIndex() int64
+	GetToken() string
+	GetTxFrom() string
+	GetTxTo() string
+	GetAmount() string
+	GetByToken(db *gorm.DB, token string, n, maxNumber uint64) ([]TokenTransferIntf, error)
+	GetByAddress(db *gorm.DB, address string, n, maxNumber uint64) ([]TokenTransferIntf, error)
+	SetTokenTransfers(db *gorm.DB, transfers []TokenTransferIntf) error
+	CopyTokenTransfers(db *gorm.DB, transfers []TokenTransferIntf) error
+}
+
+// TokenTransfer is the exported static model interface.
+var TokenTransfer tokenTransfer
+
+// tokenTransfersTable inserts transfers keyed on their block and log index.
+// The logs of a block don't change, so existing rows are kept.
+var tokenTransfersTable = bulkTable{
+	name: "token_transfers",
+	columns: []string{"block_hash", "block_number", "tx_hash", "log_index",
+		"token", "tx_from", "tx_to", "amount", "created_at", "updated_at"},
+	key: []string{"block_hash", "log_index"},
+}
+
+// tokenTransfer is an ERC-20 Transfer event.
+type tokenTransfer struct {
+	BlockHash   string `gorm:"column:block_hash" json:"-"`
+	BlockNumber uint64 `gorm:"column:block_number" json:"block_num"`
+	TxHash      string `gorm:"column:tx_hash" json:"tx_hash"`
+	LogIndex    int64  `gorm:"column:log_index" json:"log_index"`
+	Token       string `gorm:"column:token" json:"token"`
+	TxFrom      string `gorm:"column:tx_from" json:"from"`
+	TxTo        string `gorm:"column:tx_to" json:"to"`
+	Amount      string `gorm:"column:amount" json:"amount"`
+	CreatedAt   int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt   int64  `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (t *tokenTransfer) TableName() string {
+	return "token_transfers"
+}
+
+// GetBlockHash ...
+func (t *tokenTransfer) GetBlockHash() string {
+	return t.BlockHash
+}
+
+// GetBlockNumber ...
+func (t *tokenTransfer) GetBlockNumber() uint64 {
+	return t.BlockNumber
+}
+
+// GetTxHash ...
+func (t *tokenTransfer) GetTxHash() string {
+	return t.TxHash
+}
+
+// GetLogIndex returns the index of the log in the block.
+func (t *tokenTransfer) GetLogIndex() int64 {
+	return t.LogIndex
+}
+
+// GetToken returns the address of the token contract.
+func (t *tokenTransfer) GetToken() string {
+	return t.Token
+}
+
+// GetTxFrom ...
+func (t *tokenTransfer) GetTxFrom() string {
+	return t.TxFrom
+}
+
+// GetTxTo ...
+func (t *tokenTransfer) GetTxTo() string {
+	return t.TxTo
+}
+
+// GetAmount returns the amount transferred in the smallest unit of the token.
+func (t *tokenTransfer) GetAmount() string {
+	return t.Amount
+}
+
+// NewTokenTransfer
+func NewTokenTransfer(blockHash string, blockNumber uint64, txHash string,
+	logIndex int64, token, from, to, amount string) TokenTransferIntf {
+	newTokenTransfer := tokenTransfer{
+		BlockHash:   blockHash,
+		BlockNumber: blockNumber,
+		TxHash:      txHash,
+		LogIndex:    logIndex,
+		Token:       token,
+		TxFrom:      from,
+		TxTo:        to,
+		Amount:      amount,
+	}
+
+	return &newTokenTransfer
+}
+
+// GetByToken returns the latest n transfers of a token in canonical
+// transactions of blocks numbered up to maxNumber, the most recent first.
+func (t *tokenTransfer) GetByToken(
+	db *gorm.DB, token string, n, maxNumber uint64) ([]TokenTransferIntf, error) {
+	return t.getCanonical(db, "tr.token = ?", []interface{}{token}, n, maxNumber)
+}
+
+// GetByAddress returns the latest n transfers from or to an address in
+// canonical transactions of blocks numbered up to maxNumber, the most recent
+// first.
+func (t *tokenTransfer) GetByAddress(
+	db *gorm.DB, address string, n, maxNumber uint64) ([]TokenTransferIntf, error) {
+	return t.getCanonical(db, "(tr.tx_from = ? OR tr.tx_to = ?)",
+		[]interface{}{address, address}, n, maxNumber)
+}
+
+// getCanonical returns the latest n transfers matching the condition in
+// canonical transactions of blocks numbered up to maxNumber.
+func (t *tokenTransfer) getCanonical(db *gorm.DB, condition string,
+	args []interface{}, n, maxNumber uint64) ([]TokenTransferIntf, error) {
+	transfers := []*tokenTransfer{}
+	args = append(append([]interface{}{maxNumber}, args...), n)
+	err := db.Raw(`
+		SELECT tr.*
+		FROM token_transfers tr
+		JOIN transactions t ON t.tx_hash = tr.tx_hash AND t.block_hash = tr.block_hash
+		WHERE t.canonical AND tr.block_number <= ? AND `+condition+`
+		ORDER BY tr.block_number DESC, tr.log_index DESC
+		LIMIT ?`, args...).Scan(&transfers).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into TokenTransferIntf slice.
+	transferIntfs := []TokenTransferIntf{}
+	for _, transfer := range transfers {
+		transferIntfs = append(transferIntfs, transfer)
+	}
+
+	return transferIntfs, nil
+}
+
+// SetTokenTransfers inserts transfers with multi-row statements.
+func (t *tokenTransfer) SetTokenTransfers(db *gorm.DB, transfers []TokenTransferIntf) error {
+	return upsertRows(db, tokenTransfersTable, tokenTransferRows(transfers))
+}
+
+// CopyTokenTransfers inserts transfers with COPY, for PostgreSQL only.
+func (t *tokenTransfer) CopyTokenTransfers(db *gorm.DB, transfers []TokenTransferIntf) error {
+	return copyRows(db, tokenTransfersTable, tokenTransferRows(transfers))
+}
+
+// tokenTransferRows returns the tokenTransfersTable rows of transfers.
+func tokenTransferRows(transfers []TokenTransferIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(transfers))
+	for i, t := range transfers {
+		rows[i] = []interface{}{t.GetBlockHash(), int64(t.GetBlockNumber()),
+			t.GetTxHash(), t.GetLogIndex(), t.GetToken(), t.GetTxFrom(),
+			t.GetTxTo(), t.GetAmount(), now, now}
+	}
+	return rows
+}
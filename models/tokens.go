@@ -0,0 +1,221 @@
+package models
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TokenIntf ...
+type TokenIntf interface {
+	GetAddress() string
+	GetName() *string
+	GetSymbol() *string
+	GetDecimals() *uint64
+	GetTotalSupply() *string
+	GetResolved() bool
+	GetAttempts() int64
+	GetRefreshedAt() *int64
+	GetNextRefreshAt() int64
+	SetMetadata(name, symbol *string, decimals *uint64, totalSupply *string, refreshedAt, nextRefreshAt int64)
+	SetFailed(nextRefreshAt int64)
+	GetByAddress(db *gorm.DB, address string) (TokenIntf, error)
+	GetDue(db *gorm.DB, now int64, n uint64) ([]TokenIntf, error)
+	SetTokens(db *gorm.DB, tokens []TokenIntf) error
+	UpdateToken(db *gorm.DB) error
+}
+
+// Token is the exported static model interface.
+var Token token
+
+// tokensTable inserts newly seen tokens keyed on their address. The metadata
+// of known tokens is only written by the resolver.
+var tokensTable = bulkTable{
+	name:    "tokens",
+	columns: []string{"address", "created_at", "updated_at"},
+	key:     []string{"address"},
+}
+
+// token is a token contract with its metadata.
+type token struct {
+	Address       string  `gorm:"column:address;primary_key" json:"address"`
+	Name          *string `gorm:"column:name" json:"name"`
+	Symbol        *string `gorm:"column:symbol" json:"symbol"`
+	Decimals      *uint64 `gorm:"column:decimals" json:"decimals"`
+	TotalSupply   *string `gorm:"column:total_supply" json:"total_supply"`
+	Resolved      bool    `gorm:"column:resolved" json:"resolved"`
+	Attempts      int64   `gorm:"column:attempts" json:"-"`
+	RefreshedAt   *int64  `gorm:"column:refreshed_at" json:"refreshed_at"`
+	NextRefreshAt int64   `gorm:"column:next_refresh_at" json:"-"`
+	CreatedAt     int64   `gorm:"column:created_at" json:"-"`
+	UpdatedAt     int64   `gorm:"column:updated_at" json:"-"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (t *token) TableName() string {
+	return "tokens"
+}
+
+// GetAddress ...
+func (t *token) GetAddress() string {
+	return t.Address
+}
+
+// GetName returns the name of the token, or nil if unknown.
+func (t *token) GetName() *string {
+	return t.Name
+}
+
+// GetSymbol returns the symbol of the token, or nil if unknown.
+func (t *token) GetSymbol() *string {
+	return t.Symbol
+}
+
+// GetDecimals returns the decimals of the token, or nil if unknown.
+func (t *token) GetDecimals() *uint64 {
+	return t.Decimals
+}
+
+// GetTotalSupply returns the total supply of the token in its smallest unit
+// when last refreshed, or nil if unknown.
+func (t *token) GetTotalSupply() *string {
+	return t.TotalSupply
+}
+
+// GetResolved returns whether the metadata was resolved at least once.
+func (t *token) GetResolved() bool {
+	return t.Resolved
+}
+
+// GetAttempts returns the number of failed attempts to resolve the metadata
+// since it was last resolved.
+func (t *token) GetAttempts() int64 {
+	return t.Attempts
+}
+
+// GetRefreshedAt returns the time the metadata was last resolved, in
+// milliseconds.
+func (t *token) GetRefreshedAt() *int64 {
+	return t.RefreshedAt
+}
+
+// GetNextRefreshAt returns the time the metadata is due for a refresh, in
+// milliseconds.
+func (t *token) GetNextRefreshAt() int64 {
+	return t.NextRefreshAt
+}
+
+// SetMetadata sets the resolved metadata, and schedules the next refresh.
+func (t *token) SetMetadata(name, symbol *string, decimals *uint64,
+	totalSupply *string, refreshedAt, nextRefreshAt int64) {
+	t.Name = name
+	t.Symbol = symbol
+	t.Decimals = decimals
+	t.TotalSupply = totalSupply
+	t.Resolved = true
+	t.Attempts = 0
+	t.RefreshedAt = &refreshedAt
+	t.NextRefreshAt = nextRefreshAt
+}
+
+// SetFailed counts a failed attempt to resolve the metadata, and schedules
+// the next one.
+func (t *token) SetFailed(nextRefreshAt int64) {
+	t.Attempts++
+	t.NextRefreshAt = nextRefreshAt
+}
+
+// NewToken returns a newly seen token, due for resolution.
+func NewToken(address string) TokenIntf {
+	newToken := token{
+		Address: address,
+	}
+
+	return &newToken
+}
+
+// GetByAddress ...
+func (t *token) GetByAddress(db *gorm.DB, address string) (TokenIntf, error) {
+	token := token{}
+	err := db.Model(t).Where("address = ?", address).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// GetDue returns up to n tokens due for a refresh at the given time, the
+// longest due first.
+func (t *token) GetDue(db *gorm.DB, now int64, n uint64) ([]TokenIntf, error) {
+	tokens := []*token{}
+	err := db.Model(t).
+		Where("next_refresh_at <= ?", now).
+		Order("next_refresh_at asc").
+		Limit(n).
+		Find(&tokens).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Organize into TokenIntf slice.
+	tokenIntfs := []TokenIntf{}
+	for _, token := range tokens {
+		tokenIntfs = append(tokenIntfs, token)
+	}
+
+	return tokenIntfs, nil
+}
+
+// SetTokens inserts newly seen tokens with multi-row statements, keeping the
+// known ones.
+func (t *token) SetTokens(db *gorm.DB, tokens []TokenIntf) error {
+	now := nowMillis()
+	rows := make([][]interface{}, len(tokens))
+	for i, token := range tokens {
+		rows[i] = []interface{}{token.GetAddress(), now, now}
+	}
+	return upsertRows(db, tokensTable, rows)
+}
+
+// UpdateToken writes the metadata and the refresh schedule of the token.
+func (t *token) UpdateToken(db *gorm.DB) error {
+	return db.Model(t).UpdateColumns(map[string]interface{}{
+		"name":            t.Name,
+		"symbol":          t.Symbol,
+		"decimals":        t.Decimals,
+		"total_supply":    t.TotalSupply,
+		"resolved":        t.Resolved,
+		"attempts":        t.Attempts,
+		"refreshed_at":    t.RefreshedAt,
+		"next_refresh_at": t.NextRefreshAt,
+		"updated_at":      nowMillis(),
+	}).Error
+}
+
+// FormatUnits formats an amount of a token in its smallest unit as a decimal
+// number of whole tokens, e.g. "1.5" for 1500000 with 6 decimals. It returns
+// false if the amount isn't a non-negative integer.
+func FormatUnits(amount string, decimals uint64) (string, bool) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok || value.Sign() < 0 {
+		return "", false
+	}
+	digits := value.String()
+	if decimals == 0 {
+		return digits, true
+	}
+
+	// pad to at least one whole digit, then split off the fraction
+	if uint64(len(digits)) <= decimals {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	point := uint64(len(digits)) - decimals
+	whole, fraction := digits[:point], strings.TrimRight(digits[point:], "0")
+	if len(fraction) == 0 {
+		return whole, true
+	}
+	return whole + "." + fraction, true
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/jinzhu/gorm"
 )
@@ -8,24 +11,40 @@ import (
 // TransactionLogIntf ...
 type TransactionLogIntf interface {
 	GetTxHash() string
-	GetByHash(db *gorm.DB, txHash string) ([]TransactionLogIntf, error)
+	GetBlockHash() string
+	GetLogIndex() int64
+	GetAddress() string
+	GetTopics() []string
+	GetData() []byte
+	GetByHash(db *gorm.DB, txHash, blockHash string) ([]TransactionLogIntf, error)
 	SetTransactionLog(db *gorm.DB) error
+	SetTransactionLogs(db *gorm.DB, logs []TransactionLogIntf) error
+	CopyTransactionLogs(db *gorm.DB, logs []TransactionLogIntf) error
 }
 
 // TransactionLog is the exported static model interface.
 var TransactionLog transactionLog
 
+// transactionLogsTable upserts logs on their block hash and index in the
+// block, so the logs of a transaction in an orphaned block are kept apart.
+var transactionLogsTable = bulkTable{
+	name: "transaction_logs",
+	columns: []string{"tx_hash", "block_hash", "log_index", "address", "topics",
+		"data", "created_at", "updated_at"},
+	key:    []string{"block_hash", "log_index"},
+	update: []string{"tx_hash", "address", "topics", "data", "updated_at"},
+}
+
 // transactionLog ...
 type transactionLog struct {
 	TxHash    string `gorm:"column:tx_hash" json:"tx_hash"`
+	BlockHash string `gorm:"column:block_hash" json:"-"`
 	LogIndex  int64  `gorm:"column:log_index" json:"log_index"`
+	Address   string `gorm:"column:address" json:"address"`
+	Topics    string `gorm:"column:topics" json:"topics"`
 	Data      []byte `gorm:"column:data" json:"data"`
-	CreatedAt int64  `gorm:"column:created_at;default:extract(epoch from now())*1000" json:"-"`
-	UpdatedAt int64  `gorm:"column:updated_at;default:extract(epoch from now())*1000" json:"-"`
-}
-
-func init() {
-	registerModelForAutoMigration(&transactionLog{})
+	CreatedAt int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt int64  `gorm:"column:updated_at" json:"-"`
 }
 
 // TableName is used by GORM to choose which table to use.
@@ -33,18 +52,16 @@ func (t *transactionLog) TableName() string {
 	return "transaction_logs"
 }
 
-// createIndexes ...
-func (t *transactionLog) createIndexes(db *gorm.DB) error {
-	return nil
-}
-
-// createUniqueIndexes ...
-func (t *transactionLog) createUniqueIndexes(db *gorm.DB) error {
+// BeforeCreate is called by GORM to set the creation and update times.
+func (t *transactionLog) BeforeCreate() error {
+	t.CreatedAt = nowMillis()
+	t.UpdatedAt = t.CreatedAt
 	return nil
 }
 
-// createForeignKeys ...
-func (t *transactionLog) createForeignKeys(db *gorm.DB) error {
+// BeforeUpdate is called by GORM to set the update time.
+func (t *transactionLog) BeforeUpdate() error {
+	t.UpdatedAt = nowMillis()
 	return nil
 }
 
@@ -53,6 +70,35 @@ func (t *transactionLog) GetTxHash() string {
 	return t.TxHash
 }
 
+// GetBlockHash returns the hash of the block the log was emitted in.
+func (t *transactionLog) GetBlockHash() string {
+	return t.BlockHash
+}
+
+// GetLogIndex ...
+func (t *transactionLog) GetLogIndex() int64 {
+	return t.LogIndex
+}
+
+// GetAddress returns the address of the contract emitting the log, or an
+// empty string for logs indexed before it was stored.
+func (t *transactionLog) GetAddress() string {
+	return t.Address
+}
+
+// GetTopics returns the topics of the log.
+func (t *transactionLog) GetTopics() []string {
+	if len(t.Topics) == 0 {
+		return nil
+	}
+	return strings.Split(t.Topics, ",")
+}
+
+// GetData ...
+func (t *transactionLog) GetData() []byte {
+	return t.Data
+}
+
 // GetCreatedAt ...
 func (t *transactionLog) GetCreatedAt() int64 {
 	return t.CreatedAt
@@ -65,19 +111,33 @@ func (t *transactionLog) GetUpdatedAt() int64 {
 
 // NewTransactionLog
 func NewTransactionLog(t *types.Log, txHash string) (TransactionLogIntf, error) {
+	topics := make([]string, len(t.Topics))
+	for i, topic := range t.Topics {
+		topics[i] = topic.String()
+	}
 	newTransactionLog := transactionLog{
-		TxHash: txHash,
+		TxHash:    txHash,
+		BlockHash: t.BlockHash.String(),
+		LogIndex:  int64(t.Index),
+		Address:   t.Address.String(),
+		Topics:    strings.Join(topics, ","),
+		Data:      t.Data,
 	}
 
 	return &newTransactionLog, nil
 }
 
-// GetByHash ...
-func (t *transactionLog) GetByHash(db *gorm.DB, txHash string) ([]TransactionLogIntf, error) {
-	// Get transactionLog based on given transaction hash
+// GetByHash returns the logs of a transaction in a block, leaving out the
+// logs of the other blocks it was mined in.
+func (t *transactionLog) GetByHash(db *gorm.DB, txHash, blockHash string) ([]TransactionLogIntf, error) {
+	// Get transactionLog based on given transaction and block hashes
 	transactionLogs := []*transactionLog{}
-	err := db.Model(t).Where("tx_hash = ?", txHash).First(&transactionLogs).Error
-	if err != nil {
+	err := db.Raw(`
+		SELECT l.*
+		FROM transaction_logs l
+		WHERE l.tx_hash = ? AND l.block_hash = ?
+		ORDER BY l.log_index ASC`, txHash, blockHash).Scan(&transactionLogs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
 
@@ -92,5 +152,27 @@ func (t *transactionLog) GetByHash(db *gorm.DB, txHash string) ([]TransactionLog
 
 // SetTransactionLog ...
 func (t *transactionLog) SetTransactionLog(db *gorm.DB) error {
-	return db.Where("tx_hash = ?", t.TxHash).FirstOrCreate(t).Error
+	return db.Where("block_hash = ? AND log_index = ?", t.BlockHash, t.LogIndex).
+		FirstOrCreate(t).Error
+}
+
+// SetTransactionLogs upserts logs with multi-row statements.
+func (t *transactionLog) SetTransactionLogs(db *gorm.DB, logs []TransactionLogIntf) error {
+	return upsertRows(db, transactionLogsTable, transactionLogRows(logs))
+}
+
+// CopyTransactionLogs upserts logs with COPY, for PostgreSQL only.
+func (t *transactionLog) CopyTransactionLogs(db *gorm.DB, logs []TransactionLogIntf) error {
+	return copyRows(db, transactionLogsTable, transactionLogRows(logs))
+}
+
+// transactionLogRows returns the transactionLogsTable rows of logs.
+func transactionLogRows(logs []TransactionLogIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(logs))
+	for i, t := range logs {
+		rows[i] = []interface{}{t.GetTxHash(), t.GetBlockHash(), t.GetLogIndex(),
+			t.GetAddress(), strings.Join(t.GetTopics(), ","), t.GetData(), now, now}
+	}
+	return rows
 }
@@ -15,16 +15,32 @@ type TransactionIntf interface {
 	GetNounce() uint64
 	GetData() []byte
 	GetValue() string
+	GetCanonical() bool
+	SetCanonical(canonical bool)
 	GetCreatedAt() int64
 	GetUpdatedAt() int64
 	GetByHash(db *gorm.DB, hash string) (TransactionIntf, error)
 	GetByBlockHash(db *gorm.DB, hash string) ([]TransactionIntf, error)
 	SetTransaction(db *gorm.DB) error
+	SetTransactions(db *gorm.DB, transactions []TransactionIntf) error
+	CopyTransactions(db *gorm.DB, transactions []TransactionIntf) error
+	UpdateCanonicalByBlockHash(db *gorm.DB, hash string, canonical bool) error
 }
 
 // Transaction is the exported static model interface.
 var Transaction transaction
 
+// transactionsTable upserts transactions on their block and hash. A
+// transaction is kept in every block it's mined in, so an orphaned block
+// keeps its transactions, which are canonical again if it's restored.
+var transactionsTable = bulkTable{
+	name: "transactions",
+	columns: []string{"block_hash", "tx_hash", "tx_from", "tx_to", "nounce",
+		"data", "value", "canonical", "created_at", "updated_at"},
+	key:    []string{"block_hash", "tx_hash"},
+	update: []string{"canonical", "updated_at"},
+}
+
 // transaction ...
 type transaction struct {
 	BlockHash string `gorm:"column:block_hash" json:"-"`
@@ -34,12 +50,9 @@ type transaction struct {
 	Nounce    uint64 `gorm:"column:nounce" json:"nounce"`
 	Data      []byte `gorm:"column:data" json:"data"`
 	Value     string `gorm:"column:value" json:"value"`
-	CreatedAt int64  `gorm:"column:created_at;default:extract(epoch from now())*1000" json:"-"`
-	UpdatedAt int64  `gorm:"column:updated_at;default:extract(epoch from now())*1000" json:"-"`
-}
-
-func init() {
-	registerModelForAutoMigration(&transaction{})
+	Canonical bool   `gorm:"column:canonical" json:"-"`
+	CreatedAt int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt int64  `gorm:"column:updated_at" json:"-"`
 }
 
 // TableName is used by GORM to choose which table to use.
@@ -47,18 +60,16 @@ func (b *transaction) TableName() string {
 	return "transactions"
 }
 
-// createIndexes ...
-func (b *transaction) createIndexes(db *gorm.DB) error {
-	return nil
-}
-
-// createUniqueIndexes ...
-func (b *transaction) createUniqueIndexes(db *gorm.DB) error {
+// BeforeCreate is called by GORM to set the creation and update times.
+func (b *transaction) BeforeCreate() error {
+	b.CreatedAt = nowMillis()
+	b.UpdatedAt = b.CreatedAt
 	return nil
 }
 
-// createForeignKeys ...
-func (b *transaction) createForeignKeys(db *gorm.DB) error {
+// BeforeUpdate is called by GORM to set the update time.
+func (b *transaction) BeforeUpdate() error {
+	b.UpdatedAt = nowMillis()
 	return nil
 }
 
@@ -97,6 +108,16 @@ func (b *transaction) GetValue() string {
 	return b.Value
 }
 
+// GetCanonical ...
+func (b *transaction) GetCanonical() bool {
+	return b.Canonical
+}
+
+// SetCanonical ...
+func (b *transaction) SetCanonical(canonical bool) {
+	b.Canonical = canonical
+}
+
 // GetCreatedAt ...
 func (b *transaction) GetCreatedAt() int64 {
 	return b.CreatedAt
@@ -125,16 +146,17 @@ func NewTransaction(t *types.Transaction, blockHash string) (TransactionIntf, er
 		Nounce:    t.Nonce(),
 		Data:      t.Data(),
 		Value:     t.Value().String(),
+		Canonical: true,
 	}
 
 	return &newTransaction, nil
 }
 
-// GetByHash ...
+// GetByHash returns a transaction in the canonical block it's in.
 func (t *transaction) GetByHash(db *gorm.DB, hash string) (TransactionIntf, error) {
-	// Get transaction based on given number
+	// Get transaction based on given hash
 	transaction := transaction{}
-	err := db.Model(t).Where("tx_hash = ?", hash).First(&transaction).Error
+	err := db.Model(t).Where("tx_hash = ? AND canonical", hash).First(&transaction).Error
 	if err != nil {
 		return nil, err
 	}
@@ -161,5 +183,35 @@ func (t *transaction) GetByBlockHash(db *gorm.DB, hash string) ([]TransactionInt
 
 // SetTransaction ...
 func (t *transaction) SetTransaction(db *gorm.DB) error {
-	return db.Where("tx_hash = ?", t.TxHash).FirstOrCreate(t).Error
+	return db.Where("block_hash = ? AND tx_hash = ?", t.BlockHash, t.TxHash).FirstOrCreate(t).Error
+}
+
+// SetTransactions upserts transactions with multi-row statements.
+func (t *transaction) SetTransactions(db *gorm.DB, transactions []TransactionIntf) error {
+	return upsertRows(db, transactionsTable, transactionRows(transactions))
+}
+
+// CopyTransactions upserts transactions with COPY, for PostgreSQL only.
+func (t *transaction) CopyTransactions(db *gorm.DB, transactions []TransactionIntf) error {
+	return copyRows(db, transactionsTable, transactionRows(transactions))
+}
+
+// UpdateCanonicalByBlockHash flags the transactions of a block canonical or
+// orphaned.
+func (t *transaction) UpdateCanonicalByBlockHash(
+	db *gorm.DB, hash string, canonical bool) error {
+	return db.Model(t).Where("block_hash = ?", hash).
+		UpdateColumns(map[string]interface{}{"canonical": canonical, "updated_at": nowMillis()}).Error
+}
+
+// transactionRows returns the transactionsTable rows of transactions.
+func transactionRows(transactions []TransactionIntf) [][]interface{} {
+	now := nowMillis()
+	rows := make([][]interface{}, len(transactions))
+	for i, t := range transactions {
+		rows[i] = []interface{}{t.GetBlockHash(), t.GetTxHash(), t.GetTxFrom(),
+			t.GetTxTo(), int64(t.GetNounce()), t.GetData(), t.GetValue(),
+			t.GetCanonical(), now, now}
+	}
+	return rows
 }
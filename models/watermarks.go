@@ -0,0 +1,78 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// WatermarkIntf ...
+type WatermarkIntf interface {
+	GetName() string
+	GetNumber() uint64
+	GetUpdatedAt() int64
+	GetByName(db *gorm.DB, name string) (WatermarkIntf, error)
+	Advance(db *gorm.DB, name string, num uint64) error
+	Rewind(db *gorm.DB, name string, num uint64) error
+}
+
+// Watermark is the exported static model interface.
+var Watermark watermark
+
+// watermark is a named block height, such as the height every block up to
+// which is indexed.
+type watermark struct {
+	Name      string `gorm:"column:name;primary_key" json:"name"`
+	Number    uint64 `gorm:"column:number" json:"number"`
+	CreatedAt int64  `gorm:"column:created_at" json:"-"`
+	UpdatedAt int64  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName is used by GORM to choose which table to use.
+func (w *watermark) TableName() string {
+	return "watermarks"
+}
+
+// GetName ...
+func (w *watermark) GetName() string {
+	return w.Name
+}
+
+// GetNumber ...
+func (w *watermark) GetNumber() uint64 {
+	return w.Number
+}
+
+// GetUpdatedAt ...
+func (w *watermark) GetUpdatedAt() int64 {
+	return w.UpdatedAt
+}
+
+// GetByName ...
+func (w *watermark) GetByName(db *gorm.DB, name string) (WatermarkIntf, error) {
+	watermark := watermark{}
+	err := db.Model(w).Where("name = ?", name).First(&watermark).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &watermark, nil
+}
+
+// Advance sets the named watermark to the given number, unless it's already
+// higher. Watermarks only move back through Rewind.
+func (w *watermark) Advance(db *gorm.DB, name string, num uint64) error {
+	now := nowMillis()
+	return db.Exec(`
+		INSERT INTO watermarks (name, number, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE
+		SET number = EXCLUDED.number, updated_at = EXCLUDED.updated_at
+		WHERE watermarks.number < EXCLUDED.number`,
+		name, num, now, now).Error
+}
+
+// Rewind sets the named watermark back to the given number, unless it's
+// already lower, e.g. once blocks under it are orphaned.
+func (w *watermark) Rewind(db *gorm.DB, name string, num uint64) error {
+	return db.Model(w).Where("name = ? AND number > ?", name, num).
+		UpdateColumns(map[string]interface{}{"number": num, "updated_at": nowMillis()}).Error
+}
@@ -3,13 +3,8 @@ package server
 import (
 	"context"
 	"main/api"
-	"main/config"
-	"main/global"
-	"main/logging"
+	"main/lifecycle"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 )
 
 // CreateServer creates an HTTP server listening on the specified address.
@@ -25,34 +20,8 @@ func CreateServer(ctx context.Context, address string) *http.Server {
 		Handler: api.GetRouter(),
 	}
 
-	// Install the shutdown handler.
-	installShutdownHandler(ctx, server)
+	// Stop accepting requests and wait for active ones on shutdown.
+	lifecycle.Register("server", server.Shutdown)
 
 	return server
 }
-
-// installShutdownHandler registers a shutdown handler for graceful shutdown.
-func installShutdownHandler(ctx context.Context, server *http.Server) {
-	// Create signal channel & shutdown timeout context.
-	sigChan := make(chan os.Signal)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	timeoutCtx, cancel := context.WithTimeout(ctx,
-		config.GetMilliseconds("SERVER_SHUTDOWN_GRACE_PERIOD_MS"))
-
-	// Catch signals in a separate goroutine.
-	go func(ctx context.Context) {
-		defer cancel()
-
-		// Wait for signals.
-		sig := <-sigChan
-		signal.Stop(sigChan)
-		logging.Warn(ctx, "Received signal: %s.", sig.String())
-
-		// Perform graceful shutdown.
-		logging.Warn(ctx, "Initiating graceful shutdown...")
-		global.Alive = false
-		if err := server.Shutdown(timeoutCtx); err != nil {
-			logging.Error(ctx, "Failed to shutdown: %s", err.Error())
-		}
-	}(ctx)
-}
@@ -0,0 +1,580 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jinzhu/gorm"
+
+	"main/database"
+	"main/leader"
+	"main/models"
+)
+
+// balancesLockKey is the advisory lock key held by the database transaction
+// applying or reverting balance changes, so balances are updated by one
+// transaction at a time.
+const balancesLockKey = 7245683923
+
+// gormBlockStore is the BlockStore backed by the database module.
+type gormBlockStore struct{}
+
+// GetBlocks implements BlockStore.
+func (gormBlockStore) GetBlocks(ctx context.Context, n, maxNumber uint64,
+	minStatus models.BlockStatus) ([]models.BlockIntf, error) {
+	blocks, err := models.Block.GetBlocks(database.GetSQLWithContext(ctx), n,
+		maxNumber, minStatus)
+	return blocks, notFound(err)
+}
+
+// GetByNumber implements BlockStore.
+func (gormBlockStore) GetByNumber(ctx context.Context, num uint64) (models.BlockIntf, error) {
+	block, err := models.Block.GetByNumber(database.GetSQLWithContext(ctx), num)
+	return block, notFound(err)
+}
+
+// GetByHash implements BlockStore.
+func (gormBlockStore) GetByHash(ctx context.Context, hash string) (models.BlockIntf, error) {
+	block, err := models.Block.GetByHash(database.GetSQLWithContext(ctx), hash)
+	return block, notFound(err)
+}
+
+// GetRange implements BlockStore.
+func (gormBlockStore) GetRange(ctx context.Context, from, to uint64) ([]models.BlockIntf, error) {
+	blocks, err := models.Block.GetRange(database.GetSQLWithContext(ctx), from, to)
+	return blocks, notFound(err)
+}
+
+// GetHighest implements BlockStore.
+func (gormBlockStore) GetHighest(
+	ctx context.Context, minStatus models.BlockStatus) (models.BlockIntf, error) {
+	block, err := models.Block.GetHighest(database.GetSQLWithContext(ctx), minStatus)
+	return block, notFound(err)
+}
+
+// GetLowest implements BlockStore.
+func (gormBlockStore) GetLowest(ctx context.Context) (models.BlockIntf, error) {
+	block, err := models.Block.GetLowest(database.GetSQLWithContext(ctx))
+	return block, notFound(err)
+}
+
+// GetGaps implements BlockStore.
+func (gormBlockStore) GetGaps(ctx context.Context, limit uint64) ([]models.BlockGap, error) {
+	gaps, err := models.Block.GetGaps(database.GetSQLWithContext(ctx), limit)
+	return gaps, notFound(err)
+}
+
+// GetContiguousEnd implements BlockStore.
+func (gormBlockStore) GetContiguousEnd(ctx context.Context, from uint64) (uint64, error) {
+	end, err := models.Block.GetContiguousEnd(database.GetSQLWithContext(ctx), from)
+	return end, notFound(err)
+}
+
+// SetBlock implements BlockStore.
+func (gormBlockStore) SetBlock(ctx context.Context, block models.BlockIntf) error {
+	return block.SetBlock(database.GetSQLWithContext(ctx))
+}
+
+// GetLessFinal implements BlockStore.
+func (gormBlockStore) GetLessFinal(ctx context.Context, n, maxNumber uint64,
+	status models.BlockStatus) ([]models.BlockIntf, error) {
+	return models.Block.GetLessFinal(database.GetSQLWithContext(ctx), n, maxNumber, status)
+}
+
+// DeleteBlock implements BlockStore. Children are deleted by the foreign key
+// cascades, after the balance changes of a canonical block are reverted in a
+// database transaction, if this instance still leads.
+func (gormBlockStore) DeleteBlock(ctx context.Context, block models.BlockIntf) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		if err := orphanBlock(tx, block); err != nil {
+			return err
+		}
+		return block.DeleteBlock(tx)
+	})
+}
+
+// OrphanBlock implements BlockStore. The block and its transactions are
+// flagged in a database transaction, if this instance still leads.
+func (gormBlockStore) OrphanBlock(ctx context.Context, block models.BlockIntf) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		return orphanBlock(tx, block)
+	})
+}
+
+// CommitBlock implements BlockStore. The rows are written in a database
+// transaction, if this instance still leads, with COPY if the context asks
+// for it.
+func (gormBlockStore) CommitBlock(ctx context.Context, block IndexedBlock) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		return commitBlock(tx, block, useCopy(ctx) && database.SupportsCopy())
+	})
+}
+
+// commitBlock writes a block with its transactions, receipts, logs, internal
+// transactions, contracts, token transfers, newly seen tokens and balance
+// changes within the database transaction, and applies its balance changes.
+func commitBlock(tx *gorm.DB, block IndexedBlock, bulkCopy bool) error {
+	// orphan an indexed block with another hash, or keep the same one
+	old, err := models.Block.GetByNumber(tx, block.Block.GetNumber())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return err
+	case old.GetHash() != block.Block.GetHash():
+		if err := orphanBlock(tx, old); err != nil {
+			return err
+		}
+	case !old.GetStatus().AtLeast(block.Block.GetStatus()):
+		return old.UpdateBlockStatus(tx, block.Block.GetStatus())
+	default:
+		return nil
+	}
+
+	// restore the block if it was orphaned, e.g. when the chain switches back
+	orphan, err := models.Block.GetByHash(tx, block.Block.GetHash())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := block.Block.SetBlock(tx); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		orphan.SetCanonical(true)
+		if err := orphan.UpdateBlockStatus(tx, block.Block.GetStatus()); err != nil {
+			return err
+		}
+	}
+
+	// write the children in bulk, parents first
+	w := upsertWriters
+	if bulkCopy {
+		w = copyWriters
+	}
+	if err := w.transactions(tx, block.Transactions); err != nil {
+		return err
+	}
+	if err := w.receipts(tx, block.Receipts); err != nil {
+		return err
+	}
+	if err := w.logs(tx, block.Logs); err != nil {
+		return err
+	}
+	if err := w.internalTxs(tx, block.InternalTransactions); err != nil {
+		return err
+	}
+	if err := w.contractCode(tx, block.ContractCode); err != nil {
+		return err
+	}
+	if err := w.contracts(tx, block.Contracts); err != nil {
+		return err
+	}
+	if err := w.transfers(tx, block.TokenTransfers); err != nil {
+		return err
+	}
+	if err := w.balanceChanges(tx, block.BalanceChanges); err != nil {
+		return err
+	}
+	if err := models.Token.SetTokens(tx, block.Tokens); err != nil {
+		return err
+	}
+	return applyBalanceChanges(tx, block.BalanceChanges, false)
+}
+
+// bulkWriters are the bulk writes of the children of a block, with
+// multi-row upserts or COPY.
+type bulkWriters struct {
+	transactions   func(db *gorm.DB, txs []models.TransactionIntf) error
+	receipts       func(db *gorm.DB, receipts []models.ReceiptIntf) error
+	logs           func(db *gorm.DB, logs []models.TransactionLogIntf) error
+	internalTxs    func(db *gorm.DB, internalTxs []models.InternalTransactionIntf) error
+	contractCode   func(db *gorm.DB, code []models.ContractCodeIntf) error
+	contracts      func(db *gorm.DB, contracts []models.ContractIntf) error
+	transfers      func(db *gorm.DB, transfers []models.TokenTransferIntf) error
+	balanceChanges func(db *gorm.DB, changes []models.BalanceChangeIntf) error
+}
+
+// upsertWriters write the children of a block with multi-row upserts.
+var upsertWriters = bulkWriters{
+	transactions:   models.Transaction.SetTransactions,
+	receipts:       models.Receipt.SetReceipts,
+	logs:           models.TransactionLog.SetTransactionLogs,
+	internalTxs:    models.InternalTransaction.SetInternalTransactions,
+	contractCode:   models.ContractCode.SetContractCode,
+	contracts:      models.Contract.SetContracts,
+	transfers:      models.TokenTransfer.SetTokenTransfers,
+	balanceChanges: models.BalanceChange.SetBalanceChanges,
+}
+
+// copyWriters write the children of a block with COPY, for PostgreSQL only.
+var copyWriters = bulkWriters{
+	transactions:   models.Transaction.CopyTransactions,
+	receipts:       models.Receipt.CopyReceipts,
+	logs:           models.TransactionLog.CopyTransactionLogs,
+	internalTxs:    models.InternalTransaction.CopyInternalTransactions,
+	contractCode:   models.ContractCode.CopyContractCode,
+	contracts:      models.Contract.CopyContracts,
+	transfers:      models.TokenTransfer.CopyTokenTransfers,
+	balanceChanges: models.BalanceChange.CopyBalanceChanges,
+}
+
+// orphanBlock flags a canonical block and its transactions as orphaned, and
+// reverts its balance changes, within the database transaction.
+func orphanBlock(tx *gorm.DB, block models.BlockIntf) error {
+	// the balance changes of a block already orphaned are already reverted
+	stored, err := models.Block.GetByHash(tx, block.GetHash())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !stored.GetCanonical() {
+		return nil
+	}
+
+	if err := block.UpdateBlockCanonical(tx, false); err != nil {
+		return err
+	}
+	if err := models.Transaction.UpdateCanonicalByBlockHash(tx, block.GetHash(), false); err != nil {
+		return err
+	}
+	changes, err := models.BalanceChange.GetByBlockHash(tx, block.GetHash())
+	if err != nil {
+		return err
+	}
+	return applyBalanceChanges(tx, changes, true)
+}
+
+// applyBalanceChanges applies balance changes to the balances, or reverts
+// them, within the database transaction. Where the database has advisory
+// locks, the balances lock is held until the transaction ends.
+func applyBalanceChanges(tx *gorm.DB, changes []models.BalanceChangeIntf, revert bool) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if database.SupportsAdvisoryLocks() {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", balancesLockKey).Error; err != nil {
+			return err
+		}
+	}
+	return models.Balance.ApplyChanges(tx, changes, revert)
+}
+
+// gormTxStore is the TxStore backed by the database module.
+type gormTxStore struct{}
+
+// GetByHash implements TxStore.
+func (gormTxStore) GetByHash(ctx context.Context, hash string) (models.TransactionIntf, error) {
+	tx, err := models.Transaction.GetByHash(database.GetSQLWithContext(ctx), hash)
+	return tx, notFound(err)
+}
+
+// GetByBlockHash implements TxStore.
+func (gormTxStore) GetByBlockHash(
+	ctx context.Context, hash string) ([]models.TransactionIntf, error) {
+	txs, err := models.Transaction.GetByBlockHash(database.GetSQLWithContext(ctx), hash)
+	return txs, notFound(err)
+}
+
+// SetTransaction implements TxStore.
+func (gormTxStore) SetTransaction(ctx context.Context, tx models.TransactionIntf) error {
+	return tx.SetTransaction(database.GetSQLWithContext(ctx))
+}
+
+// SetTransactions implements TxStore.
+func (gormTxStore) SetTransactions(ctx context.Context, txs []models.TransactionIntf) error {
+	if useCopy(ctx) && database.SupportsCopy() {
+		return models.Transaction.CopyTransactions(database.GetSQLWithContext(ctx), txs)
+	}
+	return models.Transaction.SetTransactions(database.GetSQLWithContext(ctx), txs)
+}
+
+// gormReceiptStore is the ReceiptStore backed by the database module.
+type gormReceiptStore struct{}
+
+// GetByHash implements ReceiptStore.
+func (gormReceiptStore) GetByHash(
+	ctx context.Context, txHash, blockHash string) (models.ReceiptIntf, error) {
+	receipt, err := models.Receipt.GetByHash(database.GetSQLWithContext(ctx), txHash, blockHash)
+	return receipt, notFound(err)
+}
+
+// SetReceipt implements ReceiptStore.
+func (gormReceiptStore) SetReceipt(ctx context.Context, receipt models.ReceiptIntf) error {
+	return receipt.SetReceipt(database.GetSQLWithContext(ctx))
+}
+
+// SetReceipts implements ReceiptStore.
+func (gormReceiptStore) SetReceipts(ctx context.Context, receipts []models.ReceiptIntf) error {
+	if useCopy(ctx) && database.SupportsCopy() {
+		return models.Receipt.CopyReceipts(database.GetSQLWithContext(ctx), receipts)
+	}
+	return models.Receipt.SetReceipts(database.GetSQLWithContext(ctx), receipts)
+}
+
+// gormLogStore is the LogStore backed by the database module.
+type gormLogStore struct{}
+
+// GetByTxHash implements LogStore.
+func (gormLogStore) GetByTxHash(
+	ctx context.Context, txHash, blockHash string) ([]models.TransactionLogIntf, error) {
+	logs, err := models.TransactionLog.GetByHash(
+		database.GetSQLWithContext(ctx), txHash, blockHash)
+	return logs, notFound(err)
+}
+
+// SetLog implements LogStore.
+func (gormLogStore) SetLog(ctx context.Context, log models.TransactionLogIntf) error {
+	return log.SetTransactionLog(database.GetSQLWithContext(ctx))
+}
+
+// SetLogs implements LogStore.
+func (gormLogStore) SetLogs(ctx context.Context, logs []models.TransactionLogIntf) error {
+	if useCopy(ctx) && database.SupportsCopy() {
+		return models.TransactionLog.CopyTransactionLogs(database.GetSQLWithContext(ctx), logs)
+	}
+	return models.TransactionLog.SetTransactionLogs(database.GetSQLWithContext(ctx), logs)
+}
+
+// gormInternalTxStore is the InternalTxStore backed by the database module.
+type gormInternalTxStore struct{}
+
+// GetByTxHash implements InternalTxStore.
+func (gormInternalTxStore) GetByTxHash(
+	ctx context.Context, txHash, blockHash string) ([]models.InternalTransactionIntf, error) {
+	internalTxs, err := models.InternalTransaction.GetByTxHash(
+		database.GetSQLWithContext(ctx), txHash, blockHash)
+	return internalTxs, notFound(err)
+}
+
+// GetByAddress implements InternalTxStore.
+func (gormInternalTxStore) GetByAddress(ctx context.Context,
+	address string, n, maxNumber uint64) ([]models.InternalTransactionIntf, error) {
+	internalTxs, err := models.InternalTransaction.GetByAddress(
+		database.GetSQLWithContext(ctx), address, n, maxNumber)
+	return internalTxs, notFound(err)
+}
+
+// SetInternalTransactions implements InternalTxStore.
+func (gormInternalTxStore) SetInternalTransactions(
+	ctx context.Context, internalTxs []models.InternalTransactionIntf) error {
+	if useCopy(ctx) && database.SupportsCopy() {
+		return models.InternalTransaction.CopyInternalTransactions(
+			database.GetSQLWithContext(ctx), internalTxs)
+	}
+	return models.InternalTransaction.SetInternalTransactions(
+		database.GetSQLWithContext(ctx), internalTxs)
+}
+
+// gormContractStore is the ContractStore backed by the database module.
+type gormContractStore struct{}
+
+// GetByAddress implements ContractStore.
+func (gormContractStore) GetByAddress(
+	ctx context.Context, address string) (models.ContractIntf, error) {
+	contract, err := models.Contract.GetByAddress(database.GetSQLWithContext(ctx), address)
+	return contract, notFound(err)
+}
+
+// GetCode implements ContractStore.
+func (gormContractStore) GetCode(
+	ctx context.Context, codeHash string) (models.ContractCodeIntf, error) {
+	code, err := models.ContractCode.GetByHash(database.GetSQLWithContext(ctx), codeHash)
+	return code, notFound(err)
+}
+
+// gormTokenStore is the TokenStore backed by the database module.
+type gormTokenStore struct{}
+
+// GetByAddress implements TokenStore.
+func (gormTokenStore) GetByAddress(ctx context.Context, address string) (models.TokenIntf, error) {
+	token, err := models.Token.GetByAddress(database.GetSQLWithContext(ctx), address)
+	return token, notFound(err)
+}
+
+// GetDue implements TokenStore.
+func (gormTokenStore) GetDue(ctx context.Context, now int64, n uint64) ([]models.TokenIntf, error) {
+	tokens, err := models.Token.GetDue(database.GetSQLWithContext(ctx), now, n)
+	return tokens, notFound(err)
+}
+
+// UpdateToken implements TokenStore, if this instance still leads.
+func (gormTokenStore) UpdateToken(ctx context.Context, token models.TokenIntf) error {
+	return fenced(ctx, token.UpdateToken)
+}
+
+// gormTokenTransferStore is the TokenTransferStore backed by the database
+// module.
+type gormTokenTransferStore struct{}
+
+// GetByToken implements TokenTransferStore.
+func (gormTokenTransferStore) GetByToken(ctx context.Context,
+	token string, n, maxNumber uint64) ([]models.TokenTransferIntf, error) {
+	transfers, err := models.TokenTransfer.GetByToken(
+		database.GetSQLWithContext(ctx), token, n, maxNumber)
+	return transfers, notFound(err)
+}
+
+// GetByAddress implements TokenTransferStore.
+func (gormTokenTransferStore) GetByAddress(ctx context.Context,
+	address string, n, maxNumber uint64) ([]models.TokenTransferIntf, error) {
+	transfers, err := models.TokenTransfer.GetByAddress(
+		database.GetSQLWithContext(ctx), address, n, maxNumber)
+	return transfers, notFound(err)
+}
+
+// gormBalanceStore is the BalanceStore backed by the database module.
+type gormBalanceStore struct{}
+
+// GetByHolder implements BalanceStore.
+func (gormBalanceStore) GetByHolder(ctx context.Context, holder string) ([]models.BalanceIntf, error) {
+	balances, err := models.Balance.GetByHolder(database.GetSQLWithContext(ctx), holder)
+	return balances, notFound(err)
+}
+
+// GetByToken implements BalanceStore.
+func (gormBalanceStore) GetByToken(
+	ctx context.Context, token string, holders []string) ([]models.BalanceIntf, error) {
+	balances, err := models.Balance.GetByToken(database.GetSQLWithContext(ctx), token, holders)
+	return balances, notFound(err)
+}
+
+// GetTopHolders implements BalanceStore.
+func (gormBalanceStore) GetTopHolders(
+	ctx context.Context, token string, n uint64) ([]models.BalanceIntf, error) {
+	balances, err := models.Balance.GetTopHolders(database.GetSQLWithContext(ctx), token, n)
+	return balances, notFound(err)
+}
+
+// GetChangesAfter implements BalanceStore.
+func (gormBalanceStore) GetChangesAfter(ctx context.Context,
+	token, holder string, num uint64) ([]models.BalanceChangeIntf, error) {
+	changes, err := models.BalanceChange.GetAfter(
+		database.GetSQLWithContext(ctx), token, holder, num)
+	return changes, notFound(err)
+}
+
+// GetDue implements BalanceStore.
+func (gormBalanceStore) GetDue(ctx context.Context, n uint64) ([]models.BalanceIntf, error) {
+	balances, err := models.Balance.GetDue(database.GetSQLWithContext(ctx), n)
+	return balances, notFound(err)
+}
+
+// UpdateCheck implements BalanceStore, if this instance still leads.
+func (gormBalanceStore) UpdateCheck(ctx context.Context, balance models.BalanceIntf) error {
+	return fenced(ctx, balance.UpdateCheck)
+}
+
+// gormABIStore is the ABIStore backed by the database module.
+type gormABIStore struct{}
+
+// GetByAddress implements ABIStore.
+func (gormABIStore) GetByAddress(ctx context.Context, address string) (models.ContractABIIntf, error) {
+	abi, err := models.ContractABI.GetByAddress(database.GetSQLWithContext(ctx), address)
+	return abi, notFound(err)
+}
+
+// SetABI implements ABIStore.
+func (gormABIStore) SetABI(ctx context.Context, abi models.ContractABIIntf) error {
+	return abi.SetContractABI(database.GetSQLWithContext(ctx))
+}
+
+// gormWatermarkStore is the WatermarkStore backed by the database module.
+type gormWatermarkStore struct{}
+
+// Get implements WatermarkStore.
+func (gormWatermarkStore) Get(ctx context.Context, name string) (uint64, error) {
+	watermark, err := models.Watermark.GetByName(database.GetSQLWithContext(ctx), name)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	return watermark.GetNumber(), nil
+}
+
+// Advance implements WatermarkStore, if this instance still leads.
+func (gormWatermarkStore) Advance(ctx context.Context, name string, num uint64) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		return models.Watermark.Advance(tx, name, num)
+	})
+}
+
+// Rewind implements WatermarkStore, if this instance still leads.
+func (gormWatermarkStore) Rewind(ctx context.Context, name string, num uint64) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		return models.Watermark.Rewind(tx, name, num)
+	})
+}
+
+// gormReorgStore is the ReorgStore backed by the database module.
+type gormReorgStore struct{}
+
+// GetLatest implements ReorgStore.
+func (gormReorgStore) GetLatest(ctx context.Context, n uint64) ([]models.ReorgIntf, error) {
+	reorgs, err := models.Reorg.GetLatest(database.GetSQLWithContext(ctx), n)
+	return reorgs, notFound(err)
+}
+
+// SetReorg implements ReorgStore, if this instance still leads.
+func (gormReorgStore) SetReorg(ctx context.Context, reorg models.ReorgIntf) error {
+	return fenced(ctx, reorg.SetReorg)
+}
+
+// gormIndexerStatusStore is the IndexerStatusStore backed by the database
+// module.
+type gormIndexerStatusStore struct{}
+
+// Get implements IndexerStatusStore.
+func (gormIndexerStatusStore) Get(ctx context.Context) (models.IndexerStatusIntf, error) {
+	status, err := models.IndexerStatus.Get(database.GetSQLWithContext(ctx))
+	return status, notFound(err)
+}
+
+// SetChainHead implements IndexerStatusStore, if this instance still leads.
+func (gormIndexerStatusStore) SetChainHead(ctx context.Context, num uint64) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		return models.IndexerStatus.SetChainHead(tx, num)
+	})
+}
+
+// SetActiveEndpoint implements IndexerStatusStore, if this instance still
+// leads.
+func (gormIndexerStatusStore) SetActiveEndpoint(ctx context.Context, endpoint string) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		return models.IndexerStatus.SetActiveEndpoint(tx, endpoint)
+	})
+}
+
+// SetFinality implements IndexerStatusStore, if this instance still leads.
+func (gormIndexerStatusStore) SetFinality(ctx context.Context,
+	mode string, safe, finalized *uint64) error {
+	return fenced(ctx, func(tx *gorm.DB) error {
+		return models.IndexerStatus.SetFinality(tx, mode, safe, finalized)
+	})
+}
+
+// fenced runs fn in a database transaction, if this instance still leads.
+// Every write of the indexer goes through it, so that a leader which lost
+// its lock can't write alongside the new one.
+func fenced(ctx context.Context, fn database.DBTransactionFunc) error {
+	tx := database.GetSQLWithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := leader.Check(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// notFound maps the GORM not found error to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
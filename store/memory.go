@@ -0,0 +1,1120 @@
+package store
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"main/models"
+)
+
+// memoryDB holds the records of the in-memory stores. Canonical blocks are
+// kept by number, and orphaned ones by hash. Transactions and receipts are
+// kept by block and transaction hash, and logs by transaction hash, of any
+// block.
+type memoryDB struct {
+	sync.RWMutex
+	blocks   map[uint64]models.BlockIntf
+	orphans  map[string]models.BlockIntf
+	txs      map[txKey]models.TransactionIntf
+	receipts map[txKey]models.ReceiptIntf
+	logs     map[string][]models.TransactionLogIntf
+
+	// internal transactions by transaction hash, of any block
+	internalTxs map[string][]models.InternalTransactionIntf
+
+	// contracts by address, of any block, and bytecode by code hash
+	contracts map[string][]models.ContractIntf
+	code      map[string]models.ContractCodeIntf
+
+	// tokens by address, and token transfers of any block
+	tokens    map[string]models.TokenIntf
+	transfers []models.TokenTransferIntf
+
+	// balances by token and holder, and balance changes of any block
+	balances       map[balanceKey]models.BalanceIntf
+	balanceChanges []models.BalanceChangeIntf
+
+	// ABIs by contract address
+	abis map[string]models.ContractABIIntf
+
+	watermarks map[string]uint64
+	reorgs     []models.ReorgIntf
+	status     models.IndexerStatusIntf
+}
+
+// txKey is the key of a transaction, or its receipt, in the memoryDB.
+type txKey struct{ blockHash, txHash string }
+
+// balanceKey is the key of a balance in the memoryDB.
+type balanceKey struct{ token, holder string }
+
+// newMemoryDB returns an empty memoryDB.
+func newMemoryDB() *memoryDB {
+	return &memoryDB{
+		blocks:   map[uint64]models.BlockIntf{},
+		orphans:  map[string]models.BlockIntf{},
+		txs:      map[txKey]models.TransactionIntf{},
+		receipts: map[txKey]models.ReceiptIntf{},
+		logs:     map[string][]models.TransactionLogIntf{},
+
+		internalTxs: map[string][]models.InternalTransactionIntf{},
+
+		contracts: map[string][]models.ContractIntf{},
+		code:      map[string]models.ContractCodeIntf{},
+
+		tokens: map[string]models.TokenIntf{},
+
+		balances: map[balanceKey]models.BalanceIntf{},
+
+		abis: map[string]models.ContractABIIntf{},
+
+		watermarks: map[string]uint64{},
+	}
+}
+
+// sortedNumbers returns the indexed block numbers in ascending order.
+func (m *memoryDB) sortedNumbers() []uint64 {
+	nums := make([]uint64, 0, len(m.blocks))
+	for num := range m.blocks {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	return nums
+}
+
+// memoryBlockStore is the in-memory BlockStore.
+type memoryBlockStore struct{ m *memoryDB }
+
+// GetBlocks implements BlockStore.
+func (s memoryBlockStore) GetBlocks(ctx context.Context, n, maxNumber uint64,
+	minStatus models.BlockStatus) ([]models.BlockIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	nums := s.m.sortedNumbers()
+	blocks := []models.BlockIntf{}
+	for i := len(nums) - 1; i >= 0 && uint64(len(blocks)) < n; i-- {
+		block := s.m.blocks[nums[i]]
+		if nums[i] <= maxNumber && block.GetStatus().AtLeast(minStatus) {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+// GetByNumber implements BlockStore.
+func (s memoryBlockStore) GetByNumber(ctx context.Context, num uint64) (models.BlockIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	block, ok := s.m.blocks[num]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return block, nil
+}
+
+// GetByHash implements BlockStore.
+func (s memoryBlockStore) GetByHash(ctx context.Context, hash string) (models.BlockIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	for _, block := range s.m.blocks {
+		if block.GetHash() == hash {
+			return block, nil
+		}
+	}
+	if block, ok := s.m.orphans[hash]; ok {
+		return block, nil
+	}
+	return nil, ErrNotFound
+}
+
+// GetRange implements BlockStore.
+func (s memoryBlockStore) GetRange(ctx context.Context, from, to uint64) ([]models.BlockIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	blocks := []models.BlockIntf{}
+	for _, num := range s.m.sortedNumbers() {
+		if num >= from && num <= to {
+			blocks = append(blocks, s.m.blocks[num])
+		}
+	}
+	return blocks, nil
+}
+
+// GetHighest implements BlockStore.
+func (s memoryBlockStore) GetHighest(
+	ctx context.Context, minStatus models.BlockStatus) (models.BlockIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	nums := s.m.sortedNumbers()
+	for i := len(nums) - 1; i >= 0; i-- {
+		block := s.m.blocks[nums[i]]
+		if block.GetStatus().AtLeast(minStatus) {
+			return block, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// GetLowest implements BlockStore.
+func (s memoryBlockStore) GetLowest(ctx context.Context) (models.BlockIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	nums := s.m.sortedNumbers()
+	if len(nums) == 0 {
+		return nil, ErrNotFound
+	}
+	return s.m.blocks[nums[0]], nil
+}
+
+// GetGaps implements BlockStore.
+func (s memoryBlockStore) GetGaps(ctx context.Context, limit uint64) ([]models.BlockGap, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	nums := s.m.sortedNumbers()
+	gaps := []models.BlockGap{}
+	for i := len(nums) - 1; i > 0 && uint64(len(gaps)) < limit; i-- {
+		if nums[i]-nums[i-1] > 1 {
+			gaps = append(gaps, models.BlockGap{From: nums[i-1] + 1, To: nums[i] - 1})
+		}
+	}
+	return gaps, nil
+}
+
+// GetContiguousEnd implements BlockStore.
+func (s memoryBlockStore) GetContiguousEnd(ctx context.Context, from uint64) (uint64, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if _, ok := s.m.blocks[from]; !ok {
+		return 0, ErrNotFound
+	}
+	end := from
+	for {
+		if _, ok := s.m.blocks[end+1]; !ok {
+			return end, nil
+		}
+		end++
+	}
+}
+
+// SetBlock implements BlockStore. Like the database, an existing block with
+// the same number is kept.
+func (s memoryBlockStore) SetBlock(ctx context.Context, block models.BlockIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.m.blocks[block.GetNumber()]; !ok {
+		s.m.blocks[block.GetNumber()] = block
+	}
+	return nil
+}
+
+// GetLessFinal implements BlockStore.
+func (s memoryBlockStore) GetLessFinal(ctx context.Context, n, maxNumber uint64,
+	status models.BlockStatus) ([]models.BlockIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	blocks := []models.BlockIntf{}
+	for _, num := range s.m.sortedNumbers() {
+		block := s.m.blocks[num]
+		if num > maxNumber || uint64(len(blocks)) == n {
+			break
+		}
+		if !block.GetStatus().AtLeast(status) {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+// DeleteBlock implements BlockStore.
+func (s memoryBlockStore) DeleteBlock(ctx context.Context, block models.BlockIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.deleteBlock(block)
+	return nil
+}
+
+// OrphanBlock implements BlockStore.
+func (s memoryBlockStore) OrphanBlock(ctx context.Context, block models.BlockIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.orphanBlock(block)
+	return nil
+}
+
+// CommitBlock implements BlockStore.
+func (s memoryBlockStore) CommitBlock(ctx context.Context, block IndexedBlock) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// orphan an indexed block with another hash, or keep the same one
+	if old, ok := s.m.blocks[block.Block.GetNumber()]; ok {
+		if old.GetHash() == block.Block.GetHash() {
+			if !old.GetStatus().AtLeast(block.Block.GetStatus()) {
+				old.SetStatus(block.Block.GetStatus())
+			}
+			return nil
+		}
+		s.orphanBlock(old)
+	}
+
+	// restore the block if it was orphaned
+	if orphan, ok := s.m.orphans[block.Block.GetHash()]; ok {
+		delete(s.m.orphans, orphan.GetHash())
+		orphan.SetCanonical(true)
+		orphan.SetStatus(block.Block.GetStatus())
+		s.m.blocks[orphan.GetNumber()] = orphan
+	} else {
+		s.m.blocks[block.Block.GetNumber()] = block.Block
+	}
+	for _, tx := range block.Transactions {
+		tx.SetCanonical(true)
+		s.m.txs[txKey{tx.GetBlockHash(), tx.GetTxHash()}] = tx
+	}
+	for _, receipt := range block.Receipts {
+		s.m.receipts[txKey{receipt.GetBlockHash(), receipt.GetTxHash()}] = receipt
+	}
+	logs := memoryLogStore{s.m}
+	for _, log := range block.Logs {
+		if i := logs.find(log); i >= 0 {
+			s.m.logs[log.GetTxHash()][i] = log
+		} else {
+			logs.insert(log)
+		}
+	}
+	memoryInternalTxStore{s.m}.insert(block.InternalTransactions)
+	memoryContractStore{s.m}.insert(block.Contracts, block.ContractCode)
+	memoryTokenTransferStore{s.m}.insert(block.TokenTransfers)
+	for _, token := range block.Tokens {
+		if _, ok := s.m.tokens[token.GetAddress()]; !ok {
+			s.m.tokens[token.GetAddress()] = token
+		}
+	}
+	balances := memoryBalanceStore{s.m}
+	balances.insert(block.BalanceChanges)
+	balances.apply(block.BalanceChanges, false)
+	return nil
+}
+
+// orphanBlock flags a canonical block and its transactions as orphaned, and
+// reverts its balance changes. The caller must hold the lock.
+func (s memoryBlockStore) orphanBlock(block models.BlockIntf) {
+	stored, ok := s.m.blocks[block.GetNumber()]
+	if !ok || stored.GetHash() != block.GetHash() {
+		return
+	}
+	delete(s.m.blocks, block.GetNumber())
+	stored.SetCanonical(false)
+	s.m.orphans[stored.GetHash()] = stored
+
+	for _, tx := range s.m.txs {
+		if tx.GetBlockHash() == block.GetHash() {
+			tx.SetCanonical(false)
+		}
+	}
+	balances := memoryBalanceStore{s.m}
+	balances.apply(balances.getByBlockHash(block.GetHash()), true)
+}
+
+// deleteBlock deletes a block with its transactions, receipts and logs,
+// reverting its balance changes if canonical. The caller must hold the lock.
+func (s memoryBlockStore) deleteBlock(block models.BlockIntf) {
+	// orphan a canonical block first, reverting its balance changes
+	s.orphanBlock(block)
+	if _, ok := s.m.orphans[block.GetHash()]; !ok {
+		return
+	}
+	delete(s.m.orphans, block.GetHash())
+
+	// cascade to the transactions, receipts, logs and internal transactions
+	// of the block
+	for hash, logs := range s.m.logs {
+		kept := logs[:0]
+		for _, log := range logs {
+			if log.GetBlockHash() != block.GetHash() {
+				kept = append(kept, log)
+			}
+		}
+		s.m.logs[hash] = kept
+	}
+	for hash, traced := range s.m.internalTxs {
+		kept := traced[:0]
+		for _, internalTx := range traced {
+			if internalTx.GetBlockHash() != block.GetHash() {
+				kept = append(kept, internalTx)
+			}
+		}
+		s.m.internalTxs[hash] = kept
+	}
+	for key := range s.m.txs {
+		if key.blockHash == block.GetHash() {
+			delete(s.m.txs, key)
+			delete(s.m.receipts, key)
+		}
+	}
+	for address, deployed := range s.m.contracts {
+		kept := deployed[:0]
+		for _, contract := range deployed {
+			if contract.GetBlockHash() != block.GetHash() {
+				kept = append(kept, contract)
+			}
+		}
+		s.m.contracts[address] = kept
+	}
+	transfers := s.m.transfers[:0]
+	for _, transfer := range s.m.transfers {
+		if transfer.GetBlockHash() != block.GetHash() {
+			transfers = append(transfers, transfer)
+		}
+	}
+	s.m.transfers = transfers
+	changes := s.m.balanceChanges[:0]
+	for _, change := range s.m.balanceChanges {
+		if change.GetBlockHash() != block.GetHash() {
+			changes = append(changes, change)
+		}
+	}
+	s.m.balanceChanges = changes
+}
+
+// memoryTxStore is the in-memory TxStore.
+type memoryTxStore struct{ m *memoryDB }
+
+// GetByHash implements TxStore.
+func (s memoryTxStore) GetByHash(ctx context.Context, hash string) (models.TransactionIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	for key, tx := range s.m.txs {
+		if key.txHash == hash && tx.GetCanonical() {
+			return tx, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// GetByBlockHash implements TxStore.
+func (s memoryTxStore) GetByBlockHash(
+	ctx context.Context, hash string) ([]models.TransactionIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	txs := []models.TransactionIntf{}
+	for _, tx := range s.m.txs {
+		if tx.GetBlockHash() == hash {
+			txs = append(txs, tx)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].GetTxHash() < txs[j].GetTxHash() })
+	return txs, nil
+}
+
+// SetTransaction implements TxStore. An existing transaction with the same
+// block and hash is kept.
+func (s memoryTxStore) SetTransaction(ctx context.Context, tx models.TransactionIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := txKey{tx.GetBlockHash(), tx.GetTxHash()}
+	if _, ok := s.m.txs[key]; !ok {
+		s.m.txs[key] = tx
+	}
+	return nil
+}
+
+// SetTransactions implements TxStore.
+func (s memoryTxStore) SetTransactions(ctx context.Context, txs []models.TransactionIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, tx := range txs {
+		s.m.txs[txKey{tx.GetBlockHash(), tx.GetTxHash()}] = tx
+	}
+	return nil
+}
+
+// memoryReceiptStore is the in-memory ReceiptStore.
+type memoryReceiptStore struct{ m *memoryDB }
+
+// GetByHash implements ReceiptStore.
+func (s memoryReceiptStore) GetByHash(
+	ctx context.Context, txHash, blockHash string) (models.ReceiptIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	receipt, ok := s.m.receipts[txKey{blockHash, txHash}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return receipt, nil
+}
+
+// SetReceipt implements ReceiptStore. An existing receipt of the same block
+// and transaction is kept.
+func (s memoryReceiptStore) SetReceipt(ctx context.Context, receipt models.ReceiptIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := txKey{receipt.GetBlockHash(), receipt.GetTxHash()}
+	if _, ok := s.m.receipts[key]; !ok {
+		s.m.receipts[key] = receipt
+	}
+	return nil
+}
+
+// SetReceipts implements ReceiptStore.
+func (s memoryReceiptStore) SetReceipts(ctx context.Context, receipts []models.ReceiptIntf) error {
+	for _, receipt := range receipts {
+		if err := s.SetReceipt(ctx, receipt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memoryLogStore is the in-memory LogStore.
+type memoryLogStore struct{ m *memoryDB }
+
+// GetByTxHash implements LogStore.
+func (s memoryLogStore) GetByTxHash(
+	ctx context.Context, txHash, blockHash string) ([]models.TransactionLogIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	logs := []models.TransactionLogIntf{}
+	for _, log := range s.m.logs[txHash] {
+		if log.GetBlockHash() == blockHash {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// SetLog implements LogStore. An existing log with the same block hash and
+// index is kept.
+func (s memoryLogStore) SetLog(ctx context.Context, log models.TransactionLogIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.find(log) < 0 {
+		s.insert(log)
+	}
+	return nil
+}
+
+// SetLogs implements LogStore.
+func (s memoryLogStore) SetLogs(ctx context.Context, logs []models.TransactionLogIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, log := range logs {
+		if i := s.find(log); i >= 0 {
+			s.m.logs[log.GetTxHash()][i] = log
+		} else {
+			s.insert(log)
+		}
+	}
+	return nil
+}
+
+// find returns the position of the stored log with the same block hash and
+// index, or -1.
+func (s memoryLogStore) find(log models.TransactionLogIntf) int {
+	for i, stored := range s.m.logs[log.GetTxHash()] {
+		if stored.GetBlockHash() == log.GetBlockHash() &&
+			stored.GetLogIndex() == log.GetLogIndex() {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert stores a new log, keeping the logs of a transaction in index order.
+func (s memoryLogStore) insert(log models.TransactionLogIntf) {
+	logs := append(s.m.logs[log.GetTxHash()], log)
+	sort.Slice(logs, func(i, j int) bool { return logs[i].GetLogIndex() < logs[j].GetLogIndex() })
+	s.m.logs[log.GetTxHash()] = logs
+}
+
+// memoryInternalTxStore is the in-memory InternalTxStore.
+type memoryInternalTxStore struct{ m *memoryDB }
+
+// GetByTxHash implements InternalTxStore.
+func (s memoryInternalTxStore) GetByTxHash(
+	ctx context.Context, txHash, blockHash string) ([]models.InternalTransactionIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	internalTxs := []models.InternalTransactionIntf{}
+	for _, internalTx := range s.m.internalTxs[txHash] {
+		if internalTx.GetBlockHash() == blockHash {
+			internalTxs = append(internalTxs, internalTx)
+		}
+	}
+	return internalTxs, nil
+}
+
+// GetByAddress implements InternalTxStore.
+func (s memoryInternalTxStore) GetByAddress(ctx context.Context,
+	address string, n, maxNumber uint64) ([]models.InternalTransactionIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	internalTxs := []models.InternalTransactionIntf{}
+	for txHash, traced := range s.m.internalTxs {
+		for _, internalTx := range traced {
+			tx, ok := s.m.txs[txKey{internalTx.GetBlockHash(), txHash}]
+			if ok && tx.GetCanonical() &&
+				internalTx.GetBlockNumber() <= maxNumber &&
+				(internalTx.GetTxFrom() == address || internalTx.GetTxTo() == address) {
+				internalTxs = append(internalTxs, internalTx)
+			}
+		}
+	}
+	sort.SliceStable(internalTxs, func(i, j int) bool {
+		a, b := internalTxs[i], internalTxs[j]
+		if a.GetBlockNumber() != b.GetBlockNumber() {
+			return a.GetBlockNumber() > b.GetBlockNumber()
+		}
+		if a.GetTxHash() != b.GetTxHash() {
+			return a.GetTxHash() < b.GetTxHash()
+		}
+		return a.GetPosition() < b.GetPosition()
+	})
+	if uint64(len(internalTxs)) > n {
+		internalTxs = internalTxs[:n]
+	}
+	return internalTxs, nil
+}
+
+// SetInternalTransactions implements InternalTxStore.
+func (s memoryInternalTxStore) SetInternalTransactions(
+	ctx context.Context, internalTxs []models.InternalTransactionIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.insert(internalTxs)
+	return nil
+}
+
+// insert stores new internal transactions, keeping existing ones with the
+// same block, transaction and position, and the internal transactions of a
+// transaction in trace order. The caller must hold the lock.
+func (s memoryInternalTxStore) insert(internalTxs []models.InternalTransactionIntf) {
+	for _, internalTx := range internalTxs {
+		txHash := internalTx.GetTxHash()
+		found := false
+		for _, stored := range s.m.internalTxs[txHash] {
+			if stored.GetBlockHash() == internalTx.GetBlockHash() &&
+				stored.GetPosition() == internalTx.GetPosition() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.m.internalTxs[txHash] = append(s.m.internalTxs[txHash], internalTx)
+		}
+	}
+	for _, traced := range s.m.internalTxs {
+		sort.SliceStable(traced, func(i, j int) bool {
+			return traced[i].GetPosition() < traced[j].GetPosition()
+		})
+	}
+}
+
+// memoryContractStore is the in-memory ContractStore.
+type memoryContractStore struct{ m *memoryDB }
+
+// GetByAddress implements ContractStore.
+func (s memoryContractStore) GetByAddress(
+	ctx context.Context, address string) (models.ContractIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	var latest models.ContractIntf
+	for _, contract := range s.m.contracts[address] {
+		tx, ok := s.m.txs[txKey{contract.GetBlockHash(), contract.GetCreationTxHash()}]
+		if !ok || !tx.GetCanonical() {
+			continue
+		}
+		if latest == nil || contract.GetBlockNumber() > latest.GetBlockNumber() {
+			latest = contract
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+// GetCode implements ContractStore.
+func (s memoryContractStore) GetCode(
+	ctx context.Context, codeHash string) (models.ContractCodeIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	code, ok := s.m.code[codeHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return code, nil
+}
+
+// insert stores new contracts and bytecode, keeping existing ones with the
+// same address and block, or code hash. The caller must hold the lock.
+func (s memoryContractStore) insert(
+	contracts []models.ContractIntf, code []models.ContractCodeIntf) {
+	for _, c := range code {
+		if _, ok := s.m.code[c.GetCodeHash()]; !ok {
+			s.m.code[c.GetCodeHash()] = c
+		}
+	}
+	for _, contract := range contracts {
+		found := false
+		for _, stored := range s.m.contracts[contract.GetAddress()] {
+			if stored.GetBlockHash() == contract.GetBlockHash() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.m.contracts[contract.GetAddress()] = append(
+				s.m.contracts[contract.GetAddress()], contract)
+		}
+	}
+}
+
+// memoryTokenStore is the in-memory TokenStore.
+type memoryTokenStore struct{ m *memoryDB }
+
+// GetByAddress implements TokenStore.
+func (s memoryTokenStore) GetByAddress(ctx context.Context, address string) (models.TokenIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	token, ok := s.m.tokens[address]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return token, nil
+}
+
+// GetDue implements TokenStore.
+func (s memoryTokenStore) GetDue(ctx context.Context, now int64, n uint64) ([]models.TokenIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	tokens := []models.TokenIntf{}
+	for _, token := range s.m.tokens {
+		if token.GetNextRefreshAt() <= now {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].GetNextRefreshAt() < tokens[j].GetNextRefreshAt()
+	})
+	if uint64(len(tokens)) > n {
+		tokens = tokens[:n]
+	}
+	return tokens, nil
+}
+
+// UpdateToken implements TokenStore.
+func (s memoryTokenStore) UpdateToken(ctx context.Context, token models.TokenIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.m.tokens[token.GetAddress()]; ok {
+		s.m.tokens[token.GetAddress()] = token
+	}
+	return nil
+}
+
+// memoryTokenTransferStore is the in-memory TokenTransferStore.
+type memoryTokenTransferStore struct{ m *memoryDB }
+
+// GetByToken implements TokenTransferStore.
+func (s memoryTokenTransferStore) GetByToken(ctx context.Context,
+	token string, n, maxNumber uint64) ([]models.TokenTransferIntf, error) {
+	return s.getCanonical(func(t models.TokenTransferIntf) bool {
+		return t.GetToken() == token
+	}, n, maxNumber), nil
+}
+
+// GetByAddress implements TokenTransferStore.
+func (s memoryTokenTransferStore) GetByAddress(ctx context.Context,
+	address string, n, maxNumber uint64) ([]models.TokenTransferIntf, error) {
+	return s.getCanonical(func(t models.TokenTransferIntf) bool {
+		return t.GetTxFrom() == address || t.GetTxTo() == address
+	}, n, maxNumber), nil
+}
+
+// getCanonical returns the latest n matching transfers in canonical
+// transactions of blocks numbered up to maxNumber.
+func (s memoryTokenTransferStore) getCanonical(match func(models.TokenTransferIntf) bool,
+	n, maxNumber uint64) []models.TokenTransferIntf {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	transfers := []models.TokenTransferIntf{}
+	for _, transfer := range s.m.transfers {
+		tx, ok := s.m.txs[txKey{transfer.GetBlockHash(), transfer.GetTxHash()}]
+		if ok && tx.GetCanonical() &&
+			transfer.GetBlockNumber() <= maxNumber && match(transfer) {
+			transfers = append(transfers, transfer)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		a, b := transfers[i], transfers[j]
+		if a.GetBlockNumber() != b.GetBlockNumber() {
+			return a.GetBlockNumber() > b.GetBlockNumber()
+		}
+		return a.GetLogIndex() > b.GetLogIndex()
+	})
+	if uint64(len(transfers)) > n {
+		transfers = transfers[:n]
+	}
+	return transfers
+}
+
+// insert stores new transfers, keeping existing ones with the same block and
+// log index. The caller must hold the lock.
+func (s memoryTokenTransferStore) insert(transfers []models.TokenTransferIntf) {
+	for _, transfer := range transfers {
+		found := false
+		for _, stored := range s.m.transfers {
+			if stored.GetBlockHash() == transfer.GetBlockHash() &&
+				stored.GetLogIndex() == transfer.GetLogIndex() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.m.transfers = append(s.m.transfers, transfer)
+		}
+	}
+}
+
+// memoryBalanceStore is the in-memory BalanceStore.
+type memoryBalanceStore struct{ m *memoryDB }
+
+// GetByHolder implements BalanceStore.
+func (s memoryBalanceStore) GetByHolder(ctx context.Context, holder string) ([]models.BalanceIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	balances := []models.BalanceIntf{}
+	for key, balance := range s.m.balances {
+		if key.holder == holder {
+			balances = append(balances, balance)
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].GetToken() < balances[j].GetToken()
+	})
+	return balances, nil
+}
+
+// GetByToken implements BalanceStore.
+func (s memoryBalanceStore) GetByToken(
+	ctx context.Context, token string, holders []string) ([]models.BalanceIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	balances := []models.BalanceIntf{}
+	for _, holder := range holders {
+		if balance, ok := s.m.balances[balanceKey{token, holder}]; ok {
+			balances = append(balances, balance)
+		}
+	}
+	return balances, nil
+}
+
+// GetTopHolders implements BalanceStore.
+func (s memoryBalanceStore) GetTopHolders(
+	ctx context.Context, token string, n uint64) ([]models.BalanceIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	balances := []models.BalanceIntf{}
+	amounts := map[models.BalanceIntf]*big.Int{}
+	for key, balance := range s.m.balances {
+		amount, ok := new(big.Int).SetString(balance.GetBalance(), 10)
+		if key.token == token && ok && amount.Sign() > 0 {
+			balances = append(balances, balance)
+			amounts[balance] = amount
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if c := amounts[balances[i]].Cmp(amounts[balances[j]]); c != 0 {
+			return c > 0
+		}
+		return balances[i].GetHolder() < balances[j].GetHolder()
+	})
+	if uint64(len(balances)) > n {
+		balances = balances[:n]
+	}
+	return balances, nil
+}
+
+// GetChangesAfter implements BalanceStore.
+func (s memoryBalanceStore) GetChangesAfter(ctx context.Context,
+	token, holder string, num uint64) ([]models.BalanceChangeIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	changes := []models.BalanceChangeIntf{}
+	for _, change := range s.m.balanceChanges {
+		block, ok := s.m.blocks[change.GetBlockNumber()]
+		if ok && block.GetHash() == change.GetBlockHash() && change.GetBlockNumber() > num &&
+			(len(token) == 0 || change.GetToken() == token) &&
+			(len(holder) == 0 || change.GetHolder() == holder) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// GetDue implements BalanceStore.
+func (s memoryBalanceStore) GetDue(ctx context.Context, n uint64) ([]models.BalanceIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	balances := []models.BalanceIntf{}
+	for _, balance := range s.m.balances {
+		balances = append(balances, balance)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].GetCheckedAt() < balances[j].GetCheckedAt()
+	})
+	if uint64(len(balances)) > n {
+		balances = balances[:n]
+	}
+	return balances, nil
+}
+
+// UpdateCheck implements BalanceStore.
+func (s memoryBalanceStore) UpdateCheck(ctx context.Context, balance models.BalanceIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	stored, ok := s.m.balances[balanceKey{balance.GetToken(), balance.GetHolder()}]
+	if ok && stored != balance {
+		checkedBlock := uint64(0)
+		if balance.GetCheckedBlock() != nil {
+			checkedBlock = *balance.GetCheckedBlock()
+		}
+		stored.SetChecked(balance.GetCheckedAt(), checkedBlock,
+			balance.GetOnchainBalance(), balance.GetMismatch())
+	}
+	return nil
+}
+
+// getByBlockHash returns the balance changes of a block. The caller must hold
+// the lock.
+func (s memoryBalanceStore) getByBlockHash(hash string) []models.BalanceChangeIntf {
+	changes := []models.BalanceChangeIntf{}
+	for _, change := range s.m.balanceChanges {
+		if change.GetBlockHash() == hash {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// insert stores new balance changes, keeping existing ones with the same
+// token, holder and block. The caller must hold the lock.
+func (s memoryBalanceStore) insert(changes []models.BalanceChangeIntf) {
+	for _, change := range changes {
+		found := false
+		for _, stored := range s.m.balanceChanges {
+			if stored.GetToken() == change.GetToken() &&
+				stored.GetHolder() == change.GetHolder() &&
+				stored.GetBlockHash() == change.GetBlockHash() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.m.balanceChanges = append(s.m.balanceChanges, change)
+		}
+	}
+}
+
+// apply applies balance changes to the balances, or reverts them. The caller
+// must hold the lock.
+func (s memoryBalanceStore) apply(changes []models.BalanceChangeIntf, revert bool) {
+	for _, change := range changes {
+		delta, ok := new(big.Int).SetString(change.GetDelta(), 10)
+		if !ok {
+			continue
+		}
+		if revert {
+			delta.Neg(delta)
+		}
+		key := balanceKey{change.GetToken(), change.GetHolder()}
+		balance, ok := s.m.balances[key]
+		if !ok {
+			balance = models.NewBalance(key.token, key.holder, "0")
+			s.m.balances[key] = balance
+		}
+		amount, _ := new(big.Int).SetString(balance.GetBalance(), 10)
+		balance.SetBalance(amount.Add(amount, delta).String())
+	}
+}
+
+// memoryABIStore is the in-memory ABIStore.
+type memoryABIStore struct{ m *memoryDB }
+
+// GetByAddress implements ABIStore.
+func (s memoryABIStore) GetByAddress(ctx context.Context, address string) (models.ContractABIIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	abi, ok := s.m.abis[address]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return abi, nil
+}
+
+// SetABI implements ABIStore.
+func (s memoryABIStore) SetABI(ctx context.Context, abi models.ContractABIIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.m.abis[abi.GetAddress()] = abi
+	return nil
+}
+
+// memoryWatermarkStore is the in-memory WatermarkStore.
+type memoryWatermarkStore struct{ m *memoryDB }
+
+// Get implements WatermarkStore.
+func (s memoryWatermarkStore) Get(ctx context.Context, name string) (uint64, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	num, ok := s.m.watermarks[name]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return num, nil
+}
+
+// Advance implements WatermarkStore.
+func (s memoryWatermarkStore) Advance(ctx context.Context, name string, num uint64) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if old, ok := s.m.watermarks[name]; !ok || old < num {
+		s.m.watermarks[name] = num
+	}
+	return nil
+}
+
+// Rewind implements WatermarkStore.
+func (s memoryWatermarkStore) Rewind(ctx context.Context, name string, num uint64) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if old, ok := s.m.watermarks[name]; ok && old > num {
+		s.m.watermarks[name] = num
+	}
+	return nil
+}
+
+// memoryReorgStore is the in-memory ReorgStore.
+type memoryReorgStore struct{ m *memoryDB }
+
+// GetLatest implements ReorgStore.
+func (s memoryReorgStore) GetLatest(ctx context.Context, n uint64) ([]models.ReorgIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	reorgs := []models.ReorgIntf{}
+	for i := len(s.m.reorgs) - 1; i >= 0 && uint64(len(reorgs)) < n; i-- {
+		reorgs = append(reorgs, s.m.reorgs[i])
+	}
+	return reorgs, nil
+}
+
+// SetReorg implements ReorgStore.
+func (s memoryReorgStore) SetReorg(ctx context.Context, reorg models.ReorgIntf) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.m.reorgs = append(s.m.reorgs, reorg)
+	return nil
+}
+
+// memoryIndexerStatusStore is the in-memory IndexerStatusStore.
+type memoryIndexerStatusStore struct{ m *memoryDB }
+
+// Get implements IndexerStatusStore.
+func (s memoryIndexerStatusStore) Get(ctx context.Context) (models.IndexerStatusIntf, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.m.status == nil {
+		return nil, ErrNotFound
+	}
+	return s.m.status, nil
+}
+
+// SetChainHead implements IndexerStatusStore.
+func (s memoryIndexerStatusStore) SetChainHead(ctx context.Context, num uint64) error {
+	seenAt := time.Now().UnixMilli()
+	s.update(func(old models.IndexerStatusIntf) models.IndexerStatusIntf {
+		return models.NewIndexerStatus(num, &seenAt, old.GetActiveEndpoint(),
+			old.GetFinalityMode(), old.GetSafe(), old.GetFinalized())
+	})
+	return nil
+}
+
+// SetActiveEndpoint implements IndexerStatusStore.
+func (s memoryIndexerStatusStore) SetActiveEndpoint(ctx context.Context, endpoint string) error {
+	s.update(func(old models.IndexerStatusIntf) models.IndexerStatusIntf {
+		return models.NewIndexerStatus(old.GetChainHead(), old.GetChainHeadSeenAt(),
+			endpoint, old.GetFinalityMode(), old.GetSafe(), old.GetFinalized())
+	})
+	return nil
+}
+
+// SetFinality implements IndexerStatusStore.
+func (s memoryIndexerStatusStore) SetFinality(ctx context.Context,
+	mode string, safe, finalized *uint64) error {
+	s.update(func(old models.IndexerStatusIntf) models.IndexerStatusIntf {
+		return models.NewIndexerStatus(old.GetChainHead(), old.GetChainHeadSeenAt(),
+			old.GetActiveEndpoint(), mode, safe, finalized)
+	})
+	return nil
+}
+
+// update replaces the status with the one set from the old status, or from
+// an empty one if there is none yet.
+func (s memoryIndexerStatusStore) update(
+	set func(old models.IndexerStatusIntf) models.IndexerStatusIntf) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	old := s.m.status
+	if old == nil {
+		old = models.NewIndexerStatus(0, nil, "", "", nil, nil)
+	}
+	s.m.status = set(old)
+}
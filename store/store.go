@@ -0,0 +1,278 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"main/models"
+)
+
+// ErrNotFound is returned when a requested record doesn't exist.
+var ErrNotFound = errors.New("record not found")
+
+// The name of the watermark every block up to which is indexed.
+const WatermarkIndexed = "indexed"
+
+// IndexedBlock is a block with its transactions, receipts and logs, committed
+// together. Internal transactions are only set if the block was traced.
+type IndexedBlock struct {
+	Block                models.BlockIntf
+	Transactions         []models.TransactionIntf
+	Receipts             []models.ReceiptIntf
+	Logs                 []models.TransactionLogIntf
+	InternalTransactions []models.InternalTransactionIntf
+	Contracts            []models.ContractIntf
+	ContractCode         []models.ContractCodeIntf
+	TokenTransfers       []models.TokenTransferIntf
+	Tokens               []models.TokenIntf
+	BalanceChanges       []models.BalanceChangeIntf
+}
+
+// BlockStore stores blocks. Blocks replaced by a reorg are kept as orphans,
+// which are only found by hash.
+type BlockStore interface {
+	// GetBlocks returns the latest n blocks numbered up to maxNumber at least
+	// as final as minStatus, the most recent first.
+	GetBlocks(ctx context.Context, n, maxNumber uint64,
+		minStatus models.BlockStatus) ([]models.BlockIntf, error)
+	GetByNumber(ctx context.Context, num uint64) (models.BlockIntf, error)
+	GetByHash(ctx context.Context, hash string) (models.BlockIntf, error)
+	// GetRange returns the blocks in the range [from, to] in ascending order.
+	GetRange(ctx context.Context, from, to uint64) ([]models.BlockIntf, error)
+	// GetHighest returns the highest block at least as final as minStatus.
+	GetHighest(ctx context.Context, minStatus models.BlockStatus) (models.BlockIntf, error)
+	GetLowest(ctx context.Context) (models.BlockIntf, error)
+	// GetGaps returns up to limit ranges of missing block numbers, the most
+	// recent first.
+	GetGaps(ctx context.Context, limit uint64) ([]models.BlockGap, error)
+	// GetContiguousEnd returns the highest block number such that every
+	// block from the given number up to it is indexed, or ErrNotFound if the
+	// block of the given number isn't indexed.
+	GetContiguousEnd(ctx context.Context, from uint64) (uint64, error)
+	SetBlock(ctx context.Context, block models.BlockIntf) error
+	// GetLessFinal returns the lowest n blocks numbered up to maxNumber which
+	// are less final than the given status, in ascending order.
+	GetLessFinal(ctx context.Context, n, maxNumber uint64,
+		status models.BlockStatus) ([]models.BlockIntf, error)
+	// DeleteBlock deletes a block with its transactions, receipts and logs,
+	// reverting its balance changes if canonical.
+	DeleteBlock(ctx context.Context, block models.BlockIntf) error
+	// OrphanBlock flags a block and its transactions as orphaned, and
+	// reverts its balance changes.
+	OrphanBlock(ctx context.Context, block models.BlockIntf) error
+	// CommitBlock writes a block with its transactions, receipts, logs,
+	// internal transactions, contracts, token transfers, newly seen tokens
+	// and balance changes atomically, orphaning an indexed block of the same
+	// number with another hash, or restoring an orphan with the same hash.
+	// The balance changes of the blocks orphaned are reverted, and those of
+	// the block committed applied. An indexed block with the same hash is
+	// kept, and its status raised to the status of the committed block if
+	// more final.
+	CommitBlock(ctx context.Context, block IndexedBlock) error
+}
+
+// TxStore stores transactions.
+type TxStore interface {
+	// GetByHash returns a transaction in the canonical block it's in.
+	GetByHash(ctx context.Context, hash string) (models.TransactionIntf, error)
+	// GetByBlockHash returns the transactions of a block, canonical or
+	// orphaned.
+	GetByBlockHash(ctx context.Context, hash string) ([]models.TransactionIntf, error)
+	SetTransaction(ctx context.Context, tx models.TransactionIntf) error
+	// SetTransactions upserts transactions in bulk on their block and hash.
+	SetTransactions(ctx context.Context, txs []models.TransactionIntf) error
+}
+
+// ReceiptStore stores transaction receipts.
+type ReceiptStore interface {
+	// GetByHash returns the receipt of a transaction in a block.
+	GetByHash(ctx context.Context, txHash, blockHash string) (models.ReceiptIntf, error)
+	SetReceipt(ctx context.Context, receipt models.ReceiptIntf) error
+	// SetReceipts inserts receipts in bulk on their block and transaction
+	// hash.
+	SetReceipts(ctx context.Context, receipts []models.ReceiptIntf) error
+}
+
+// LogStore stores transaction logs.
+type LogStore interface {
+	// GetByTxHash returns the logs of a transaction in a block, in index
+	// order.
+	GetByTxHash(ctx context.Context, txHash, blockHash string) ([]models.TransactionLogIntf, error)
+	SetLog(ctx context.Context, log models.TransactionLogIntf) error
+	// SetLogs upserts logs in bulk on their block hash and index.
+	SetLogs(ctx context.Context, logs []models.TransactionLogIntf) error
+}
+
+// InternalTxStore stores the internal transactions traced in transactions.
+type InternalTxStore interface {
+	// GetByTxHash returns the internal transactions of a transaction in a
+	// block, in trace order.
+	GetByTxHash(ctx context.Context, txHash, blockHash string) ([]models.InternalTransactionIntf, error)
+	// GetByAddress returns the latest n internal transactions from or to an
+	// address in canonical transactions of blocks numbered up to maxNumber,
+	// the most recent first.
+	GetByAddress(ctx context.Context, address string, n, maxNumber uint64) ([]models.InternalTransactionIntf, error)
+	// SetInternalTransactions inserts internal transactions in bulk, keeping
+	// existing ones.
+	SetInternalTransactions(ctx context.Context, internalTxs []models.InternalTransactionIntf) error
+}
+
+// ContractStore stores the contracts deployed by transactions, with their
+// bytecode.
+type ContractStore interface {
+	// GetByAddress returns the latest deployment of a contract by a
+	// canonical transaction.
+	GetByAddress(ctx context.Context, address string) (models.ContractIntf, error)
+	GetCode(ctx context.Context, codeHash string) (models.ContractCodeIntf, error)
+}
+
+// TokenStore stores the token contracts seen by the indexer with their
+// metadata.
+type TokenStore interface {
+	GetByAddress(ctx context.Context, address string) (models.TokenIntf, error)
+	// GetDue returns up to n tokens due for a metadata refresh at the given
+	// time in milliseconds, the longest due first.
+	GetDue(ctx context.Context, now int64, n uint64) ([]models.TokenIntf, error)
+	// UpdateToken writes the metadata and the refresh schedule of a token.
+	UpdateToken(ctx context.Context, token models.TokenIntf) error
+}
+
+// TokenTransferStore stores token transfers.
+type TokenTransferStore interface {
+	// GetByToken returns the latest n transfers of a token in canonical
+	// transactions of blocks numbered up to maxNumber, the most recent
+	// first.
+	GetByToken(ctx context.Context, token string, n, maxNumber uint64) ([]models.TokenTransferIntf, error)
+	// GetByAddress returns the latest n transfers from or to an address in
+	// canonical transactions of blocks numbered up to maxNumber, the most
+	// recent first.
+	GetByAddress(ctx context.Context, address string, n, maxNumber uint64) ([]models.TokenTransferIntf, error)
+}
+
+// BalanceStore stores the token balances of holders, derived from the token
+// transfers of the canonical blocks indexed.
+type BalanceStore interface {
+	// GetByHolder returns the balances of a holder of any token, including
+	// zero ones.
+	GetByHolder(ctx context.Context, holder string) ([]models.BalanceIntf, error)
+	// GetByToken returns the balances of the given holders of a token.
+	GetByToken(ctx context.Context, token string, holders []string) ([]models.BalanceIntf, error)
+	// GetTopHolders returns the n balances of a token with the highest
+	// positive balances, the highest first.
+	GetTopHolders(ctx context.Context, token string, n uint64) ([]models.BalanceIntf, error)
+	// GetChangesAfter returns the balance changes of canonical blocks
+	// numbered above num, of the token and of the holder unless empty.
+	GetChangesAfter(ctx context.Context, token, holder string, num uint64) ([]models.BalanceChangeIntf, error)
+	// GetDue returns up to n balances, the least recently spot checked
+	// first.
+	GetDue(ctx context.Context, n uint64) ([]models.BalanceIntf, error)
+	// UpdateCheck writes the result of the last spot check of a balance.
+	UpdateCheck(ctx context.Context, balance models.BalanceIntf) error
+}
+
+// ABIStore stores the ABIs registered for contracts.
+type ABIStore interface {
+	GetByAddress(ctx context.Context, address string) (models.ContractABIIntf, error)
+	// SetABI registers the ABI of a contract, replacing the one registered
+	// before.
+	SetABI(ctx context.Context, abi models.ContractABIIntf) error
+}
+
+// WatermarkStore stores named block heights.
+type WatermarkStore interface {
+	Get(ctx context.Context, name string) (uint64, error)
+	// Advance sets the named watermark to the given number, unless it's
+	// already higher.
+	Advance(ctx context.Context, name string, num uint64) error
+	// Rewind sets the named watermark back to the given number, unless it's
+	// already lower.
+	Rewind(ctx context.Context, name string, num uint64) error
+}
+
+// ReorgStore stores the chain reorganizations seen by the indexer.
+type ReorgStore interface {
+	// GetLatest returns the latest n reorgs, the most recent first.
+	GetLatest(ctx context.Context, n uint64) ([]models.ReorgIntf, error)
+	SetReorg(ctx context.Context, reorg models.ReorgIntf) error
+}
+
+// IndexerStatusStore stores the state of the indexer reported by /status.
+type IndexerStatusStore interface {
+	Get(ctx context.Context) (models.IndexerStatusIntf, error)
+	// SetChainHead records the chain head polled now.
+	SetChainHead(ctx context.Context, num uint64) error
+	SetActiveEndpoint(ctx context.Context, endpoint string) error
+	// SetFinality records the finality heights tracked and how.
+	SetFinality(ctx context.Context, mode string, safe, finalized *uint64) error
+}
+
+// contextKeyCopy is the context key of the bulk copy option.
+type contextKeyCopy struct{}
+
+// WithCopy returns a copy of the context under which bulk writes are loaded
+// with COPY where the database supports it. COPY has a higher setup cost than
+// multi-row inserts, and pays off for the large batches written on backfill.
+func WithCopy(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyCopy{}, true)
+}
+
+// useCopy returns whether bulk writes under the context use COPY.
+func useCopy(ctx context.Context) bool {
+	enabled, _ := ctx.Value(contextKeyCopy{}).(bool)
+	return enabled
+}
+
+// The stores used by the API and the indexer. They're backed by the database
+// module unless replaced, and must only be replaced before they're used.
+var (
+	Blocks      BlockStore         = gormBlockStore{}
+	Txs         TxStore            = gormTxStore{}
+	Receipts    ReceiptStore       = gormReceiptStore{}
+	Logs        LogStore           = gormLogStore{}
+	InternalTxs InternalTxStore    = gormInternalTxStore{}
+	Contracts   ContractStore      = gormContractStore{}
+	Tokens      TokenStore         = gormTokenStore{}
+	Transfers   TokenTransferStore = gormTokenTransferStore{}
+	Balances    BalanceStore       = gormBalanceStore{}
+	ABIs        ABIStore           = gormABIStore{}
+	Watermarks  WatermarkStore     = gormWatermarkStore{}
+	Reorgs      ReorgStore         = gormReorgStore{}
+	Indexer     IndexerStatusStore = gormIndexerStatusStore{}
+)
+
+// UseMemory replaces the stores with empty in-memory stores, e.g. to run
+// handlers without a database.
+func UseMemory() {
+	m := newMemoryDB()
+	Blocks = memoryBlockStore{m}
+	Txs = memoryTxStore{m}
+	Receipts = memoryReceiptStore{m}
+	Logs = memoryLogStore{m}
+	InternalTxs = memoryInternalTxStore{m}
+	Contracts = memoryContractStore{m}
+	Tokens = memoryTokenStore{m}
+	Transfers = memoryTokenTransferStore{m}
+	Balances = memoryBalanceStore{m}
+	ABIs = memoryABIStore{m}
+	Watermarks = memoryWatermarkStore{m}
+	Reorgs = memoryReorgStore{m}
+	Indexer = memoryIndexerStatusStore{m}
+}
+
+// UseDatabase restores the stores backed by the database module, e.g. after
+// UseMemory.
+func UseDatabase() {
+	Blocks = gormBlockStore{}
+	Txs = gormTxStore{}
+	Receipts = gormReceiptStore{}
+	Logs = gormLogStore{}
+	InternalTxs = gormInternalTxStore{}
+	Contracts = gormContractStore{}
+	Tokens = gormTokenStore{}
+	Transfers = gormTokenTransferStore{}
+	Balances = gormBalanceStore{}
+	ABIs = gormABIStore{}
+	Watermarks = gormWatermarkStore{}
+	Reorgs = gormReorgStore{}
+	Indexer = gormIndexerStatusStore{}
+}
@@ -0,0 +1,310 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"main/models"
+	"main/store"
+	"main/store/storetest"
+)
+
+// The token, and the holders transferring it in the tests.
+var (
+	token = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	alice = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	bob   = common.HexToAddress("0x3000000000000000000000000000000000000003")
+)
+
+// backends are the store implementations the tests run against.
+var backends = []struct {
+	name string
+	use  func(t testing.TB)
+}{
+	{"memory", storetest.UseMemory},
+	{"sqlite", storetest.UseSQLite},
+}
+
+// forEachBackend runs a test against each store implementation.
+func forEachBackend(t *testing.T, test func(t *testing.T)) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			backend.use(t)
+			test(t)
+		})
+	}
+}
+
+func TestCommitBlock(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		ctx := context.Background()
+		tx := storetest.Tx(t, 0)
+		block := storetest.NewBlock(1, common.Hash{}, "")
+		block.AddTransfer(t, tx, 0, token, alice, bob, 10)
+		block.Commit(t)
+
+		got, err := store.Blocks.GetByNumber(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetByNumber: %v", err)
+		}
+		if got.GetHash() != block.Hash().String() || !got.GetCanonical() {
+			t.Errorf("block = %s canonical %v, want %s canonical",
+				got.GetHash(), got.GetCanonical(), block.Hash())
+		}
+		if _, err := store.Blocks.GetByNumber(ctx, 2); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetByNumber(2) error = %v, want ErrNotFound", err)
+		}
+		txs, err := store.Txs.GetByBlockHash(ctx, block.Hash().String())
+		if err != nil {
+			t.Fatalf("GetByBlockHash: %v", err)
+		}
+		if len(txs) != 1 || txs[0].GetTxHash() != tx.Hash().String() {
+			t.Errorf("transactions = %v, want %s", txs, tx.Hash())
+		}
+		assertBalance(t, bob, "10")
+	})
+}
+
+func TestCommitBlockReorg(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		ctx := context.Background()
+		tx := storetest.Tx(t, 0)
+
+		// the transaction is mined in a block, then re-mined at another
+		// position by a block replacing it
+		old := storetest.NewBlock(1, common.Hash{}, "old")
+		old.AddTransfer(t, tx, 0, token, alice, bob, 10)
+		old.Commit(t)
+		replacing := storetest.NewBlock(1, common.Hash{}, "new")
+		replacing.AddTransfer(t, storetest.Tx(t, 1), 0, token, alice, bob, 5)
+		replacing.AddTransfer(t, tx, 1, token, alice, bob, 10)
+		replacing.Commit(t)
+
+		got, err := store.Blocks.GetByNumber(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetByNumber: %v", err)
+		}
+		if got.GetHash() != replacing.Hash().String() {
+			t.Errorf("block = %s, want %s", got.GetHash(), replacing.Hash())
+		}
+		orphan, err := store.Blocks.GetByHash(ctx, old.Hash().String())
+		if err != nil {
+			t.Fatalf("GetByHash: %v", err)
+		}
+		if orphan.GetCanonical() {
+			t.Error("replaced block is canonical")
+		}
+		assertTxIn(t, tx.Hash().String(), replacing.Hash().String(), 1)
+		assertBalance(t, bob, "15")
+
+		// the replaced block keeps the transaction, with its logs
+		txs, err := store.Txs.GetByBlockHash(ctx, old.Hash().String())
+		if err != nil {
+			t.Fatalf("GetByBlockHash: %v", err)
+		}
+		if len(txs) != 1 || txs[0].GetTxHash() != tx.Hash().String() || txs[0].GetCanonical() {
+			t.Errorf("transactions of the replaced block = %v, want %s orphaned", txs, tx.Hash())
+		}
+		logs, err := store.Logs.GetByTxHash(ctx, tx.Hash().String(), old.Hash().String())
+		if err != nil {
+			t.Fatalf("Logs.GetByTxHash: %v", err)
+		}
+		if len(logs) != 1 || logs[0].GetLogIndex() != 0 {
+			t.Errorf("got %d logs in the replaced block, want the one at index 0", len(logs))
+		}
+
+		// and back to the block it was first mined in
+		old.Commit(t)
+		assertTxIn(t, tx.Hash().String(), old.Hash().String(), 0)
+		assertBalance(t, bob, "10")
+	})
+}
+
+func TestGetBlocks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		ctx := context.Background()
+		parent := common.Hash{}
+		for num, status := range []models.BlockStatus{
+			models.StatusFinalized, models.StatusFinalized,
+			models.StatusLatest, models.StatusFinalized,
+		} {
+			block := storetest.NewBlock(uint64(num+1), parent, "")
+			block.Block.SetStatus(status)
+			block.Commit(t)
+			parent = block.Hash()
+		}
+
+		// less final blocks are skipped rather than counted in the limit
+		blocks, err := store.Blocks.GetBlocks(ctx, 2, 4, models.StatusFinalized)
+		if err != nil {
+			t.Fatalf("GetBlocks: %v", err)
+		}
+		if len(blocks) != 2 || blocks[0].GetNumber() != 4 || blocks[1].GetNumber() != 2 {
+			t.Errorf("got %d blocks, want 4 and 2", len(blocks))
+		}
+		blocks, err = store.Blocks.GetBlocks(ctx, 2, 3, models.StatusLatest)
+		if err != nil {
+			t.Fatalf("GetBlocks: %v", err)
+		}
+		if len(blocks) != 2 || blocks[0].GetNumber() != 3 || blocks[1].GetNumber() != 2 {
+			t.Errorf("got %d blocks, want 3 and 2", len(blocks))
+		}
+	})
+}
+
+func TestGetLessFinal(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		ctx := context.Background()
+		parent := common.Hash{}
+		for num, status := range []models.BlockStatus{
+			models.StatusFinalized, models.StatusLatest, models.StatusSafe,
+			models.StatusLatest, models.StatusLatest,
+		} {
+			block := storetest.NewBlock(uint64(num+1), parent, "")
+			block.Block.SetStatus(status)
+			block.Commit(t)
+			parent = block.Hash()
+		}
+
+		// the lowest blocks first, up to the limit and the number
+		blocks, err := store.Blocks.GetLessFinal(ctx, 2, 5, models.StatusFinalized)
+		if err != nil {
+			t.Fatalf("GetLessFinal: %v", err)
+		}
+		if len(blocks) != 2 || blocks[0].GetNumber() != 2 || blocks[1].GetNumber() != 3 {
+			t.Errorf("got %d blocks, want 2 and 3", len(blocks))
+		}
+		blocks, err = store.Blocks.GetLessFinal(ctx, 10, 4, models.StatusSafe)
+		if err != nil {
+			t.Fatalf("GetLessFinal: %v", err)
+		}
+		if len(blocks) != 2 || blocks[0].GetNumber() != 2 || blocks[1].GetNumber() != 4 {
+			t.Errorf("got %d blocks, want 2 and 4", len(blocks))
+		}
+	})
+}
+
+func TestWatermarks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		ctx := context.Background()
+		if _, err := store.Watermarks.Get(ctx, "test"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Get error = %v, want ErrNotFound", err)
+		}
+		for _, step := range []struct {
+			advance bool
+			num     uint64
+			want    uint64
+		}{
+			{true, 5, 5},
+			{true, 3, 5},
+			{false, 7, 5},
+			{false, 2, 2},
+			{true, 4, 4},
+		} {
+			var err error
+			if step.advance {
+				err = store.Watermarks.Advance(ctx, "test", step.num)
+			} else {
+				err = store.Watermarks.Rewind(ctx, "test", step.num)
+			}
+			if err != nil {
+				t.Fatalf("moving watermark to %d: %v", step.num, err)
+			}
+			got, err := store.Watermarks.Get(ctx, "test")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got != step.want {
+				t.Errorf("after moving to %d, watermark = %d, want %d",
+					step.num, got, step.want)
+			}
+		}
+	})
+}
+
+func TestIndexerStatus(t *testing.T) {
+	forEachBackend(t, func(t *testing.T) {
+		ctx := context.Background()
+		if _, err := store.Indexer.Get(ctx); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Get error = %v, want ErrNotFound", err)
+		}
+
+		// each setter leaves the state the others recorded
+		safe, finalized := uint64(90), uint64(80)
+		if err := store.Indexer.SetChainHead(ctx, 100); err != nil {
+			t.Fatalf("SetChainHead: %v", err)
+		}
+		if err := store.Indexer.SetActiveEndpoint(ctx, "https://node"); err != nil {
+			t.Fatalf("SetActiveEndpoint: %v", err)
+		}
+		if err := store.Indexer.SetFinality(ctx, "tags", &safe, &finalized); err != nil {
+			t.Fatalf("SetFinality: %v", err)
+		}
+		if err := store.Indexer.SetChainHead(ctx, 101); err != nil {
+			t.Fatalf("SetChainHead: %v", err)
+		}
+
+		got, err := store.Indexer.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.GetChainHead() != 101 || got.GetChainHeadSeenAt() == nil ||
+			got.GetActiveEndpoint() != "https://node" || got.GetFinalityMode() != "tags" ||
+			got.GetSafe() == nil || *got.GetSafe() != safe ||
+			got.GetFinalized() == nil || *got.GetFinalized() != finalized {
+			t.Errorf("status = %+v, want head 101 at https://node, safe %d, finalized %d",
+				got, safe, finalized)
+		}
+	})
+}
+
+// assertTxIn checks that a transaction is read from the given block, with
+// its receipt and its only log at the given index.
+func assertTxIn(t *testing.T, txHash, blockHash string, logIndex int64) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := store.Txs.GetByHash(ctx, txHash)
+	if err != nil {
+		t.Fatalf("Txs.GetByHash: %v", err)
+	}
+	if tx.GetBlockHash() != blockHash {
+		t.Errorf("transaction block = %s, want %s", tx.GetBlockHash(), blockHash)
+	}
+	if !tx.GetCanonical() {
+		t.Error("transaction is orphaned")
+	}
+	if _, err := store.Receipts.GetByHash(ctx, txHash, blockHash); err != nil {
+		t.Fatalf("Receipts.GetByHash: %v", err)
+	}
+	logs, err := store.Logs.GetByTxHash(ctx, txHash, blockHash)
+	if err != nil {
+		t.Fatalf("Logs.GetByTxHash: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(logs))
+	}
+	if logs[0].GetBlockHash() != blockHash || logs[0].GetLogIndex() != logIndex {
+		t.Errorf("log = %d in %s, want %d in %s", logs[0].GetLogIndex(),
+			logs[0].GetBlockHash(), logIndex, blockHash)
+	}
+}
+
+// assertBalance checks the balance of the test token held by a holder.
+func assertBalance(t *testing.T, holder common.Address, want string) {
+	t.Helper()
+	balances, err := store.Balances.GetByToken(context.Background(),
+		token.String(), []string{holder.String()})
+	if err != nil {
+		t.Fatalf("GetByToken: %v", err)
+	}
+	got := "0"
+	for _, balance := range balances {
+		got = balance.GetBalance()
+	}
+	if got != want {
+		t.Errorf("balance of %s = %s, want %s", holder, got, want)
+	}
+}
@@ -0,0 +1,181 @@
+// Package storetest provides helpers to set up the stores and fill them with
+// blocks in tests.
+package storetest
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"main/database"
+	"main/models"
+	"main/store"
+)
+
+// TopicTransfer is the topic of the Transfer(address,address,uint256) event.
+var TopicTransfer = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// The key signing the transactions of the tests.
+var key, _ = crypto.GenerateKey()
+
+// The signer of the transactions of the tests.
+var signer = types.LatestSignerForChainID(big.NewInt(1))
+
+// UseMemory replaces the stores with empty in-memory stores for the duration
+// of the test.
+func UseMemory(t testing.TB) {
+	t.Helper()
+	store.UseMemory()
+	t.Cleanup(store.UseDatabase)
+}
+
+// UseSQLite backs the stores with an empty SQLite database for the duration
+// of the test, with every migration applied.
+func UseSQLite(t testing.TB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := database.InitializeSQLite(context.Background(), path); err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	store.UseDatabase()
+	t.Cleanup(database.Finalize)
+}
+
+// Tx returns a transaction of the test sender with the given nonce.
+func Tx(t testing.TB, nonce uint64) *types.Transaction {
+	t.Helper()
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: big.NewInt(1),
+		Gas:      21000,
+		To:       &common.Address{},
+		Value:    big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+// Block is an indexed block built by a test.
+type Block struct {
+	store.IndexedBlock
+	block *types.Block
+}
+
+// NewBlock returns an empty block numbered num on top of parent. Blocks of
+// the same number are told apart by extra.
+func NewBlock(num uint64, parent common.Hash, extra string) *Block {
+	block := types.NewBlockWithHeader(&types.Header{
+		Number:      new(big.Int).SetUint64(num),
+		ParentHash:  parent,
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  new(big.Int),
+		Time:        num,
+		Extra:       []byte(extra),
+	})
+	return &Block{
+		IndexedBlock: store.IndexedBlock{Block: models.NewBlock(block)},
+		block:        block,
+	}
+}
+
+// Hash returns the hash of the block.
+func (b *Block) Hash() common.Hash {
+	return b.block.Hash()
+}
+
+// Header returns the header of the block, as a node serves it.
+func (b *Block) Header() *types.Header {
+	return b.block.Header()
+}
+
+// AddTransfer adds a transaction to the block, with its receipt and the log
+// at the given index of a transfer of amount of token from an address to
+// another, and the resulting balance changes.
+func (b *Block) AddTransfer(t testing.TB, tx *types.Transaction, logIndex uint,
+	token, from, to common.Address, amount int64) {
+	t.Helper()
+	blockHash := b.Hash().String()
+	num := b.block.NumberU64()
+
+	newTx, err := models.NewTransaction(tx, blockHash)
+	if err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+	newReceipt, err := models.NewReceipt(&types.Receipt{
+		TxHash: tx.Hash(), BlockHash: b.Hash()})
+	if err != nil {
+		t.Fatalf("failed to create receipt: %v", err)
+	}
+	newLog, err := models.NewTransactionLog(&types.Log{
+		Address: token,
+		Topics: []common.Hash{TopicTransfer,
+			common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:      common.BigToHash(big.NewInt(amount)).Bytes(),
+		TxHash:    tx.Hash(),
+		BlockHash: b.Hash(),
+		Index:     logIndex,
+	}, tx.Hash().String())
+	if err != nil {
+		t.Fatalf("failed to create log: %v", err)
+	}
+
+	b.Transactions = append(b.Transactions, newTx)
+	b.Receipts = append(b.Receipts, newReceipt)
+	b.Logs = append(b.Logs, newLog)
+	b.TokenTransfers = append(b.TokenTransfers, models.NewTokenTransfer(
+		blockHash, num, tx.Hash().String(), int64(logIndex), token.String(),
+		from.String(), to.String(), big.NewInt(amount).String()))
+	b.addToken(token.String())
+	b.addBalanceChange(token.String(), from.String(), big.NewInt(-amount))
+	b.addBalanceChange(token.String(), to.String(), big.NewInt(amount))
+}
+
+// addToken adds a token to the tokens seen in the block, unless already
+// there.
+func (b *Block) addToken(address string) {
+	for _, token := range b.Tokens {
+		if token.GetAddress() == address {
+			return
+		}
+	}
+	b.Tokens = append(b.Tokens, models.NewToken(address))
+}
+
+// addBalanceChange adds delta to the balance change of a holder of a token
+// in the block, the way the indexer nets them.
+func (b *Block) addBalanceChange(token, holder string, delta *big.Int) {
+	for i, change := range b.BalanceChanges {
+		if change.GetToken() == token && change.GetHolder() == holder {
+			sum, _ := new(big.Int).SetString(change.GetDelta(), 10)
+			sum.Add(sum, delta)
+			b.BalanceChanges[i] = models.NewBalanceChange(token, holder,
+				b.Hash().String(), b.block.NumberU64(), sum.String())
+			return
+		}
+	}
+	b.BalanceChanges = append(b.BalanceChanges, models.NewBalanceChange(token,
+		holder, b.Hash().String(), b.block.NumberU64(), delta.String()))
+}
+
+// Commit commits the block to the stores, and advances the indexed
+// watermark up to it.
+func (b *Block) Commit(t testing.TB) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.Blocks.CommitBlock(ctx, b.IndexedBlock); err != nil {
+		t.Fatalf("failed to commit block: %v", err)
+	}
+	err := store.Watermarks.Advance(ctx, store.WatermarkIndexed, b.block.NumberU64())
+	if err != nil {
+		t.Fatalf("failed to advance watermark: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/config"
+	"main/global"
+	"main/lifecycle"
+	"main/logging"
+)
+
+// instrumentationName is the name of the tracer used by all modules.
+const instrumentationName = "main"
+
+// Static configuration variables initalized at runtime.
+var exporterName string
+var otlpEndpoint string
+var filePath string
+var sampleRatio float64
+var shutdownTimeout time.Duration
+
+// Singleton tracer provider and the file written by the file exporter.
+var provider *sdktrace.TracerProvider
+var traceFile *os.File
+
+// init loads the tracing configurations.
+func init() {
+	exporterName = config.GetString("TRACING_EXPORTER")
+	otlpEndpoint = config.GetString("TRACING_OTLP_ENDPOINT")
+	filePath = config.GetString("TRACING_FILE_PATH")
+	sampleRatio = config.GetFloat64("TRACING_SAMPLE_RATIO")
+	shutdownTimeout = config.GetMilliseconds("TRACING_SHUTDOWN_TIMEOUT_MS")
+}
+
+// Initialize initializes the tracing module. Supported exporters are "otlp"
+// (OTLP over HTTP), "stdout", "file" and "none".
+func Initialize(ctx context.Context) {
+	// Always propagate W3C trace context, even if spans are not exported.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	// Create span exporter according to configuration.
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName {
+	case "none", "":
+		return
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpoint(otlpEndpoint),
+			otlptracehttp.WithInsecure())
+	case "stdout":
+		exporter, err = newWriterExporter(os.Stdout)
+	case "file":
+		traceFile, err = os.OpenFile(filePath,
+			os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err == nil {
+			exporter, err = newWriterExporter(traceFile)
+		}
+	default:
+		panic("invalid tracing exporter: " + exporterName)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	// Create and install the tracer provider.
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName()),
+			attribute.String("service.version", global.GitCommitHash),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	// Flush pending spans on shutdown.
+	lifecycle.Register("tracing", func(ctx context.Context) error {
+		shutdown(ctx)
+		return nil
+	})
+}
+
+// Finalize flushes pending spans and shuts down the tracing module.
+func Finalize() {
+	shutdown(context.Background())
+}
+
+// shutdown flushes pending spans within TRACING_SHUTDOWN_TIMEOUT_MS, or by the
+// deadline of the context if sooner, and shuts down the tracer provider.
+func shutdown(ctx context.Context) {
+	if provider == nil {
+		return
+	}
+
+	// Flush pending spans within the timeout.
+	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		logging.Error(ctx, "Failed to shutdown tracer provider: %v", err)
+	}
+
+	if traceFile != nil {
+		traceFile.Close()
+	}
+}
+
+// Start starts a span as a child of the span in the context, if any.
+func Start(ctx context.Context, name string,
+	attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithAttributes(attrs...))
+}
+
+// StartWithKind starts a span of the given kind, e.g. a server or client span.
+func StartWithKind(ctx context.Context, name string, kind trace.SpanKind,
+	attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// EndWithError records the error, if any, on the span and ends it.
+func EndWithError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// newWriterExporter creates an exporter writing spans as JSON to w.
+func newWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(w))
+}
+
+// serviceName returns the service name reported with every span.
+func serviceName() string {
+	if len(global.ServiceName) > 0 {
+		return global.ServiceName
+	}
+	return config.GetString("PROJECT_NAME")
+}